@@ -0,0 +1,159 @@
+package rlogs
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+
+	"github.com/hamba/avro"
+	"github.com/hamba/avro/ocf"
+	"github.com/pkg/errors"
+)
+
+// S3AvroLoader is for Apache Avro Object Container File on AWS S3, e.g. output
+// of Kafka Connect S3 sink connector. The schema is read from header of the
+// file. One record is converted to one MessageQueue and Raw of the MessageQueue
+// is the record encoded as JSON object. A value of union is unwrapped (e.g.
+// `{"string":"abc"}` to `"abc"`), timestamp and date logical types are encoded
+// as RFC3339 string and decimal is encoded as number.
+type S3AvroLoader struct {
+	S3Options
+}
+
+// Load of S3AvroLoader reads an Avro object record by record
+func (x *S3AvroLoader) Load(src LogSource) chan *MessageQueue {
+	return x.LoadContext(context.Background(), src)
+}
+
+// LoadContext of S3AvroLoader is Load that stops reading the object when ctx is done
+func (x *S3AvroLoader) LoadContext(ctx context.Context, src LogSource) chan *MessageQueue {
+	chMsg := make(chan *MessageQueue)
+
+	go func() {
+		defer close(chMsg)
+
+		r, err := getObjectReader(ctx, src, s3Download{S3Options: x.S3Options})
+		if err != nil {
+			sendMessage(ctx, chMsg, &MessageQueue{Error: err})
+			return
+		}
+		defer r.Close()
+
+		dec, err := ocf.NewDecoder(r)
+		if err != nil {
+			sendMessage(ctx, chMsg, &MessageQueue{Error: errors.Wrap(err, "Fail to read Avro header")})
+			return
+		}
+
+		schema, err := avro.Parse(string(dec.Metadata()["avro.schema"]))
+		if err != nil {
+			sendMessage(ctx, chMsg, &MessageQueue{Error: errors.Wrap(err, "Fail to parse Avro schema")})
+			return
+		}
+
+		seq := 0
+		for dec.HasNext() {
+			var record interface{}
+			if err := dec.Decode(&record); err != nil {
+				sendMessage(ctx, chMsg, &MessageQueue{Error: errors.Wrapf(err, "Fail to decode Avro record [%d]", seq)})
+				return
+			}
+
+			raw, err := json.Marshal(avroValue(schema, record))
+			if err != nil {
+				sendMessage(ctx, chMsg, &MessageQueue{Error: errors.Wrapf(err, "Fail to encode Avro record [%d]", seq)})
+				return
+			}
+
+			if !sendMessage(ctx, chMsg, &MessageQueue{
+				Raw: raw,
+				Seq: seq,
+				Src: src,
+			}) {
+				return
+			}
+			seq++
+		}
+
+		if err := dec.Error(); err != nil {
+			sendMessage(ctx, chMsg, &MessageQueue{Error: errors.Wrap(err, "Fail to read Avro block")})
+			return
+		}
+	}()
+
+	return chMsg
+}
+
+// avroValue converts a value decoded by avro package to a value that is
+// encoded to JSON naturally according to the schema.
+func avroValue(schema avro.Schema, v interface{}) interface{} {
+	if ref, ok := schema.(*avro.RefSchema); ok {
+		schema = ref.Schema()
+	}
+
+	switch s := schema.(type) {
+	case *avro.RecordSchema:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return v
+		}
+		for _, f := range s.Fields() {
+			if fv, ok := m[f.Name()]; ok {
+				m[f.Name()] = avroValue(f.Type(), fv)
+			}
+		}
+		return m
+
+	case *avro.ArraySchema:
+		if a, ok := v.([]interface{}); ok {
+			for i := range a {
+				a[i] = avroValue(s.Items(), a[i])
+			}
+		}
+		return v
+
+	case *avro.MapSchema:
+		if m, ok := v.(map[string]interface{}); ok {
+			for k := range m {
+				m[k] = avroValue(s.Values(), m[k])
+			}
+		}
+		return v
+
+	case *avro.UnionSchema:
+		// Non-null value of union is wrapped by a map that has one key of type name
+		m, ok := v.(map[string]interface{})
+		if !ok || len(m) != 1 {
+			return v
+		}
+		for _, t := range s.Types() {
+			if uv, ok := m[avroTypeName(t)]; ok {
+				return avroValue(t, uv)
+			}
+		}
+		return v
+	}
+
+	if r, ok := v.(*big.Rat); ok {
+		f, _ := r.Float64()
+		return f
+	}
+
+	return v
+}
+
+// avroTypeName returns name of type used as key of union value.
+func avroTypeName(schema avro.Schema) string {
+	if ref, ok := schema.(*avro.RefSchema); ok {
+		schema = ref.Schema()
+	}
+	if n, ok := schema.(avro.NamedSchema); ok {
+		return n.FullName()
+	}
+
+	name := string(schema.Type())
+	if ls, ok := schema.(avro.LogicalTypeSchema); ok && ls.Logical() != nil {
+		name += "." + string(ls.Logical().Type())
+	}
+	return name
+}
@@ -0,0 +1,86 @@
+package rlogs_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/hamba/avro/ocf"
+	"github.com/m-mizutani/rlogs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type dummyS3ClientData struct {
+	rlogs.TestS3ClientBase
+	data []byte
+}
+
+func (x *dummyS3ClientData) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	return &s3.GetObjectOutput{Body: toReadCloser(string(x.data))}, nil
+}
+
+const avroTestSchema = `{
+	"type": "record", "name": "event", "namespace": "test",
+	"fields": [
+		{"name": "host", "type": "string"},
+		{"name": "port", "type": ["null", "int"]},
+		{"name": "user", "type": ["null", {"type": "record", "name": "user", "fields": [{"name": "name", "type": "string"}]}]},
+		{"name": "tags", "type": {"type": "array", "items": "string"}},
+		{"name": "ts", "type": {"type": "long", "logicalType": "timestamp-millis"}}
+	]
+}`
+
+func TestS3AvroLoader(t *testing.T) {
+	ts := time.Date(2019, 10, 19, 4, 44, 44, 123000000, time.UTC)
+
+	var buf bytes.Buffer
+	enc, err := ocf.NewEncoder(avroTestSchema, &buf)
+	require.NoError(t, err)
+	require.NoError(t, enc.Encode(map[string]interface{}{
+		"host": "host0",
+		"port": map[string]interface{}{"int": 8000},
+		"user": map[string]interface{}{"test.user": map[string]interface{}{"name": "alice"}},
+		"tags": []interface{}{"a", "b"},
+		"ts":   ts,
+	}))
+	require.NoError(t, enc.Encode(map[string]interface{}{
+		"host": "host1",
+		"port": nil,
+		"user": nil,
+		"tags": []interface{}{},
+		"ts":   ts,
+	}))
+	require.NoError(t, enc.Close())
+
+	rlogs.InjectNewS3Client(&dummyS3ClientData{data: buf.Bytes()})
+	defer rlogs.FixNewS3Client()
+
+	ldr := rlogs.S3AvroLoader{}
+	var messages []*rlogs.MessageQueue
+	for msg := range ldr.Load(&rlogs.AwsS3LogSource{Region: "ap-northeast-1", Bucket: "my-own-bucket", Key: "data.avro"}) {
+		require.NoError(t, msg.Error)
+		messages = append(messages, msg)
+	}
+
+	require.Equal(t, 2, len(messages))
+	assert.Equal(t, 1, messages[1].Seq)
+	assert.JSONEq(t, `{"host":"host0","port":8000,"user":{"name":"alice"},"tags":["a","b"],"ts":"2019-10-19T04:44:44.123Z"}`, string(messages[0].Raw))
+	assert.JSONEq(t, `{"host":"host1","port":null,"user":null,"tags":[],"ts":"2019-10-19T04:44:44.123Z"}`, string(messages[1].Raw))
+}
+
+func TestS3AvroLoaderNotAvro(t *testing.T) {
+	rlogs.InjectNewS3Client(&dummyS3ClientData{data: []byte("blue\norange\nred\n")})
+	defer rlogs.FixNewS3Client()
+
+	ldr := rlogs.S3AvroLoader{}
+	var messages []*rlogs.MessageQueue
+	for msg := range ldr.Load(&rlogs.AwsS3LogSource{Region: "ap-northeast-1", Bucket: "my-own-bucket", Key: "data.json"}) {
+		messages = append(messages, msg)
+	}
+
+	require.Equal(t, 1, len(messages))
+	require.Error(t, messages[0].Error)
+	assert.Contains(t, messages[0].Error.Error(), "Fail to read Avro header")
+}
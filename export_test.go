@@ -1,6 +1,11 @@
 package rlogs
 
-import "github.com/aws/aws-sdk-go/service/s3"
+import (
+	"context"
+	"io"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+)
 
 // InjectNewS3Client replaces mock s3Client for testing. Use the function in only test case.
 func InjectNewS3Client(c s3Client) {
@@ -17,3 +22,45 @@ type TestS3ClientBase struct{}
 func (x *TestS3ClientBase) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
 	return nil, nil
 }
+
+// HeadObject is dummy function. It should be overwritten if required in test.
+func (x *TestS3ClientBase) HeadObject(input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
+	return nil, nil
+}
+
+// OpenS3Object returns reader of S3 object that is used by loaders. Use the function in only test case.
+func OpenS3Object(src LogSource, partSize int64, concurrency int) (io.ReadCloser, error) {
+	return getObjectReader(context.Background(), src, s3Download{PartSize: partSize, Concurrency: concurrency})
+}
+
+// DecodeORCIntRLE decodes n integers of ORC integer run length encoding version 1 or 2. Use the function in only test case.
+func DecodeORCIntRLE(data []byte, version int, signed bool, n int) ([]int64, error) {
+	var r orcIntReader = &orcRLEv1{data: data, signed: signed}
+	if version == 2 {
+		r = &orcRLEv2{data: data, signed: signed}
+	}
+
+	values := make([]int64, n)
+	for i := range values {
+		v, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// DecodeORCByteRLE decodes n bytes of ORC byte run length encoding. Use the function in only test case.
+func DecodeORCByteRLE(data []byte, n int) ([]byte, error) {
+	r := &orcByteRLE{data: data}
+	values := make([]byte, n)
+	for i := range values {
+		v, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
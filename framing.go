@@ -0,0 +1,397 @@
+package rlogs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// S3DelimitedLoader is for log file on AWS S3 that has records delimited by a
+// byte other than line break, e.g. NUL delimited records.
+type S3DelimitedLoader struct {
+	S3Options
+	ScanBufferSize  int
+	ScanBufferLimit int
+	// Delimiter of records. NUL (0x00) is used if zero.
+	Delimiter byte
+}
+
+// Load of S3DelimitedLoader reads a log object record by record
+func (x *S3DelimitedLoader) Load(src LogSource) chan *MessageQueue {
+	return x.LoadContext(context.Background(), src)
+}
+
+// LoadContext of S3DelimitedLoader is Load that stops reading the object when ctx is done
+func (x *S3DelimitedLoader) LoadContext(ctx context.Context, src LogSource) chan *MessageQueue {
+	return scanObject(ctx, src, newScanDelimited(x.Delimiter), x.ScanBufferSize, x.ScanBufferLimit, s3Download{S3Options: x.S3Options})
+}
+
+func newScanDelimited(delim byte) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+		if i := bytes.IndexByte(data, delim); i >= 0 {
+			return i + 1, data[:i], nil
+		}
+		if atEOF {
+			return len(data), data, nil
+		}
+		return 0, nil, nil // Request more data
+	}
+}
+
+// LengthPrefixFormat is format of length prefix of a record.
+type LengthPrefixFormat int
+
+const (
+	// LengthPrefixVarint is unsigned varint length prefix (e.g. delimited protobuf messages)
+	LengthPrefixVarint LengthPrefixFormat = iota
+	// LengthPrefixRecordIO is RecordIO format, decimal length and line break (e.g. "5\nhello")
+	LengthPrefixRecordIO
+	// LengthPrefixUint32 is 4 bytes big endian length prefix
+	LengthPrefixUint32
+)
+
+// S3LengthPrefixedLoader is for log file on AWS S3 that has length-prefixed records.
+type S3LengthPrefixedLoader struct {
+	S3Options
+	ScanBufferSize  int
+	ScanBufferLimit int
+	// Format of length prefix. LengthPrefixVarint is used by default.
+	Format LengthPrefixFormat
+}
+
+// Load of S3LengthPrefixedLoader reads a log object record by record
+func (x *S3LengthPrefixedLoader) Load(src LogSource) chan *MessageQueue {
+	return x.LoadContext(context.Background(), src)
+}
+
+// LoadContext of S3LengthPrefixedLoader is Load that stops reading the object when ctx is done
+func (x *S3LengthPrefixedLoader) LoadContext(ctx context.Context, src LogSource) chan *MessageQueue {
+	var readLength func(data []byte) (uint64, int, error)
+	switch x.Format {
+	case LengthPrefixVarint:
+		readLength = readVarintLength
+	case LengthPrefixRecordIO:
+		readLength = readRecordIOLength
+	case LengthPrefixUint32:
+		readLength = readUint32Length
+	default:
+		chMsg := make(chan *MessageQueue, 1)
+		chMsg <- &MessageQueue{Error: fmt.Errorf("Invalid LengthPrefixFormat: %d", x.Format)}
+		close(chMsg)
+		return chMsg
+	}
+
+	return scanObject(ctx, src, newScanLengthPrefixed(readLength), x.ScanBufferSize, x.ScanBufferLimit, s3Download{S3Options: x.S3Options})
+}
+
+// newScanLengthPrefixed returns split function for length-prefixed records.
+// readLength returns length of the record and size of the prefix, or 0 size if
+// data is not enough to read the prefix.
+func newScanLengthPrefixed(readLength func(data []byte) (uint64, int, error)) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+
+		length, size, err := readLength(data)
+		if err != nil {
+			return 0, nil, err
+		}
+		if size > 0 && uint64(len(data)-size) >= length {
+			end := size + int(length)
+			return end, data[size:end], nil
+		}
+
+		if atEOF {
+			return 0, nil, fmt.Errorf("Truncated length-prefixed record")
+		}
+		return 0, nil, nil // Request more data
+	}
+}
+
+func readVarintLength(data []byte) (uint64, int, error) {
+	length, size := binary.Uvarint(data)
+	if size < 0 {
+		return 0, 0, fmt.Errorf("Invalid varint length prefix")
+	}
+	return length, size, nil
+}
+
+func readRecordIOLength(data []byte) (uint64, int, error) {
+	i := bytes.IndexByte(data, '\n')
+	if i < 0 {
+		if len(data) > 20 { // longer than max digits of uint64
+			return 0, 0, fmt.Errorf("Invalid RecordIO length prefix")
+		}
+		return 0, 0, nil
+	}
+
+	length, err := strconv.ParseUint(string(data[:i]), 10, 64)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "Invalid RecordIO length prefix")
+	}
+	return length, i + 1, nil
+}
+
+func readUint32Length(data []byte) (uint64, int, error) {
+	if len(data) < 4 {
+		return 0, 0, nil
+	}
+	return uint64(binary.BigEndian.Uint32(data)), 4, nil
+}
+
+// kplMagic is magic number of Kinesis Producer Library aggregated record.
+var kplMagic = []byte{0xF3, 0x89, 0x9A, 0xC2}
+
+// S3KPLLoader is for AWS S3 object that consists of Kinesis Producer Library
+// (KPL) aggregated records, e.g. delivered by Kinesis Data Firehose from a
+// stream that KPL puts records into. User records in aggregated records are
+// de-aggregated and sent as individual messages. KPL puts a single user record
+// without aggregation, then data between aggregated records (found by magic
+// number) is sent as one message. Records that are not aggregated and
+// concatenated without aggregated record between them can not be separated.
+type S3KPLLoader struct {
+	S3Options
+	ScanBufferSize  int
+	ScanBufferLimit int
+}
+
+// Load of S3KPLLoader reads a log object and de-aggregates user records
+func (x *S3KPLLoader) Load(src LogSource) chan *MessageQueue {
+	return x.LoadContext(context.Background(), src)
+}
+
+// LoadContext of S3KPLLoader is Load that stops reading the object when ctx is done
+func (x *S3KPLLoader) LoadContext(ctx context.Context, src LogSource) chan *MessageQueue {
+	chMsg := make(chan *MessageQueue)
+
+	go func() {
+		defer close(chMsg)
+
+		r, err := getObjectReader(ctx, src, s3Download{S3Options: x.S3Options})
+		if err != nil {
+			sendMessage(ctx, chMsg, &MessageQueue{Error: err})
+			return
+		}
+		defer r.Close()
+
+		limit := defaultS3LineLoaderScanBufferLimit
+		if x.ScanBufferLimit > 0 {
+			limit = x.ScanBufferLimit
+		}
+		scanner := newObjectScanner(r, splitKPLRecords(limit), x.ScanBufferSize, x.ScanBufferLimit)
+
+		seq := 0
+		for scanner.Scan() {
+			data := make([]byte, len(scanner.Bytes()))
+			copy(data, scanner.Bytes())
+
+			if !bytes.HasPrefix(data, kplMagic) {
+				if !sendMessage(ctx, chMsg, &MessageQueue{Raw: data, Seq: seq, Src: src}) {
+					return
+				}
+				seq++
+				continue
+			}
+
+			records, err := parseKPLRecords(data[len(kplMagic) : len(data)-md5.Size])
+			if err != nil {
+				sendMessage(ctx, chMsg, &MessageQueue{Error: errors.Wrapf(err, "Fail to parse KPL aggregated record [%d]", seq)})
+				return
+			}
+			for _, record := range records {
+				if !sendMessage(ctx, chMsg, &MessageQueue{Raw: record, Seq: seq, Src: src}) {
+					return
+				}
+				seq++
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			sendMessage(ctx, chMsg, &MessageQueue{Error: errors.Wrapf(err, "Fail to read KPL record [%d]", seq)})
+			return
+		}
+	}()
+
+	return chMsg
+}
+
+// splitKPLRecords returns split function that splits data to KPL aggregated
+// records and data that is not aggregated record (until next magic number).
+// An aggregated record longer than limit is not accepted.
+func splitKPLRecords(limit int) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+
+		if !bytes.HasPrefix(data, kplMagic) {
+			if len(data) < len(kplMagic) && bytes.HasPrefix(kplMagic, data) && !atEOF {
+				return 0, nil, nil // Request more data to check magic number
+			}
+
+			if i := bytes.Index(data[1:], kplMagic); i >= 0 {
+				return i + 1, data[:i+1], nil
+			}
+			if atEOF {
+				return len(data), data, nil
+			}
+			return 0, nil, nil // Request more data
+		}
+
+		size, err := kplAggregatedRecordSize(data, limit, atEOF)
+		if err != nil {
+			return 0, nil, err
+		}
+		if size == 0 {
+			return 0, nil, nil // The aggregated record continues
+		}
+
+		return size, data[:size], nil
+	}
+}
+
+// kplAggregatedRecordSize finds end of an aggregated record at head of data,
+// that is magic number, protobuf message of AggregatedRecord and MD5 digest of
+// the message. End of the protobuf message is found by the MD5 digest because
+// aggregated records are concatenated without delimiter. It returns 0 if the
+// record may continue beyond data, then MD5 digest is not computed until all
+// candidates of end of the message can be checked.
+func kplAggregatedRecordSize(data []byte, limit int, atEOF bool) (int, error) {
+	body := data[len(kplMagic):]
+	maxEnd := limit - len(kplMagic) - md5.Size
+
+	// Boundaries of fields are candidates of end of the message. Walking fields
+	// stops at a byte that can not be a field of AggregatedRecord (incl. a field
+	// that does not fit in limit), that should be the MD5 digest.
+	var ends []int
+	continued, tooLong := true, false
+	for pos := 0; pos < len(body); {
+		tag, n := binary.Uvarint(body[pos:])
+		if n == 0 {
+			break // The tag continues beyond data
+		}
+		if n < 0 || tag>>3 < 1 || tag>>3 > 3 || tag&7 != 2 {
+			continued = false
+			break
+		}
+
+		length, m := binary.Uvarint(body[pos+n:])
+		if m == 0 {
+			break // The length continues beyond data
+		}
+		if m < 0 {
+			continued = false
+			break
+		}
+		if pos+n+m > maxEnd || length > uint64(maxEnd-pos-n-m) {
+			continued, tooLong = false, true
+			break
+		}
+		if length > uint64(len(body)-pos-n-m) {
+			break // The field continues beyond data
+		}
+
+		pos += n + m + int(length)
+		ends = append(ends, pos)
+	}
+
+	if !atEOF && (continued || (len(ends) > 0 && len(body) < ends[len(ends)-1]+md5.Size)) {
+		return 0, nil // Request more data to complete the last field or its digest
+	}
+
+	for i := len(ends) - 1; i >= 0; i-- {
+		end := ends[i]
+		if len(body) < end+md5.Size {
+			continue
+		}
+		digest := md5.Sum(body[:end])
+		if bytes.Equal(digest[:], body[end:end+md5.Size]) {
+			return len(kplMagic) + end + md5.Size, nil
+		}
+	}
+
+	if tooLong {
+		return 0, bufio.ErrTooLong
+	}
+	return 0, fmt.Errorf("No valid MD5 digest of KPL aggregated record")
+}
+
+// parseKPLRecords extracts data of records (field 3) in AggregatedRecord message.
+func parseKPLRecords(msg []byte) ([][]byte, error) {
+	var records [][]byte
+	for pos := 0; pos < len(msg); {
+		field, _, record, next, err := readProtobufField(msg, pos)
+		if err != nil {
+			return nil, err
+		}
+		pos = next
+		if field != 3 {
+			continue
+		}
+
+		// Record message has data in field 3
+		var recordData []byte
+		for p := 0; p < len(record); {
+			f, _, v, n, err := readProtobufField(record, p)
+			if err != nil {
+				return nil, err
+			}
+			if f == 3 {
+				recordData = v
+			}
+			p = n
+		}
+		records = append(records, recordData)
+	}
+
+	return records, nil
+}
+
+// readProtobufField reads a field of protobuf message at pos and returns field
+// number, wire type, value of length-delimited field and position of next field.
+func readProtobufField(msg []byte, pos int) (uint64, int, []byte, int, error) {
+	tag, size := binary.Uvarint(msg[pos:])
+	if size <= 0 {
+		return 0, 0, nil, 0, fmt.Errorf("Invalid protobuf tag")
+	}
+	pos += size
+	field, wireType := tag>>3, int(tag&7)
+
+	var value []byte
+	switch wireType {
+	case 0: // varint
+		_, n := binary.Uvarint(msg[pos:])
+		if n <= 0 {
+			return 0, 0, nil, 0, fmt.Errorf("Invalid protobuf varint")
+		}
+		pos += n
+	case 1: // 64-bit
+		pos += 8
+	case 2: // length-delimited
+		length, n := binary.Uvarint(msg[pos:])
+		if n <= 0 || length > uint64(len(msg)-pos-n) {
+			return 0, 0, nil, 0, fmt.Errorf("Invalid protobuf length")
+		}
+		value = msg[pos+n : pos+n+int(length)]
+		pos += n + int(length)
+	case 5: // 32-bit
+		pos += 4
+	default:
+		return 0, 0, nil, 0, fmt.Errorf("Unsupported protobuf wire type: %d", wireType)
+	}
+
+	if pos > len(msg) {
+		return 0, 0, nil, 0, fmt.Errorf("Truncated protobuf field")
+	}
+	return field, wireType, value, pos, nil
+}
@@ -0,0 +1,208 @@
+package rlogs_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/m-mizutani/rlogs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3DelimitedLoader(t *testing.T) {
+	rlogs.InjectNewS3Client(&dummyS3ClientData{data: []byte("blue\x00orange\nlemon\x00\x00red")})
+	defer rlogs.FixNewS3Client()
+
+	messages := loadMessages(&rlogs.S3DelimitedLoader{}, "data.bin")
+	require.Equal(t, 4, len(messages))
+	assert.Equal(t, "blue", string(messages[0].Raw))
+	assert.Equal(t, "orange\nlemon", string(messages[1].Raw))
+	assert.Equal(t, "", string(messages[2].Raw))
+	assert.Equal(t, "red", string(messages[3].Raw))
+	assert.Equal(t, 3, messages[3].Seq)
+
+	messages = loadMessages(&rlogs.S3DelimitedLoader{Delimiter: '\n'}, "data.bin")
+	require.Equal(t, 2, len(messages))
+}
+
+func TestS3LengthPrefixedLoader(t *testing.T) {
+	long := make([]byte, 300)
+	for i := range long {
+		long[i] = 'x'
+	}
+	var varint []byte
+	for _, r := range [][]byte{[]byte("blue"), long, []byte("")} {
+		varint = append(varint, protoVarint(uint64(len(r)))...)
+		varint = append(varint, r...)
+	}
+
+	testCases := []struct {
+		format rlogs.LengthPrefixFormat
+		data   []byte
+		expect []string
+	}{
+		{rlogs.LengthPrefixVarint, varint, []string{"blue", string(long), ""}},
+		{rlogs.LengthPrefixRecordIO, []byte("4\nblue6\norange0\n"), []string{"blue", "orange", ""}},
+		{rlogs.LengthPrefixUint32, []byte("\x00\x00\x00\x04blue\x00\x00\x00\x03red"), []string{"blue", "red"}},
+	}
+
+	for _, tc := range testCases {
+		rlogs.InjectNewS3Client(&dummyS3ClientData{data: tc.data})
+		messages := loadMessages(&rlogs.S3LengthPrefixedLoader{Format: tc.format, ScanBufferSize: 16}, "data.bin")
+		require.Equal(t, len(tc.expect), len(messages))
+		for i, expect := range tc.expect {
+			require.NoError(t, messages[i].Error)
+			assert.Equal(t, expect, string(messages[i].Raw))
+			assert.Equal(t, i, messages[i].Seq)
+		}
+	}
+	rlogs.FixNewS3Client()
+}
+
+func TestS3LengthPrefixedLoaderErrorCase(t *testing.T) {
+	defer rlogs.FixNewS3Client()
+
+	rlogs.InjectNewS3Client(&dummyS3ClientData{data: []byte("10\nblue")})
+	messages := loadMessages(&rlogs.S3LengthPrefixedLoader{Format: rlogs.LengthPrefixRecordIO}, "data.bin")
+	require.Equal(t, 1, len(messages))
+	assert.Contains(t, messages[0].Error.Error(), "Truncated")
+
+	rlogs.InjectNewS3Client(&dummyS3ClientData{data: []byte("blue\n")})
+	messages = loadMessages(&rlogs.S3LengthPrefixedLoader{Format: rlogs.LengthPrefixRecordIO}, "data.bin")
+	require.Equal(t, 1, len(messages))
+	assert.Contains(t, messages[0].Error.Error(), "Invalid RecordIO length prefix")
+
+	messages = loadMessages(&rlogs.S3LengthPrefixedLoader{Format: 99}, "data.bin")
+	require.Equal(t, 1, len(messages))
+	assert.Contains(t, messages[0].Error.Error(), "Invalid LengthPrefixFormat")
+}
+
+func protoVarint(v uint64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return buf[:binary.PutUvarint(buf, v)]
+}
+
+func protoBytes(field uint64, data []byte) []byte {
+	b := protoVarint(field<<3 | 2)
+	b = append(b, protoVarint(uint64(len(data)))...)
+	return append(b, data...)
+}
+
+func kplAggregate(records ...string) []byte {
+	var msg []byte
+	msg = append(msg, protoBytes(1, []byte("pk1"))...)
+	for _, r := range records {
+		var record []byte
+		record = append(record, 0x08, 0x00) // partition_key_index = 0
+		record = append(record, protoBytes(3, []byte(r))...)
+		msg = append(msg, protoBytes(3, record)...)
+	}
+	digest := md5.Sum(msg)
+
+	data := []byte{0xF3, 0x89, 0x9A, 0xC2}
+	data = append(data, msg...)
+	return append(data, digest[:]...)
+}
+
+func TestS3KPLLoader(t *testing.T) {
+	var data []byte
+	data = append(data, kplAggregate(`{"color":"blue"}`, `{"color":"orange"}`)...)
+	data = append(data, kplAggregate(`{"color":"red"}`)...)
+	data = append(data, []byte(`{"color":"lemon"}`)...)
+
+	rlogs.InjectNewS3Client(&dummyS3ClientData{data: data})
+	defer rlogs.FixNewS3Client()
+
+	messages := loadMessages(&rlogs.S3KPLLoader{}, "data.bin")
+	require.Equal(t, 4, len(messages))
+	for i, expect := range []string{`{"color":"blue"}`, `{"color":"orange"}`, `{"color":"red"}`, `{"color":"lemon"}`} {
+		require.NoError(t, messages[i].Error)
+		assert.Equal(t, expect, string(messages[i].Raw))
+		assert.Equal(t, i, messages[i].Seq)
+	}
+}
+
+func TestS3KPLLoaderMixedRecords(t *testing.T) {
+	var data []byte
+	data = append(data, kplAggregate(`{"color":"blue"}`, `{"color":"orange"}`)...)
+	data = append(data, []byte(`{"color":"lemon"}`)...)
+	data = append(data, kplAggregate(`{"color":"red"}`)...)
+	data = append(data, []byte(`{"color":"lime"}`)...)
+	data = append(data, kplAggregate(`{"color":"green"}`)...)
+
+	rlogs.InjectNewS3Client(&dummyS3ClientData{data: data})
+	defer rlogs.FixNewS3Client()
+
+	// Small buffer requires to read aggregated record across some reads
+	messages := loadMessages(&rlogs.S3KPLLoader{ScanBufferSize: 8}, "data.bin")
+	require.Equal(t, 6, len(messages))
+	for i, expect := range []string{`{"color":"blue"}`, `{"color":"orange"}`, `{"color":"lemon"}`, `{"color":"red"}`, `{"color":"lime"}`, `{"color":"green"}`} {
+		require.NoError(t, messages[i].Error)
+		assert.Equal(t, expect, string(messages[i].Raw))
+		assert.Equal(t, i, messages[i].Seq)
+	}
+}
+
+func TestS3KPLLoaderBufferLimit(t *testing.T) {
+	data := kplAggregate(`{"color":"blue"}`, `{"color":"orange"}`)
+
+	rlogs.InjectNewS3Client(&dummyS3ClientData{data: data})
+	defer rlogs.FixNewS3Client()
+
+	messages := loadMessages(&rlogs.S3KPLLoader{ScanBufferSize: 8, ScanBufferLimit: 16}, "data.bin")
+	require.Equal(t, 1, len(messages))
+	require.Error(t, messages[0].Error)
+	assert.Contains(t, messages[0].Error.Error(), "Fail to read KPL record")
+}
+
+func TestS3KPLLoaderBrokenDigest(t *testing.T) {
+	data := kplAggregate(`{"color":"blue"}`)
+	data[len(data)-1] ^= 0xFF
+
+	rlogs.InjectNewS3Client(&dummyS3ClientData{data: data})
+	defer rlogs.FixNewS3Client()
+
+	messages := loadMessages(&rlogs.S3KPLLoader{}, "data.bin")
+	require.Equal(t, 1, len(messages))
+	require.Error(t, messages[0].Error)
+	assert.Contains(t, messages[0].Error.Error(), "No valid MD5 digest")
+}
+
+type dummyS3ClientOneByte struct {
+	rlogs.TestS3ClientBase
+	data []byte
+}
+
+func (x *dummyS3ClientOneByte) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	return &s3.GetObjectOutput{Body: ioutil.NopCloser(iotest.OneByteReader(bytes.NewReader(x.data)))}, nil
+}
+
+func TestS3KPLLoaderOneByteRead(t *testing.T) {
+	// MD5 digest of long message should not be computed for each byte
+	records := []string{strings.Repeat("x", 256*1024)}
+	for i := 0; i < 1000; i++ {
+		records = append(records, fmt.Sprintf(`{"seq":%d}`, i))
+	}
+	var data []byte
+	data = append(data, kplAggregate(records...)...)
+	data = append(data, []byte(`{"color":"lemon"}`)...)
+	records = append(records, `{"color":"lemon"}`)
+
+	rlogs.InjectNewS3Client(&dummyS3ClientOneByte{data: data})
+	defer rlogs.FixNewS3Client()
+
+	messages := loadMessages(&rlogs.S3KPLLoader{}, "data.bin")
+	require.Equal(t, len(records), len(messages))
+	for i, expect := range records {
+		require.NoError(t, messages[i].Error)
+		assert.Equal(t, expect, string(messages[i].Raw))
+		assert.Equal(t, i, messages[i].Seq)
+	}
+}
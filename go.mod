@@ -1,11 +1,31 @@
 module github.com/m-mizutani/rlogs
 
 require (
-	github.com/aws/aws-sdk-go v1.17.9
-	github.com/pkg/errors v0.8.1
+	github.com/aws/aws-sdk-go v1.44.0
+	github.com/golang/snappy v0.0.4
+	github.com/hamba/avro v1.6.6
+	github.com/klauspost/compress v1.13.1
+	github.com/pierrec/lz4/v4 v4.1.8
+	github.com/pkg/errors v0.9.1
 	github.com/sirupsen/logrus v1.4.2
-	github.com/stretchr/testify v1.3.0
-	golang.org/x/net v0.0.0-20190403144856-b630fd6fe46b // indirect
+	github.com/stretchr/testify v1.7.0
+	github.com/xitongsys/parquet-go v1.6.2
 )
 
-go 1.13
+require (
+	github.com/apache/arrow/go/arrow v0.0.0-20200730104253-651201b0f516 // indirect
+	github.com/apache/thrift v0.14.2 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/jmespath/go-jmespath v0.4.0 // indirect
+	github.com/json-iterator/go v1.1.12 // indirect
+	github.com/konsorten/go-windows-terminal-sequences v1.0.1 // indirect
+	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
+	github.com/modern-go/reflect2 v1.0.2 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/xitongsys/parquet-go-source v0.0.0-20200817004010-026bad9b25d0 // indirect
+	golang.org/x/sys v0.0.0-20211216021012-1d35b9e2eb4e // indirect
+	golang.org/x/xerrors v0.0.0-20191204190536-9bdfabe68543 // indirect
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
+)
+
+go 1.17
@@ -0,0 +1,38 @@
+package rlogs
+
+import (
+	"fmt"
+	"time"
+)
+
+// ObjectSizeLimitError is error that size of log object exceeds MaxObjectSize of Pipeline.
+type ObjectSizeLimitError struct {
+	Src   LogSource
+	Size  int64
+	Limit int64
+}
+
+func (x *ObjectSizeLimitError) Error() string {
+	return fmt.Sprintf("Size of log object exceeds limit (%d > %d bytes): %v", x.Size, x.Limit, x.Src)
+}
+
+// RecordLimitError is error that number of log records in log object exceeds
+// MaxRecords of Pipeline.
+type RecordLimitError struct {
+	Src   LogSource
+	Limit int
+}
+
+func (x *RecordLimitError) Error() string {
+	return fmt.Sprintf("Number of log records exceeds limit (%d): %v", x.Limit, x.Src)
+}
+
+// TimeLimitError is error that processing log object exceeds Timeout of Pipeline.
+type TimeLimitError struct {
+	Src   LogSource
+	Limit time.Duration
+}
+
+func (x *TimeLimitError) Error() string {
+	return fmt.Sprintf("Processing log object exceeds time limit (%v): %v", x.Limit, x.Src)
+}
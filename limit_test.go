@@ -0,0 +1,305 @@
+package rlogs_test
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/m-mizutani/rlogs"
+	"github.com/m-mizutani/rlogs/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type dummyS3ClientLimit struct {
+	rlogs.TestS3ClientBase
+	data string
+	gets int
+}
+
+func (x *dummyS3ClientLimit) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	x.gets++
+	return &s3.GetObjectOutput{Body: toReadCloser(x.data)}, nil
+}
+
+func (x *dummyS3ClientLimit) HeadObject(input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
+	return &s3.HeadObjectOutput{ContentLength: aws.Int64(int64(len(x.data)))}, nil
+}
+
+// slowLoader sends a message and then blocks until closed.
+type slowLoader struct {
+	closed chan struct{}
+}
+
+func (x *slowLoader) Load(src rlogs.LogSource) chan *rlogs.MessageQueue {
+	ch := make(chan *rlogs.MessageQueue)
+	go func() {
+		defer close(ch)
+		ch <- &rlogs.MessageQueue{Raw: []byte(`{"ts":1571460000,"color":"blue"}`), Src: src}
+		<-x.closed
+	}()
+	return ch
+}
+
+func runPipeline(pipe rlogs.Pipeline) []*rlogs.LogQueue {
+	ch := make(chan *rlogs.LogQueue, 128)
+	go pipe.Run(&rlogs.AwsS3LogSource{
+		Region: "ap-northeast-1",
+		Bucket: "my-own-bucket",
+		Key:    "data.log",
+	}, ch)
+
+	var queues []*rlogs.LogQueue
+	for q := range ch {
+		queues = append(queues, q)
+	}
+	return queues
+}
+
+const limitTestData = `{"ts":1571460000,"color":"blue"}
+{"ts":1571460001,"color":"orange"}
+{"ts":1571460002,"color":"red"}
+`
+
+func TestPipelineMaxObjectSize(t *testing.T) {
+	defer rlogs.FixNewS3Client()
+
+	dummy := &dummyS3ClientLimit{data: limitTestData}
+	rlogs.InjectNewS3Client(dummy)
+
+	queues := runPipeline(rlogs.Pipeline{
+		Ldr:           &rlogs.S3LineLoader{},
+		Psr:           &parser.JSON{Tag: "test", UnixtimeField: rlogs.String("ts")},
+		MaxObjectSize: 64,
+	})
+	require.Equal(t, 1, len(queues))
+	err, ok := queues[0].Error.(*rlogs.ObjectSizeLimitError)
+	require.True(t, ok)
+	assert.Equal(t, int64(len(limitTestData)), err.Size)
+	assert.Equal(t, int64(64), err.Limit)
+	assert.Equal(t, 0, dummy.gets)
+
+	queues = runPipeline(rlogs.Pipeline{
+		Ldr:           &rlogs.S3LineLoader{},
+		Psr:           &parser.JSON{Tag: "test", UnixtimeField: rlogs.String("ts")},
+		MaxObjectSize: int64(len(limitTestData)),
+	})
+	require.Equal(t, 3, len(queues))
+	for _, q := range queues {
+		require.NoError(t, q.Error)
+	}
+}
+
+func TestPipelineMaxRecords(t *testing.T) {
+	defer rlogs.FixNewS3Client()
+	rlogs.InjectNewS3Client(&dummyS3ClientLimit{data: limitTestData})
+
+	queues := runPipeline(rlogs.Pipeline{
+		Ldr:        &rlogs.S3LineLoader{},
+		Psr:        &parser.JSON{Tag: "test", UnixtimeField: rlogs.String("ts")},
+		MaxRecords: 2,
+	})
+	require.Equal(t, 3, len(queues))
+	require.NoError(t, queues[0].Error)
+	require.NoError(t, queues[1].Error)
+	assert.True(t, strings.Contains(string(queues[1].Log.Raw), "orange"))
+	err, ok := queues[2].Error.(*rlogs.RecordLimitError)
+	require.True(t, ok)
+	assert.Equal(t, 2, err.Limit)
+
+	queues = runPipeline(rlogs.Pipeline{
+		Ldr:        &rlogs.S3LineLoader{},
+		Psr:        &parser.JSON{Tag: "test", UnixtimeField: rlogs.String("ts")},
+		MaxRecords: 3,
+	})
+	require.Equal(t, 3, len(queues))
+	for _, q := range queues {
+		require.NoError(t, q.Error)
+	}
+}
+
+func TestPipelineTimeout(t *testing.T) {
+	ldr := &slowLoader{closed: make(chan struct{})}
+	defer close(ldr.closed)
+
+	queues := runPipeline(rlogs.Pipeline{
+		Ldr:     ldr,
+		Psr:     &parser.JSON{Tag: "test", UnixtimeField: rlogs.String("ts")},
+		Timeout: 10 * time.Millisecond,
+	})
+	require.Equal(t, 2, len(queues))
+	require.NoError(t, queues[0].Error)
+	err, ok := queues[1].Error.(*rlogs.TimeLimitError)
+	require.True(t, ok)
+	assert.Equal(t, 10*time.Millisecond, err.Limit)
+	assert.Contains(t, err.Error(), "time limit")
+}
+
+type dummyS3ClientSlowHead struct {
+	dummyS3ClientLimit
+}
+
+func (x *dummyS3ClientSlowHead) HeadObjectWithContext(ctx aws.Context, input *s3.HeadObjectInput, opts ...request.Option) (*s3.HeadObjectOutput, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestPipelineTimeoutHeadObject(t *testing.T) {
+	defer rlogs.FixNewS3Client()
+	dummy := &dummyS3ClientSlowHead{dummyS3ClientLimit{data: limitTestData}}
+	rlogs.InjectNewS3Client(dummy)
+
+	queues := runPipeline(rlogs.Pipeline{
+		Ldr:           &rlogs.S3LineLoader{},
+		Psr:           &parser.JSON{Tag: "test", UnixtimeField: rlogs.String("ts")},
+		MaxObjectSize: 1024,
+		Timeout:       10 * time.Millisecond,
+	})
+	require.Equal(t, 1, len(queues))
+	_, ok := queues[0].Error.(*rlogs.TimeLimitError)
+	require.True(t, ok)
+	assert.Equal(t, 0, dummy.gets)
+}
+
+// slowParser takes delay to parse a message.
+type slowParser struct {
+	delay time.Duration
+}
+
+func (x *slowParser) Parse(msg *rlogs.MessageQueue) ([]*rlogs.LogRecord, error) {
+	time.Sleep(x.delay)
+	return []*rlogs.LogRecord{{Tag: "test", Raw: msg.Raw, Src: msg.Src}}, nil
+}
+
+func TestPipelineTimeoutParse(t *testing.T) {
+	defer rlogs.FixNewS3Client()
+	rlogs.InjectNewS3Client(&dummyS3ClientLimit{data: limitTestData})
+
+	queues := runPipeline(rlogs.Pipeline{
+		Ldr:     &rlogs.S3LineLoader{},
+		Psr:     &slowParser{delay: 50 * time.Millisecond},
+		Timeout: 10 * time.Millisecond,
+	})
+	require.Equal(t, 1, len(queues))
+	_, ok := queues[0].Error.(*rlogs.TimeLimitError)
+	require.True(t, ok)
+}
+
+func TestPipelineTimeoutSend(t *testing.T) {
+	defer rlogs.FixNewS3Client()
+	rlogs.InjectNewS3Client(&dummyS3ClientLimit{data: limitTestData})
+
+	pipe := rlogs.Pipeline{
+		Ldr:     &rlogs.S3LineLoader{},
+		Psr:     &parser.JSON{Tag: "test", UnixtimeField: rlogs.String("ts")},
+		Timeout: 10 * time.Millisecond,
+	}
+	ch := make(chan *rlogs.LogQueue)
+	go pipe.Run(&rlogs.AwsS3LogSource{Region: "ap-northeast-1", Bucket: "my-own-bucket", Key: "data.log"}, ch)
+
+	// Consumer does not receive log records until the time limit
+	time.Sleep(50 * time.Millisecond)
+	var queues []*rlogs.LogQueue
+	for q := range ch {
+		queues = append(queues, q)
+	}
+	require.Equal(t, 1, len(queues))
+	_, ok := queues[0].Error.(*rlogs.TimeLimitError)
+	require.True(t, ok)
+}
+
+// closeNotifier notifies Close of body.
+type closeNotifier struct {
+	io.Reader
+	closed chan struct{}
+}
+
+func (x *closeNotifier) Close() error {
+	close(x.closed)
+	return nil
+}
+
+type dummyS3ClientLargeObject struct {
+	rlogs.TestS3ClientBase
+	body *closeNotifier
+}
+
+func (x *dummyS3ClientLargeObject) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	return &s3.GetObjectOutput{Body: x.body}, nil
+}
+
+// chattyLoader sends messages without cancellation.
+type chattyLoader struct {
+	done chan struct{}
+}
+
+func (x *chattyLoader) Load(src rlogs.LogSource) chan *rlogs.MessageQueue {
+	ch := make(chan *rlogs.MessageQueue)
+	go func() {
+		defer close(x.done)
+		defer close(ch)
+		for i := 0; i < 1000; i++ {
+			ch <- &rlogs.MessageQueue{Raw: []byte(fmt.Sprintf(`{"ts":%d}`, 1571460000+i)), Seq: i, Src: src}
+		}
+	}()
+	return ch
+}
+
+func waitGoroutines(t *testing.T, n int) {
+	for i := 0; i < 300 && runtime.NumGoroutine() > n; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.True(t, runtime.NumGoroutine() <= n, "goroutines are left: %d > %d", runtime.NumGoroutine(), n)
+}
+
+func TestPipelineLimitNoGoroutineLeak(t *testing.T) {
+	defer rlogs.FixNewS3Client()
+
+	var lines strings.Builder
+	for i := 0; i < 1000; i++ {
+		lines.WriteString(fmt.Sprintf(`{"ts":%d,"color":"blue"}`+"\n", 1571460000+i))
+	}
+	base := runtime.NumGoroutine()
+
+	body := &closeNotifier{Reader: strings.NewReader(lines.String()), closed: make(chan struct{})}
+	rlogs.InjectNewS3Client(&dummyS3ClientLargeObject{body: body})
+
+	queues := runPipeline(rlogs.Pipeline{
+		Ldr:        &rlogs.S3LineLoader{},
+		Psr:        &parser.JSON{Tag: "test", UnixtimeField: rlogs.String("ts")},
+		MaxRecords: 1,
+	})
+	require.Equal(t, 2, len(queues))
+	_, ok := queues[1].Error.(*rlogs.RecordLimitError)
+	require.True(t, ok)
+
+	// Loader is cancelled and closes body of the object
+	select {
+	case <-body.closed:
+	case <-time.After(3 * time.Second):
+		require.Fail(t, "body of the object is not closed")
+	}
+	waitGoroutines(t, base)
+
+	// Messages of Loader without LoadContext are discarded until the end
+	ldr := &chattyLoader{done: make(chan struct{})}
+	queues = runPipeline(rlogs.Pipeline{
+		Ldr:        ldr,
+		Psr:        &parser.JSON{Tag: "test", UnixtimeField: rlogs.String("ts")},
+		MaxRecords: 1,
+	})
+	require.Equal(t, 2, len(queues))
+
+	select {
+	case <-ldr.done:
+	case <-time.After(3 * time.Second):
+		require.Fail(t, "loader is blocked")
+	}
+	waitGoroutines(t, base)
+}
@@ -3,50 +3,124 @@ package rlogs
 import (
 	"bufio"
 	"compress/gzip"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/pkg/errors"
 )
 
-func getObjectReader(src LogSource) (io.ReadCloser, error) {
+func getObjectReader(ctx context.Context, src LogSource, dl s3Download) (io.ReadCloser, error) {
 	s3src, ok := src.(*AwsS3LogSource)
 	if !ok {
 		return nil, fmt.Errorf("S3LineLoader accepts only AwsS3LogSource: %v", src)
 	}
 
 	s3client := NewS3Client(s3src.Region)
-	resp, err := s3client.GetObject(&s3.GetObjectInput{
+	input := &s3.GetObjectInput{
 		Bucket: aws.String(s3src.Bucket),
 		Key:    aws.String(s3src.Key),
-	})
+	}
+	if dl.Concurrency > 1 {
+		// The first part is downloaded to get size of the object
+		input.Range = aws.String(fmt.Sprintf("bytes=0-%d", dl.partSize()-1))
+	}
+	if dl.VerifyChecksum {
+		input.ChecksumMode = aws.String(s3.ChecksumModeEnabled)
+	}
 
+	ctx, cancel := context.WithCancel(ctx)
+
+	resp, err := getObject(ctx, s3client, input)
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "InvalidRange" {
+		// Range request to an empty object fails
+		input.Range = nil
+		resp, err = getObject(ctx, s3client, input)
+	}
 	if err != nil {
+		cancel()
 		return nil, errors.Wrap(err, "Fail to get object")
 	}
 
+	var body io.ReadCloser = &cancelReadCloser{
+		ReadCloser: newS3ObjectReader(ctx, s3client, s3src, resp, dl.S3Options),
+		cancel:     cancel,
+	}
+	if input.Range != nil {
+		size, err := parseContentRangeSize(resp.ContentRange)
+		if err != nil {
+			resp.Body.Close()
+			cancel()
+			return nil, err
+		}
+		body = newS3ParallelReader(ctx, cancel, s3client, s3src, resp, size, dl)
+	}
+	if dl.VerifyChecksum {
+		body = newS3ChecksumReader(s3src, resp, body)
+	}
+
 	var r io.ReadCloser
 	if resp.ContentType == nil {
-		r = resp.Body
+		r = body
 	} else if *resp.ContentType == "application/x-gzip" ||
 		(*resp.ContentType == "binary/octet-stream" &&
 			strings.HasSuffix(s3src.Key, ".gz")) {
-		gr, err := gzip.NewReader(resp.Body)
+		gr, err := gzip.NewReader(body)
 		if err != nil {
+			body.Close()
 			return nil, errors.Wrap(err, "Fail to create a new gzip reader")
 		}
-		r = gr
+		r = &gzipReadCloser{Reader: gr, body: body}
 	} else {
-		r = resp.Body
+		r = body
 	}
 
 	return r, nil
 }
 
+// cancelReadCloser cancels context of requests for the object when closed.
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (x *cancelReadCloser) Close() error {
+	x.cancel()
+	return x.ReadCloser.Close()
+}
+
+// gzipReadCloser closes both of gzip reader and body of the object because
+// gzip.Reader does not close the underlying reader.
+type gzipReadCloser struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (x *gzipReadCloser) Close() error {
+	gzErr := x.Reader.Close()
+	if err := x.body.Close(); err != nil {
+		return err
+	}
+	return gzErr
+}
+
+// sendMessage sends msg to chMsg unless ctx is done. It returns false if ctx
+// is done and the loader should stop reading.
+func sendMessage(ctx context.Context, chMsg chan *MessageQueue, msg *MessageQueue) bool {
+	select {
+	case chMsg <- msg:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
 const (
 	defaultS3LineLoaderScanBufferSize  = 1 * 1024 * 1024   // 1 MB
 	defaultS3LineLoaderScanBufferLimit = 128 * 1024 * 1024 // 128 MB
@@ -54,35 +128,44 @@ const (
 
 // S3LineLoader is for line delimitered log file on AWS S3
 type S3LineLoader struct {
+	S3Options
 	ScanBufferSize  int
 	ScanBufferLimit int
+
+	// Concurrency is number of parts of the object that are downloaded
+	// concurrently with range requests. Parallel download is enabled if more
+	// than 1. Parts up to Concurrency are kept on memory.
+	Concurrency int
+	// PartSize is size of a part for parallel download. 8 MB is used if zero.
+	PartSize int64
 }
 
 // Load of S3LineLoader reads a log object line by line
 func (x *S3LineLoader) Load(src LogSource) chan *MessageQueue {
+	return x.LoadContext(context.Background(), src)
+}
+
+// LoadContext of S3LineLoader is Load that stops reading the object when ctx is done
+func (x *S3LineLoader) LoadContext(ctx context.Context, src LogSource) chan *MessageQueue {
+	dl := s3Download{S3Options: x.S3Options, PartSize: x.PartSize, Concurrency: x.Concurrency}
+	return scanObject(ctx, src, bufio.ScanLines, x.ScanBufferSize, x.ScanBufferLimit, dl)
+}
+
+// scanObject reads a log object and splits it to log messages by split function.
+func scanObject(ctx context.Context, src LogSource, split bufio.SplitFunc, scanBufferSize, scanBufferLimit int, dl s3Download) chan *MessageQueue {
 	chMsg := make(chan *MessageQueue)
 
 	go func() {
 		defer close(chMsg)
 
-		r, err := getObjectReader(src)
+		r, err := getObjectReader(ctx, src, dl)
 		if err != nil {
-			chMsg <- &MessageQueue{Error: err}
+			sendMessage(ctx, chMsg, &MessageQueue{Error: err})
 			return
 		}
 		defer r.Close()
 
-		scanner := bufio.NewScanner(r)
-
-		var bufSize int = defaultS3LineLoaderScanBufferSize
-		if x.ScanBufferSize > 0 {
-			bufSize = x.ScanBufferSize
-		}
-		var bufLimit int = defaultS3LineLoaderScanBufferLimit
-		if x.ScanBufferLimit > 0 {
-			bufLimit = x.ScanBufferLimit
-		}
-		scanner.Buffer(make([]byte, bufSize), bufLimit)
+		scanner := newObjectScanner(r, split, scanBufferSize, scanBufferLimit)
 
 		seq := 0
 		for scanner.Scan() {
@@ -90,17 +173,19 @@ func (x *S3LineLoader) Load(src LogSource) chan *MessageQueue {
 			data := make([]byte, len(line))
 			copy(data, line)
 
-			chMsg <- &MessageQueue{
+			if !sendMessage(ctx, chMsg, &MessageQueue{
 				Raw: data,
 				Seq: seq,
 				Src: src,
+			}) {
+				return
 			}
 
 			seq++
 		}
 
 		if err := scanner.Err(); err != nil {
-			chMsg <- &MessageQueue{Error: err}
+			sendMessage(ctx, chMsg, &MessageQueue{Error: err})
 			return
 		}
 	}()
@@ -108,34 +193,134 @@ func (x *S3LineLoader) Load(src LogSource) chan *MessageQueue {
 	return chMsg
 }
 
+// newObjectScanner returns scanner with split function and buffer size. Default
+// values of S3LineLoader are used if scanBufferSize or scanBufferLimit is zero.
+func newObjectScanner(r io.Reader, split bufio.SplitFunc, scanBufferSize, scanBufferLimit int) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(split)
+
+	var bufSize int = defaultS3LineLoaderScanBufferSize
+	if scanBufferSize > 0 {
+		bufSize = scanBufferSize
+	}
+	var bufLimit int = defaultS3LineLoaderScanBufferLimit
+	if scanBufferLimit > 0 {
+		bufLimit = scanBufferLimit
+	}
+	scanner.Buffer(make([]byte, bufSize), bufLimit)
+
+	return scanner
+}
+
+// S3CSVLoader is for CSV/TSV file on AWS S3. A quoted field of RFC 4180 can have
+// line breaks, then a log message is split by line break out of quoted field.
+type S3CSVLoader struct {
+	S3Options
+	ScanBufferSize  int
+	ScanBufferLimit int
+	// Quote is quote character of field. '"' is used if zero. It must be single
+	// byte character and same as Quote of parser.CSV.
+	Quote rune
+}
+
+// Load of S3CSVLoader reads a log object record by record
+func (x *S3CSVLoader) Load(src LogSource) chan *MessageQueue {
+	return x.LoadContext(context.Background(), src)
+}
+
+// LoadContext of S3CSVLoader is Load that stops reading the object when ctx is done
+func (x *S3CSVLoader) LoadContext(ctx context.Context, src LogSource) chan *MessageQueue {
+	quote := x.Quote
+	if quote == 0 {
+		quote = '"'
+	}
+	if quote >= utf8.RuneSelf {
+		chMsg := make(chan *MessageQueue, 1)
+		chMsg <- &MessageQueue{Error: fmt.Errorf("Quote of S3CSVLoader must be single byte character: %q", quote)}
+		close(chMsg)
+		return chMsg
+	}
+
+	return scanObject(ctx, src, newScanCSVRecords(byte(quote)), x.ScanBufferSize, x.ScanBufferLimit, s3Download{S3Options: x.S3Options})
+}
+
+// newScanCSVRecords returns split function that splits data by line break that is
+// not in quoted field. A trailing CR of the record is dropped as bufio.ScanLines.
+// Position and quote state of scanning are kept while requesting more data to
+// avoid scanning a long quoted field again.
+func newScanCSVRecords(quote byte) bufio.SplitFunc {
+	pos, quoted := 0, false
+
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+
+		for ; pos < len(data); pos++ {
+			switch c := data[pos]; {
+			case c == quote:
+				quoted = !quoted // "" in quoted field toggles twice
+			case c == '\n' && !quoted:
+				i := pos
+				pos, quoted = 0, false
+				return i + 1, dropCR(data[:i]), nil
+			}
+		}
+
+		if atEOF {
+			if quoted {
+				return 0, nil, fmt.Errorf("Unterminated quoted field in CSV record")
+			}
+			pos = 0
+			return len(data), dropCR(data), nil
+		}
+
+		return 0, nil, nil // Request more data
+	}
+}
+
+func dropCR(data []byte) []byte {
+	if len(data) > 0 && data[len(data)-1] == '\r' {
+		return data[:len(data)-1]
+	}
+	return data
+}
+
 // S3FileLoader is for whole file data (not line delimitered) on AWS S3
-type S3FileLoader struct{}
+type S3FileLoader struct {
+	S3Options
+}
 
 // Load of S3LineLoader reads a log object as one log message
 func (x *S3FileLoader) Load(src LogSource) chan *MessageQueue {
+	return x.LoadContext(context.Background(), src)
+}
+
+// LoadContext of S3FileLoader is Load that stops reading the object when ctx is done
+func (x *S3FileLoader) LoadContext(ctx context.Context, src LogSource) chan *MessageQueue {
 	chMsg := make(chan *MessageQueue)
 
 	go func() {
 		defer close(chMsg)
 
-		r, err := getObjectReader(src)
+		r, err := getObjectReader(ctx, src, s3Download{S3Options: x.S3Options})
 		if err != nil {
-			chMsg <- &MessageQueue{Error: err}
+			sendMessage(ctx, chMsg, &MessageQueue{Error: err})
 			return
 		}
 		defer r.Close()
 
 		raw, err := ioutil.ReadAll(r)
 		if err != nil {
-			chMsg <- &MessageQueue{Error: errors.Wrap(err, "Fail to read S3 object data")}
+			sendMessage(ctx, chMsg, &MessageQueue{Error: errors.Wrap(err, "Fail to read S3 object data")})
 			return
 		}
 
-		chMsg <- &MessageQueue{
+		sendMessage(ctx, chMsg, &MessageQueue{
 			Raw: raw,
 			Seq: 0,
 			Src: src,
-		}
+		})
 	}()
 
 	return chMsg
@@ -5,14 +5,18 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"strings"
 	"testing"
 
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/m-mizutani/rlogs"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-type dummyS3ClientForS3Loader struct{}
+type dummyS3ClientForS3Loader struct {
+	rlogs.TestS3ClientBase
+}
 
 func toReadCloser(msg string) io.ReadCloser {
 	return ioutil.NopCloser(bytes.NewReader([]byte(msg)))
@@ -29,6 +33,26 @@ func (x *dummyS3ClientForS3Loader) GetObject(input *s3.GetObjectInput) (*s3.GetO
 			Body: toReadCloser("blue\norange\nred\n"),
 		}, nil
 
+	case "my/log/data.csv":
+		return &s3.GetObjectOutput{
+			Body: toReadCloser("color,note\r\nblue,\"sky\r\nand sea\"\r\nred,\"\"\"apple\"\"\"\n"),
+		}, nil
+
+	case "my/log/stacktrace.log":
+		return &s3.GetObjectOutput{
+			Body: toReadCloser("2019-10-19 04:44:44 INFO start\n" +
+				"2019-10-19 04:44:45 ERROR failed\r\n" +
+				"java.lang.NullPointerException\r\n" +
+				"\tat com.example.Foo.bar(Foo.java:10)\r\n" +
+				"\tat com.example.Main.main(Main.java:5)\r\n" +
+				"2019-10-19 04:44:46 INFO done"),
+		}, nil
+
+	case "my/log/pretty.json":
+		return &s3.GetObjectOutput{
+			Body: toReadCloser("{\n  \"color\": \"blue\"\n}\n{\"color\":\n\"red\"} {\"color\": \"orange\"}\n"),
+		}, nil
+
 	default:
 		return nil, fmt.Errorf("Key not found")
 	}
@@ -103,3 +127,151 @@ func TestS3FileLoaderBasic(t *testing.T) {
 	assert.NoError(t, messages[0].Error)
 	assert.Equal(t, "blue\norange\nred\n", string(messages[0].Raw))
 }
+
+func TestS3CSVLoaderQuotedLineBreak(t *testing.T) {
+	dummy := dummyS3ClientForS3Loader{}
+	rlogs.InjectNewS3Client(&dummy)
+	defer rlogs.FixNewS3Client()
+
+	ldr := rlogs.S3CSVLoader{}
+
+	var messages []*rlogs.MessageQueue
+	for msg := range ldr.Load(&rlogs.AwsS3LogSource{
+		Region: "ap-northeast-1",
+		Bucket: "my-own-bucket",
+		Key:    "my/log/data.csv",
+	}) {
+		messages = append(messages, msg)
+	}
+
+	require.Equal(t, 3, len(messages))
+	assert.NoError(t, messages[0].Error)
+	assert.Equal(t, "color,note", string(messages[0].Raw))
+	assert.Equal(t, "blue,\"sky\r\nand sea\"", string(messages[1].Raw))
+	assert.Equal(t, 1, messages[1].Seq)
+	assert.Equal(t, `red,"""apple"""`, string(messages[2].Raw))
+	assert.Equal(t, 2, messages[2].Seq)
+}
+
+func loadMessages(ldr rlogs.Loader, key string) []*rlogs.MessageQueue {
+	var messages []*rlogs.MessageQueue
+	for msg := range ldr.Load(&rlogs.AwsS3LogSource{
+		Region: "ap-northeast-1",
+		Bucket: "my-own-bucket",
+		Key:    key,
+	}) {
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+func TestS3MultilineLoaderStartPattern(t *testing.T) {
+	dummy := dummyS3ClientForS3Loader{}
+	rlogs.InjectNewS3Client(&dummy)
+	defer rlogs.FixNewS3Client()
+
+	messages := loadMessages(&rlogs.S3MultilineLoader{
+		StartPattern: `^\d{4}-\d{2}-\d{2} `,
+	}, "my/log/stacktrace.log")
+
+	require.Equal(t, 3, len(messages))
+	assert.NoError(t, messages[0].Error)
+	assert.Equal(t, "2019-10-19 04:44:44 INFO start", string(messages[0].Raw))
+	assert.Equal(t, "2019-10-19 04:44:45 ERROR failed\r\n"+
+		"java.lang.NullPointerException\r\n"+
+		"\tat com.example.Foo.bar(Foo.java:10)\r\n"+
+		"\tat com.example.Main.main(Main.java:5)", string(messages[1].Raw))
+	assert.Equal(t, 1, messages[1].Seq)
+	assert.Equal(t, "2019-10-19 04:44:46 INFO done", string(messages[2].Raw))
+	assert.Equal(t, 2, messages[2].Seq)
+}
+
+func TestS3MultilineLoaderContinuePattern(t *testing.T) {
+	dummy := dummyS3ClientForS3Loader{}
+	rlogs.InjectNewS3Client(&dummy)
+	defer rlogs.FixNewS3Client()
+
+	messages := loadMessages(&rlogs.S3MultilineLoader{
+		ContinuePattern: `^(\s|java\.)`,
+		ScanBufferSize:  8, // Event is longer than initial buffer
+	}, "my/log/stacktrace.log")
+
+	require.Equal(t, 3, len(messages))
+	assert.NoError(t, messages[1].Error)
+	assert.Contains(t, string(messages[1].Raw), "Main.java:5)")
+}
+
+func TestS3MultilineLoaderLimits(t *testing.T) {
+	dummy := dummyS3ClientForS3Loader{}
+	rlogs.InjectNewS3Client(&dummy)
+	defer rlogs.FixNewS3Client()
+
+	messages := loadMessages(&rlogs.S3MultilineLoader{
+		StartPattern: `^\d{4}-\d{2}-\d{2} `,
+		MaxLines:     2,
+	}, "my/log/stacktrace.log")
+
+	require.Equal(t, 4, len(messages))
+	assert.Equal(t, "2019-10-19 04:44:45 ERROR failed\r\njava.lang.NullPointerException", string(messages[1].Raw))
+	assert.Equal(t, "\tat com.example.Foo.bar(Foo.java:10)\r\n\tat com.example.Main.main(Main.java:5)", string(messages[2].Raw))
+
+	messages = loadMessages(&rlogs.S3MultilineLoader{
+		StartPattern: `^\d{4}-\d{2}-\d{2} `,
+		MaxBytes:     40,
+	}, "my/log/stacktrace.log")
+
+	require.Equal(t, 6, len(messages))
+	for _, msg := range messages {
+		assert.NoError(t, msg.Error)
+		assert.True(t, len(msg.Raw) <= 40)
+	}
+}
+
+func TestS3MultilineLoaderJSON(t *testing.T) {
+	dummy := dummyS3ClientForS3Loader{}
+	rlogs.InjectNewS3Client(&dummy)
+	defer rlogs.FixNewS3Client()
+
+	messages := loadMessages(&rlogs.S3MultilineLoader{JSON: true}, "my/log/pretty.json")
+
+	require.Equal(t, 3, len(messages))
+	assert.JSONEq(t, `{"color":"blue"}`, string(messages[0].Raw))
+	assert.JSONEq(t, `{"color":"red"}`, string(messages[1].Raw))
+	assert.JSONEq(t, `{"color":"orange"}`, string(messages[2].Raw))
+	assert.Equal(t, 2, messages[2].Seq)
+
+	messages = loadMessages(&rlogs.S3MultilineLoader{JSON: true}, "my/log/data.csv")
+	require.Equal(t, 1, len(messages))
+	assert.Error(t, messages[0].Error)
+}
+
+func TestS3MultilineLoaderErrorCase(t *testing.T) {
+	messages := loadMessages(&rlogs.S3MultilineLoader{}, "my/log/stacktrace.log")
+	require.Equal(t, 1, len(messages))
+	assert.Contains(t, messages[0].Error.Error(), "Either StartPattern or ContinuePattern is required")
+
+	messages = loadMessages(&rlogs.S3MultilineLoader{StartPattern: "("}, "my/log/stacktrace.log")
+	require.Equal(t, 1, len(messages))
+	assert.Contains(t, messages[0].Error.Error(), "Fail to compile StartPattern")
+}
+
+func TestS3CSVLoaderLongQuotedField(t *testing.T) {
+	rlogs.InjectNewS3Client(&dummyS3ClientData{data: []byte("a,\"" + strings.Repeat("x\n", 1000) + "\"\nb,c\n")})
+	defer rlogs.FixNewS3Client()
+
+	messages := loadMessages(&rlogs.S3CSVLoader{ScanBufferSize: 16}, "data.csv")
+	require.Equal(t, 2, len(messages))
+	require.NoError(t, messages[0].Error)
+	assert.Equal(t, 2004, len(messages[0].Raw))
+	assert.Equal(t, "b,c", string(messages[1].Raw))
+}
+
+func TestS3CSVLoaderMultiByteQuote(t *testing.T) {
+	rlogs.InjectNewS3Client(&dummyS3ClientData{data: []byte("a,b\n")})
+	defer rlogs.FixNewS3Client()
+
+	messages := loadMessages(&rlogs.S3CSVLoader{Quote: '「'}, "data.csv")
+	require.Equal(t, 1, len(messages))
+	require.Error(t, messages[0].Error)
+	assert.Contains(t, messages[0].Error.Error(), "single byte")
+}
@@ -0,0 +1,169 @@
+package rlogs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+// S3MultilineLoader is for log file on AWS S3 that has multi-line events such as
+// Java stack trace. A line starts a new event if it matches StartPattern or
+// does not match ContinuePattern, otherwise the line is appended to the current
+// event. Lines of an event are joined with original line breaks.
+//
+// If JSON is true, the loader reads consecutive JSON values (e.g. pretty-printed
+// JSON objects) regardless of line breaks instead of matching patterns.
+type S3MultilineLoader struct {
+	S3Options
+	ScanBufferSize  int
+	ScanBufferLimit int
+
+	// StartPattern is regular expression of the first line of an event, e.g. `^\d{4}-\d{2}-\d{2} `
+	StartPattern string
+	// ContinuePattern is regular expression of a line following the first line, e.g. `^\s+at `
+	ContinuePattern string
+	// MaxLines is max number of lines in an event. An event is split if it exceeds. No limit if 0.
+	MaxLines int
+	// MaxBytes is max bytes of an event. An event is split at line break if it
+	// exceeds, but one line longer than MaxBytes is not split. No limit if 0.
+	MaxBytes int
+
+	// JSON enables JSON-aware mode. Patterns, MaxLines and MaxBytes are ignored.
+	JSON bool
+}
+
+// Load of S3MultilineLoader reads a log object event by event
+func (x *S3MultilineLoader) Load(src LogSource) chan *MessageQueue {
+	return x.LoadContext(context.Background(), src)
+}
+
+// LoadContext of S3MultilineLoader is Load that stops reading the object when ctx is done
+func (x *S3MultilineLoader) LoadContext(ctx context.Context, src LogSource) chan *MessageQueue {
+	if x.JSON {
+		return decodeJSONObject(ctx, src, s3Download{S3Options: x.S3Options})
+	}
+
+	isStart, err := x.startFunc()
+	if err != nil {
+		chMsg := make(chan *MessageQueue, 1)
+		chMsg <- &MessageQueue{Error: err}
+		close(chMsg)
+		return chMsg
+	}
+
+	return scanObject(ctx, src, newScanMultilineEvents(isStart, x.MaxLines, x.MaxBytes), x.ScanBufferSize, x.ScanBufferLimit, s3Download{S3Options: x.S3Options})
+}
+
+func (x *S3MultilineLoader) startFunc() (func(line []byte) bool, error) {
+	if x.StartPattern == "" && x.ContinuePattern == "" {
+		return nil, fmt.Errorf("Either StartPattern or ContinuePattern is required for S3MultilineLoader")
+	}
+
+	var start, cont *regexp.Regexp
+	var err error
+	if x.StartPattern != "" {
+		if start, err = regexp.Compile(x.StartPattern); err != nil {
+			return nil, errors.Wrapf(err, "Fail to compile StartPattern: %s", x.StartPattern)
+		}
+	}
+	if x.ContinuePattern != "" {
+		if cont, err = regexp.Compile(x.ContinuePattern); err != nil {
+			return nil, errors.Wrapf(err, "Fail to compile ContinuePattern: %s", x.ContinuePattern)
+		}
+	}
+
+	return func(line []byte) bool {
+		return (start != nil && start.Match(line)) || (cont != nil && !cont.Match(line))
+	}, nil
+}
+
+// newScanMultilineEvents returns split function that splits data to events. A
+// line that isStart returns true, or exceeding maxLines or maxBytes, begins a
+// new event. The event is determined when the next event begins or at EOF
+// because a following line may continue the event.
+func newScanMultilineEvents(isStart func(line []byte) bool, maxLines, maxBytes int) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+
+		pos, lines := 0, 0
+		for {
+			var line []byte
+			var next int
+			if i := bytes.IndexByte(data[pos:], '\n'); i >= 0 {
+				line, next = dropCR(data[pos:pos+i]), pos+i+1
+			} else if atEOF {
+				line, next = dropCR(data[pos:]), len(data)
+			} else {
+				return 0, nil, nil // Request more data
+			}
+
+			if lines > 0 && (isStart(line) ||
+				(maxLines > 0 && lines >= maxLines) ||
+				(maxBytes > 0 && next > maxBytes)) {
+				return pos, dropLineBreak(data[:pos]), nil
+			}
+
+			lines++
+			pos = next
+			if pos == len(data) {
+				if atEOF {
+					return pos, dropLineBreak(data), nil
+				}
+				return 0, nil, nil // Next line may continue the event
+			}
+		}
+	}
+}
+
+func dropLineBreak(data []byte) []byte {
+	if len(data) > 0 && data[len(data)-1] == '\n' {
+		data = data[:len(data)-1]
+	}
+	return dropCR(data)
+}
+
+// decodeJSONObject reads consecutive JSON values from a log object and sends
+// each value as a log message.
+func decodeJSONObject(ctx context.Context, src LogSource, dl s3Download) chan *MessageQueue {
+	chMsg := make(chan *MessageQueue)
+
+	go func() {
+		defer close(chMsg)
+
+		r, err := getObjectReader(ctx, src, dl)
+		if err != nil {
+			sendMessage(ctx, chMsg, &MessageQueue{Error: err})
+			return
+		}
+		defer r.Close()
+
+		decoder := json.NewDecoder(r)
+		for seq := 0; ; seq++ {
+			var raw json.RawMessage
+			if err := decoder.Decode(&raw); err != nil {
+				if err != io.EOF {
+					sendMessage(ctx, chMsg, &MessageQueue{Error: errors.Wrapf(err, "Fail to decode JSON value [%d]", seq)})
+				}
+				return
+			}
+
+			if !sendMessage(ctx, chMsg, &MessageQueue{
+				Raw: raw,
+				Seq: seq,
+				Src: src,
+			}) {
+				return
+			}
+		}
+	}()
+
+	return chMsg
+}
@@ -0,0 +1,572 @@
+package rlogs
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	"github.com/pkg/errors"
+)
+
+// orcTailSize is size of the first range request that reads postscript and
+// footer at tail of ORC object. The footer is fetched again if it's larger.
+const orcTailSize = 16 * 1024
+
+// S3ORCLoader is for Apache ORC file on AWS S3, e.g. Hive table and Athena CTAS
+// output. The loader reads tail of the object (postscript and footer) and each
+// stripe with range requests instead of downloading whole object, then a stripe
+// is kept on memory while reading it. One row is converted to one MessageQueue
+// and Raw of the MessageQueue is the row encoded as JSON object by field names.
+// Timestamp is converted to RFC3339 string as UTC (ORC timestamp is wall clock
+// time), date is converted to "2006-01-02" string, decimal is encoded as number
+// and binary is encoded as base64 string. A value of union is unwrapped. NONE,
+// ZLIB, SNAPPY, LZ4 and ZSTD compression are supported, but LZO is not.
+//
+// S3ORCLoader does not have S3Options for the same reason as S3ParquetLoader.
+type S3ORCLoader struct{}
+
+// Load of S3ORCLoader reads an ORC object row by row
+func (x *S3ORCLoader) Load(src LogSource) chan *MessageQueue {
+	return x.LoadContext(context.Background(), src)
+}
+
+// LoadContext of S3ORCLoader is Load that stops reading the object when ctx is done
+func (x *S3ORCLoader) LoadContext(ctx context.Context, src LogSource) chan *MessageQueue {
+	chMsg := make(chan *MessageQueue)
+
+	go func() {
+		defer close(chMsg)
+
+		s3src, ok := src.(*AwsS3LogSource)
+		if !ok {
+			sendMessage(ctx, chMsg, &MessageQueue{Error: fmt.Errorf("S3ORCLoader accepts only AwsS3LogSource: %v", src)})
+			return
+		}
+
+		f, err := openS3ORCFile(ctx, s3src)
+		if err != nil {
+			sendMessage(ctx, chMsg, &MessageQueue{Error: err})
+			return
+		}
+		defer f.codec.close()
+
+		seq := 0
+		for i, stripe := range f.stripes {
+			root, err := f.readStripe(stripe)
+			if err != nil {
+				sendMessage(ctx, chMsg, &MessageQueue{Error: errors.Wrapf(err, "Fail to read ORC stripe [%d]", i)})
+				return
+			}
+
+			for n := uint64(0); n < stripe.numberOfRows; n++ {
+				row, err := root()
+				if err != nil {
+					sendMessage(ctx, chMsg, &MessageQueue{Error: errors.Wrapf(err, "Fail to read ORC row [%d]", seq)})
+					return
+				}
+
+				raw, err := json.Marshal(row)
+				if err != nil {
+					sendMessage(ctx, chMsg, &MessageQueue{Error: errors.Wrapf(err, "Fail to encode ORC row [%d]", seq)})
+					return
+				}
+
+				if !sendMessage(ctx, chMsg, &MessageQueue{
+					Raw: raw,
+					Seq: seq,
+					Src: src,
+				}) {
+					return
+				}
+				seq++
+			}
+		}
+	}()
+
+	return chMsg
+}
+
+// Kinds of compression in PostScript
+const (
+	orcCompressionNone   = 0
+	orcCompressionZlib   = 1
+	orcCompressionSnappy = 2
+	orcCompressionLZO    = 3
+	orcCompressionLZ4    = 4
+	orcCompressionZstd   = 5
+)
+
+const defaultORCCompressionBlockSize = 256 * 1024
+
+// orcStripe is StripeInformation in ORC footer.
+type orcStripe struct {
+	offset       uint64
+	indexLength  uint64
+	dataLength   uint64
+	footerLength uint64
+	numberOfRows uint64
+}
+
+// orcType is Type in ORC footer. Column ID is index of the type in footer.
+type orcType struct {
+	kind       uint64
+	subtypes   []int
+	fieldNames []string
+}
+
+// s3ORCFile is ORC object on S3 that has been read postscript and footer.
+type s3ORCFile struct {
+	ctx     context.Context
+	client  s3Client
+	src     *AwsS3LogSource
+	size    int64
+	codec   *orcCodec
+	types   []orcType
+	stripes []orcStripe
+}
+
+func openS3ORCFile(ctx context.Context, src *AwsS3LogSource) (*s3ORCFile, error) {
+	f := &s3ORCFile{
+		ctx:    ctx,
+		client: NewS3Client(src.Region),
+		src:    src,
+	}
+
+	// The last byte is length of postscript and footer is before postscript
+	tail, contentRange, err := getObjectRange(ctx, f.client, src, fmt.Sprintf("bytes=-%d", orcTailSize))
+	if err != nil {
+		return nil, err
+	}
+	if f.size, err = parseContentRangeSize(contentRange); err != nil {
+		return nil, err
+	}
+	if len(tail) < 4 || int(tail[len(tail)-1])+1 > len(tail) {
+		return nil, fmt.Errorf("Not ORC object (no postscript): %s", src.Key)
+	}
+
+	psLen := int(tail[len(tail)-1])
+	ps, err := parseORCPostScript(tail[len(tail)-1-psLen : len(tail)-1])
+	if err != nil {
+		return nil, errors.Wrapf(err, "Not ORC object: %s", src.Key)
+	}
+	f.codec = &orcCodec{kind: ps.compression, blockSize: ps.compressionBlockSize}
+
+	footerEnd := uint64(len(tail) - 1 - psLen)
+	var footer []byte
+	if ps.footerLength <= footerEnd {
+		footer = tail[footerEnd-ps.footerLength : footerEnd]
+	} else {
+		end := uint64(f.size) - 1 - uint64(psLen)
+		if ps.footerLength+3 > end {
+			return nil, fmt.Errorf("Invalid ORC footer length %d: %s", ps.footerLength, src.Key)
+		}
+		begin := end - ps.footerLength
+		if footer, _, err = getObjectRange(ctx, f.client, src, fmt.Sprintf("bytes=%d-%d", begin, end-1)); err != nil {
+			return nil, err
+		}
+		if uint64(len(footer)) != ps.footerLength {
+			return nil, fmt.Errorf("Fail to read ORC footer (%d bytes): %s", len(footer), src.Key)
+		}
+	}
+
+	if footer, err = f.codec.decompress(footer); err != nil {
+		return nil, errors.Wrap(err, "Fail to decompress ORC footer")
+	}
+	if err := f.parseFooter(footer); err != nil {
+		return nil, errors.Wrap(err, "Fail to parse ORC footer")
+	}
+
+	return f, nil
+}
+
+// orcPostScript is PostScript of ORC file.
+type orcPostScript struct {
+	footerLength         uint64
+	compression          uint64
+	compressionBlockSize uint64
+}
+
+func parseORCPostScript(msg []byte) (*orcPostScript, error) {
+	fields, err := parseORCProto(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	var ps orcPostScript
+	var magic string
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			ps.footerLength = f.value
+		case 2:
+			ps.compression = f.value
+		case 3:
+			ps.compressionBlockSize = f.value
+		case 8000:
+			magic = string(f.data)
+		}
+	}
+
+	if magic != "ORC" {
+		return nil, fmt.Errorf("No magic number in ORC postscript")
+	}
+	return &ps, nil
+}
+
+func (x *s3ORCFile) parseFooter(msg []byte) error {
+	fields, err := parseORCProto(msg)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range fields {
+		switch f.num {
+		case 3: // stripes
+			sf, err := parseORCProto(f.data)
+			if err != nil {
+				return err
+			}
+			var stripe orcStripe
+			for _, s := range sf {
+				switch s.num {
+				case 1:
+					stripe.offset = s.value
+				case 2:
+					stripe.indexLength = s.value
+				case 3:
+					stripe.dataLength = s.value
+				case 4:
+					stripe.footerLength = s.value
+				case 5:
+					stripe.numberOfRows = s.value
+				}
+			}
+			x.stripes = append(x.stripes, stripe)
+
+		case 4: // types
+			tf, err := parseORCProto(f.data)
+			if err != nil {
+				return err
+			}
+			var t orcType
+			for _, tv := range tf {
+				switch tv.num {
+				case 1:
+					t.kind = tv.value
+				case 2:
+					subtypes, err := tv.varints()
+					if err != nil {
+						return err
+					}
+					for _, s := range subtypes {
+						t.subtypes = append(t.subtypes, int(s))
+					}
+				case 3:
+					t.fieldNames = append(t.fieldNames, string(tv.data))
+				}
+			}
+			x.types = append(x.types, t)
+		}
+	}
+
+	// Columns are nested in pre-order, then subtype must be after the type
+	for id, t := range x.types {
+		for _, s := range t.subtypes {
+			if s <= id || s >= len(x.types) {
+				return fmt.Errorf("Invalid subtype %d of ORC column %d", s, id)
+			}
+		}
+		if t.kind == orcStruct && len(t.fieldNames) != len(t.subtypes) {
+			return fmt.Errorf("Field names do not match subtypes of ORC column %d", id)
+		}
+	}
+	if len(x.types) == 0 {
+		return fmt.Errorf("No type in ORC footer")
+	}
+
+	return nil
+}
+
+// Kinds of stream in stripe
+const (
+	orcStreamPresent        = 0
+	orcStreamData           = 1
+	orcStreamLength         = 2
+	orcStreamDictionaryData = 3
+	orcStreamSecondary      = 5
+)
+
+// Kinds of column encoding in stripe
+const (
+	orcEncodingDirect       = 0
+	orcEncodingDictionary   = 1
+	orcEncodingDirectV2     = 2
+	orcEncodingDictionaryV2 = 3
+)
+
+type orcStreamKey struct {
+	column int
+	kind   uint64
+}
+
+// orcEncoding is ColumnEncoding in stripe footer.
+type orcEncoding struct {
+	kind           uint64
+	dictionarySize uint64
+}
+
+// orcStripeData has decompressed streams and encodings of columns in a stripe.
+type orcStripeData struct {
+	streams   map[orcStreamKey][]byte
+	encodings []orcEncoding
+}
+
+// readStripe fetches data streams and footer of the stripe by one range request
+// and returns reader of root column. Index streams are not read.
+func (x *s3ORCFile) readStripe(stripe orcStripe) (orcColumn, error) {
+	begin := stripe.offset + stripe.indexLength
+	size := stripe.dataLength + stripe.footerLength
+	if size == 0 || begin+size > uint64(x.size) {
+		return nil, fmt.Errorf("Invalid ORC stripe range: offset %d, size %d", begin, size)
+	}
+
+	data, _, err := getObjectRange(x.ctx, x.client, x.src, fmt.Sprintf("bytes=%d-%d", begin, begin+size-1))
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(data)) != size {
+		return nil, fmt.Errorf("Unexpected size of ORC stripe: %d", len(data))
+	}
+
+	footer, err := x.codec.decompress(data[stripe.dataLength:])
+	if err != nil {
+		return nil, errors.Wrap(err, "Fail to decompress ORC stripe footer")
+	}
+	fields, err := parseORCProto(footer)
+	if err != nil {
+		return nil, errors.Wrap(err, "Fail to parse ORC stripe footer")
+	}
+
+	sd := &orcStripeData{streams: map[orcStreamKey][]byte{}}
+	pos := stripe.offset // Streams are contiguous from the head of stripe
+	for _, f := range fields {
+		switch f.num {
+		case 1: // streams
+			sf, err := parseORCProto(f.data)
+			if err != nil {
+				return nil, errors.Wrap(err, "Fail to parse ORC stream")
+			}
+			var key orcStreamKey
+			var length uint64
+			for _, s := range sf {
+				switch s.num {
+				case 1:
+					key.kind = s.value
+				case 2:
+					key.column = int(s.value)
+				case 3:
+					length = s.value
+				}
+			}
+
+			if pos >= begin {
+				if pos+length > begin+stripe.dataLength {
+					return nil, fmt.Errorf("ORC stream of column %d exceeds stripe", key.column)
+				}
+				stream, err := x.codec.decompress(data[pos-begin : pos-begin+length])
+				if err != nil {
+					return nil, errors.Wrapf(err, "Fail to decompress ORC stream of column %d", key.column)
+				}
+				sd.streams[key] = stream
+			}
+			pos += length
+
+		case 2: // columns
+			ef, err := parseORCProto(f.data)
+			if err != nil {
+				return nil, errors.Wrap(err, "Fail to parse ORC column encoding")
+			}
+			var enc orcEncoding
+			for _, e := range ef {
+				switch e.num {
+				case 1:
+					enc.kind = e.value
+				case 2:
+					enc.dictionarySize = e.value
+				}
+			}
+			sd.encodings = append(sd.encodings, enc)
+		}
+	}
+
+	if len(sd.encodings) < len(x.types) {
+		return nil, fmt.Errorf("Column encodings (%d) are less than ORC columns (%d)", len(sd.encodings), len(x.types))
+	}
+
+	return x.newColumn(0, sd)
+}
+
+// orcCodec decompresses stream of ORC file. A compressed stream consists of
+// chunks that have 3 bytes header.
+type orcCodec struct {
+	kind      uint64
+	blockSize uint64
+	zstd      *zstd.Decoder
+}
+
+func (x *orcCodec) decompress(data []byte) ([]byte, error) {
+	if x.kind == orcCompressionNone {
+		return data, nil
+	}
+
+	var out []byte
+	for pos := 0; pos < len(data); {
+		if len(data)-pos < 3 {
+			return nil, fmt.Errorf("Truncated ORC compression chunk header")
+		}
+		header := int(data[pos]) | int(data[pos+1])<<8 | int(data[pos+2])<<16
+		pos += 3
+
+		length := header >> 1
+		if length > len(data)-pos {
+			return nil, fmt.Errorf("Truncated ORC compression chunk: %d bytes", length)
+		}
+		chunk := data[pos : pos+length]
+		pos += length
+
+		if header&1 == 1 { // Original (not compressed) chunk
+			out = append(out, chunk...)
+			continue
+		}
+
+		buf, err := x.decompressChunk(chunk)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, buf...)
+	}
+
+	return out, nil
+}
+
+func (x *orcCodec) decompressChunk(chunk []byte) ([]byte, error) {
+	switch x.kind {
+	case orcCompressionZlib: // Deflate without zlib header
+		r := flate.NewReader(bytes.NewReader(chunk))
+		defer r.Close()
+		return ioutil.ReadAll(r)
+
+	case orcCompressionSnappy:
+		return snappy.Decode(nil, chunk)
+
+	case orcCompressionLZ4:
+		blockSize := x.blockSize
+		if blockSize == 0 {
+			blockSize = defaultORCCompressionBlockSize
+		}
+		buf := make([]byte, blockSize)
+		n, err := lz4.UncompressBlock(chunk, buf)
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+
+	case orcCompressionZstd:
+		if x.zstd == nil {
+			dec, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+			if err != nil {
+				return nil, err
+			}
+			x.zstd = dec
+		}
+		return x.zstd.DecodeAll(chunk, nil)
+
+	case orcCompressionLZO:
+		return nil, fmt.Errorf("LZO compression of ORC is not supported")
+	}
+
+	return nil, fmt.Errorf("Unknown ORC compression: %d", x.kind)
+}
+
+func (x *orcCodec) close() {
+	if x.zstd != nil {
+		x.zstd.Close()
+	}
+}
+
+// orcProtoField is a field of protobuf message in ORC file. value is set for
+// varint field and data is set for length-delimited field.
+type orcProtoField struct {
+	num   uint64
+	value uint64
+	data  []byte
+}
+
+// varints returns values of packed repeated varint field. A field that is not
+// packed has one value.
+func (x orcProtoField) varints() ([]uint64, error) {
+	if x.data == nil {
+		return []uint64{x.value}, nil
+	}
+
+	var values []uint64
+	for pos := 0; pos < len(x.data); {
+		v, n := binary.Uvarint(x.data[pos:])
+		if n <= 0 {
+			return nil, fmt.Errorf("Invalid protobuf varint")
+		}
+		values = append(values, v)
+		pos += n
+	}
+	return values, nil
+}
+
+// parseORCProto parses fields of protobuf message.
+func parseORCProto(msg []byte) ([]orcProtoField, error) {
+	var fields []orcProtoField
+	for pos := 0; pos < len(msg); {
+		tag, n := binary.Uvarint(msg[pos:])
+		if n <= 0 {
+			return nil, fmt.Errorf("Invalid protobuf tag")
+		}
+		pos += n
+
+		f := orcProtoField{num: tag >> 3}
+		switch tag & 7 {
+		case 0: // varint
+			v, n := binary.Uvarint(msg[pos:])
+			if n <= 0 {
+				return nil, fmt.Errorf("Invalid protobuf varint")
+			}
+			f.value = v
+			pos += n
+		case 1: // 64-bit
+			pos += 8
+		case 2: // length-delimited
+			length, n := binary.Uvarint(msg[pos:])
+			if n <= 0 || length > uint64(len(msg)-pos-n) {
+				return nil, fmt.Errorf("Invalid protobuf length")
+			}
+			f.data = msg[pos+n : pos+n+int(length)]
+			pos += n + int(length)
+		case 5: // 32-bit
+			pos += 4
+		default:
+			return nil, fmt.Errorf("Unsupported protobuf wire type: %d", tag&7)
+		}
+
+		if pos > len(msg) {
+			return nil, fmt.Errorf("Truncated protobuf field")
+		}
+		fields = append(fields, f)
+	}
+
+	return fields, nil
+}
@@ -0,0 +1,573 @@
+package rlogs_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/m-mizutani/rlogs"
+	"github.com/pierrec/lz4/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Kinds of ORC type, stream and compression used by orcTestWriter
+const (
+	orcTestBoolean   = 0
+	orcTestLong      = 4
+	orcTestDouble    = 6
+	orcTestString    = 7
+	orcTestTimestamp = 9
+	orcTestList      = 10
+	orcTestMap       = 11
+	orcTestStruct    = 12
+	orcTestDecimal   = 14
+	orcTestDate      = 15
+
+	orcTestNone   = 0
+	orcTestZlib   = 1
+	orcTestSnappy = 2
+	orcTestLZO    = 3
+	orcTestLZ4    = 4
+	orcTestZstd   = 5
+)
+
+// orcTestColumn has type and values of a column in a stripe.
+type orcTestColumn struct {
+	kind     uint64
+	subtypes []int
+	names    []string
+	dict     []string // Dictionary encoding is used if set
+
+	present []bool
+	hasNull bool
+	bools   []bool
+	ints    []int64 // DATA of integer, date, timestamp and dictionary index
+	lengths []int64
+	second  []int64
+	data    []byte // DATA of string, double and decimal
+}
+
+// orcTestWriter is minimal ORC writer for test. Integers are written by RLE
+// version 1 (literals) or version 2 (DIRECT with 64 bits width).
+type orcTestWriter struct {
+	cols        []*orcTestColumn
+	compression uint64
+	blockSize   int
+	file        []byte
+	stripes     [][]byte
+	rows        uint64
+}
+
+func newORCTestWriter(compression uint64, cols ...*orcTestColumn) *orcTestWriter {
+	return &orcTestWriter{cols: cols, compression: compression, blockSize: 64, file: []byte("ORC")}
+}
+
+func protoUint(field, v uint64) []byte {
+	return append(protoVarint(field<<3), protoVarint(v)...)
+}
+
+func (x *orcTestWriter) add(id int, v interface{}) {
+	col := x.cols[id]
+	col.present = append(col.present, v != nil)
+	if v == nil {
+		col.hasNull = true
+		return
+	}
+
+	switch col.kind {
+	case orcTestBoolean:
+		col.bools = append(col.bools, v.(bool))
+	case orcTestLong:
+		col.ints = append(col.ints, int64(v.(int)))
+	case orcTestDouble:
+		col.data = append(col.data, make([]byte, 8)...)
+		binary.LittleEndian.PutUint64(col.data[len(col.data)-8:], math.Float64bits(v.(float64)))
+	case orcTestString:
+		s := v.(string)
+		if col.dict != nil {
+			for i, d := range col.dict {
+				if d == s {
+					col.ints = append(col.ints, int64(i))
+				}
+			}
+		} else {
+			col.data = append(col.data, s...)
+			col.lengths = append(col.lengths, int64(len(s)))
+		}
+	case orcTestTimestamp:
+		t := v.(time.Time)
+		col.ints = append(col.ints, t.Unix()-1420070400)
+		// Trailing zeros of nanos are encoded in the lowest 3 bits
+		nanos, zeros := int64(t.Nanosecond()), int64(0)
+		if nanos != 0 && nanos%100 == 0 {
+			nanos, zeros = nanos/100, 1
+			for nanos%10 == 0 && zeros < 7 {
+				nanos, zeros = nanos/10, zeros+1
+			}
+		}
+		col.second = append(col.second, nanos<<3|zeros)
+	case orcTestDate:
+		col.ints = append(col.ints, v.(time.Time).Unix()/86400)
+	case orcTestDecimal:
+		// Unscaled value of "123.45" is 12345 with scale 2
+		s := v.(string)
+		scale := 0
+		if i := strings.IndexByte(s, '.'); i >= 0 {
+			scale = len(s) - i - 1
+			s = s[:i] + s[i+1:]
+		}
+		unscaled, _ := new(big.Int).SetString(s, 10)
+		zz := new(big.Int).Lsh(unscaled, 1)
+		if unscaled.Sign() < 0 {
+			zz.Neg(zz).Sub(zz, big.NewInt(1))
+		}
+		for {
+			b := byte(new(big.Int).And(zz, big.NewInt(0x7f)).Int64())
+			zz.Rsh(zz, 7)
+			if zz.Sign() == 0 {
+				col.data = append(col.data, b)
+				break
+			}
+			col.data = append(col.data, b|0x80)
+		}
+		col.second = append(col.second, int64(scale))
+	case orcTestList:
+		items := v.([]interface{})
+		col.lengths = append(col.lengths, int64(len(items)))
+		for _, item := range items {
+			x.add(col.subtypes[0], item)
+		}
+	case orcTestMap:
+		m := v.(map[string]interface{})
+		var keys []string
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		col.lengths = append(col.lengths, int64(len(keys)))
+		for _, k := range keys {
+			x.add(col.subtypes[0], k)
+			x.add(col.subtypes[1], m[k])
+		}
+	case orcTestStruct:
+		m := v.(map[string]interface{})
+		for i, sub := range col.subtypes {
+			x.add(sub, m[col.names[i]])
+		}
+	}
+}
+
+func orcTestBytes(values []byte) []byte {
+	var out []byte
+	for len(values) > 0 {
+		n := len(values)
+		if n > 128 {
+			n = 128
+		}
+		out = append(out, byte(0x100-n))
+		out = append(out, values[:n]...)
+		values = values[n:]
+	}
+	return out
+}
+
+func orcTestBools(values []bool) []byte {
+	data := make([]byte, (len(values)+7)/8)
+	for i, v := range values {
+		if v {
+			data[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+	return orcTestBytes(data)
+}
+
+func orcTestInts(values []int64, v2, signed bool) []byte {
+	var out []byte
+	for len(values) > 0 {
+		n := len(values)
+		if n > 128 {
+			n = 128
+		}
+		if v2 {
+			out = append(out, 1<<6|31<<1, byte(n-1))
+		} else {
+			out = append(out, byte(-n))
+		}
+		for _, v := range values[:n] {
+			u := uint64(v)
+			if signed {
+				u = uint64(v<<1) ^ uint64(v>>63)
+			}
+			if v2 {
+				out = append(out, make([]byte, 8)...)
+				binary.BigEndian.PutUint64(out[len(out)-8:], u)
+			} else {
+				out = append(out, protoVarint(u)...)
+			}
+		}
+		values = values[n:]
+	}
+	return out
+}
+
+func (x *orcTestWriter) compress(data []byte) []byte {
+	if x.compression == orcTestNone {
+		return data
+	}
+
+	var out []byte
+	for len(data) > 0 {
+		n := len(data)
+		if n > x.blockSize {
+			n = x.blockSize
+		}
+		chunk := data[:n]
+		data = data[n:]
+
+		var compressed []byte
+		switch x.compression {
+		case orcTestZlib:
+			var buf bytes.Buffer
+			w, _ := flate.NewWriter(&buf, flate.BestCompression)
+			w.Write(chunk)
+			w.Close()
+			compressed = buf.Bytes()
+		case orcTestSnappy:
+			compressed = snappy.Encode(nil, chunk)
+		case orcTestLZ4:
+			buf := make([]byte, lz4.CompressBlockBound(len(chunk)))
+			size, _ := lz4.CompressBlock(chunk, buf, nil)
+			compressed = buf[:size]
+		case orcTestZstd:
+			enc, _ := zstd.NewWriter(nil)
+			compressed = enc.EncodeAll(chunk, nil)
+			enc.Close()
+		case orcTestLZO:
+			compressed = []byte{0x00}
+		}
+
+		if len(compressed) > 0 && len(compressed) < len(chunk) {
+			header := len(compressed) << 1
+			out = append(out, byte(header), byte(header>>8), byte(header>>16))
+			out = append(out, compressed...)
+		} else {
+			header := len(chunk)<<1 | 1 // Original
+			out = append(out, byte(header), byte(header>>8), byte(header>>16))
+			out = append(out, chunk...)
+		}
+	}
+	return out
+}
+
+// flush writes added rows as a stripe with RLE version 1 or 2. The stripe has
+// a dummy index stream that should be skipped.
+func (x *orcTestWriter) flush(v2 bool) {
+	offset := len(x.file)
+	var streams, encodings []byte
+	var data []byte
+
+	index := x.compress([]byte("dummy row index"))
+	x.file = append(x.file, index...)
+	streams = append(streams, protoBytes(1, append(append(protoUint(1, 6), protoUint(2, 0)...), protoUint(3, uint64(len(index)))...))...)
+
+	addStream := func(column int, kind uint64, raw []byte) {
+		compressed := x.compress(raw)
+		data = append(data, compressed...)
+		streams = append(streams, protoBytes(1, append(append(protoUint(1, kind), protoUint(2, uint64(column))...), protoUint(3, uint64(len(compressed)))...))...)
+	}
+
+	rows := uint64(0)
+	for id, col := range x.cols {
+		if id == 0 {
+			rows = uint64(len(col.present))
+		}
+		if col.hasNull {
+			addStream(id, 0, orcTestBools(col.present))
+		}
+
+		encoding := uint64(0)
+		switch col.kind {
+		case orcTestBoolean:
+			addStream(id, 1, orcTestBools(col.bools))
+		case orcTestLong, orcTestDate:
+			addStream(id, 1, orcTestInts(col.ints, v2, true))
+		case orcTestDouble:
+			addStream(id, 1, col.data)
+		case orcTestString:
+			if col.dict != nil {
+				var dict []byte
+				var lengths []int64
+				for _, d := range col.dict {
+					dict = append(dict, d...)
+					lengths = append(lengths, int64(len(d)))
+				}
+				addStream(id, 1, orcTestInts(col.ints, v2, false))
+				addStream(id, 2, orcTestInts(lengths, v2, false))
+				addStream(id, 3, dict)
+				encoding = 1
+			} else {
+				addStream(id, 1, col.data)
+				addStream(id, 2, orcTestInts(col.lengths, v2, false))
+			}
+		case orcTestTimestamp:
+			addStream(id, 1, orcTestInts(col.ints, v2, true))
+			addStream(id, 5, orcTestInts(col.second, v2, false))
+		case orcTestDecimal:
+			addStream(id, 1, col.data)
+			addStream(id, 5, orcTestInts(col.second, v2, true))
+		case orcTestList, orcTestMap:
+			addStream(id, 2, orcTestInts(col.lengths, v2, false))
+		}
+		if v2 {
+			encoding += 2
+		}
+		enc := protoUint(1, encoding)
+		if col.dict != nil {
+			enc = append(enc, protoUint(2, uint64(len(col.dict)))...)
+		}
+		encodings = append(encodings, protoBytes(2, enc)...)
+
+		*col = orcTestColumn{kind: col.kind, subtypes: col.subtypes, names: col.names, dict: col.dict}
+	}
+
+	footer := x.compress(append(streams, encodings...))
+	x.file = append(x.file, data...)
+	x.file = append(x.file, footer...)
+
+	var info []byte
+	info = append(info, protoUint(1, uint64(offset))...)
+	info = append(info, protoUint(2, uint64(len(index)))...)
+	info = append(info, protoUint(3, uint64(len(data)))...)
+	info = append(info, protoUint(4, uint64(len(footer)))...)
+	info = append(info, protoUint(5, rows)...)
+	x.stripes = append(x.stripes, info)
+	x.rows += rows
+}
+
+func (x *orcTestWriter) close() []byte {
+	var footer []byte
+	footer = append(footer, protoUint(1, 3)...)
+	footer = append(footer, protoUint(2, uint64(len(x.file)-3))...)
+	for _, s := range x.stripes {
+		footer = append(footer, protoBytes(3, s)...)
+	}
+	for _, col := range x.cols {
+		t := protoUint(1, col.kind)
+		if len(col.subtypes) > 0 {
+			var packed []byte
+			for _, s := range col.subtypes {
+				packed = append(packed, protoVarint(uint64(s))...)
+			}
+			t = append(t, protoBytes(2, packed)...)
+		}
+		for _, name := range col.names {
+			t = append(t, protoBytes(3, []byte(name))...)
+		}
+		footer = append(footer, protoBytes(4, t)...)
+	}
+	footer = append(footer, protoUint(6, x.rows)...)
+	footer = x.compress(footer)
+
+	var ps []byte
+	ps = append(ps, protoUint(1, uint64(len(footer)))...)
+	ps = append(ps, protoUint(2, x.compression)...)
+	ps = append(ps, protoUint(3, uint64(x.blockSize))...)
+	ps = append(ps, protoBytes(8000, []byte("ORC"))...)
+
+	file := append(x.file, footer...)
+	file = append(file, ps...)
+	return append(file, byte(len(ps)))
+}
+
+func newORCTestFile(compression uint64) []byte {
+	w := newORCTestWriter(compression,
+		&orcTestColumn{kind: orcTestStruct, subtypes: []int{1, 2, 3, 5, 8, 9, 10, 11, 12, 13, 15},
+			names: []string{"host", "port", "tags", "attrs", "ts", "day", "ok", "score", "amount", "user", "level"}},
+		&orcTestColumn{kind: orcTestString},                                               // 1: host
+		&orcTestColumn{kind: orcTestLong},                                                 // 2: port
+		&orcTestColumn{kind: orcTestList, subtypes: []int{4}},                             // 3: tags
+		&orcTestColumn{kind: orcTestString},                                               // 4: tags element
+		&orcTestColumn{kind: orcTestMap, subtypes: []int{6, 7}},                           // 5: attrs
+		&orcTestColumn{kind: orcTestString},                                               // 6: attrs key
+		&orcTestColumn{kind: orcTestLong},                                                 // 7: attrs value
+		&orcTestColumn{kind: orcTestTimestamp},                                            // 8: ts
+		&orcTestColumn{kind: orcTestDate},                                                 // 9: day
+		&orcTestColumn{kind: orcTestBoolean},                                              // 10: ok
+		&orcTestColumn{kind: orcTestDouble},                                               // 11: score
+		&orcTestColumn{kind: orcTestDecimal},                                              // 12: amount
+		&orcTestColumn{kind: orcTestStruct, subtypes: []int{14}, names: []string{"name"}}, // 13: user
+		&orcTestColumn{kind: orcTestString},                                               // 14: user name
+		&orcTestColumn{kind: orcTestString, dict: []string{"info", "warn"}},               // 15: level
+	)
+
+	ts := time.Date(2019, 10, 19, 4, 44, 44, 123000000, time.UTC)
+	for i := 0; i < 3; i++ {
+		row := map[string]interface{}{
+			"host":   fmt.Sprintf("host%d", i),
+			"port":   -8000 - i,
+			"tags":   []interface{}{"a", "b"},
+			"attrs":  map[string]interface{}{"cpu": i, "mem": 1 << 40},
+			"ts":     ts.Add(time.Duration(i) * time.Hour),
+			"day":    ts,
+			"ok":     i%2 == 0,
+			"score":  1.5,
+			"amount": "-123.45",
+			"user":   map[string]interface{}{"name": "mizutani"},
+			"level":  "warn",
+		}
+		switch i {
+		case 1:
+			row["port"] = nil
+			row["tags"] = []interface{}{}
+			row["user"] = nil
+		case 2:
+			row["level"] = "info"
+			row["amount"] = "3"
+		}
+		w.add(0, row)
+
+		// The first stripe has 2 rows with RLE v2 and the second one has 1 row with RLE v1
+		if i == 1 {
+			w.flush(true)
+		}
+	}
+	w.flush(false)
+
+	return w.close()
+}
+
+func loadORC(t *testing.T, data []byte) ([]*rlogs.MessageQueue, []string) {
+	dummy := dummyS3ClientRange{data: data}
+	rlogs.InjectNewS3Client(&dummy)
+	defer rlogs.FixNewS3Client()
+
+	var messages []*rlogs.MessageQueue
+	for msg := range (&rlogs.S3ORCLoader{}).Load(&rlogs.AwsS3LogSource{Region: "ap-northeast-1", Bucket: "my-own-bucket", Key: "data.orc"}) {
+		messages = append(messages, msg)
+	}
+	return messages, dummy.requests
+}
+
+func TestS3ORCLoader(t *testing.T) {
+	expects := []string{
+		`{"host":"host0","port":-8000,"tags":["a","b"],"attrs":{"cpu":0,"mem":1099511627776},"ts":"2019-10-19T04:44:44.123Z","day":"2019-10-19","ok":true,"score":1.5,"amount":-123.45,"user":{"name":"mizutani"},"level":"warn"}`,
+		`{"host":"host1","port":null,"tags":[],"attrs":{"cpu":1,"mem":1099511627776},"ts":"2019-10-19T05:44:44.123Z","day":"2019-10-19","ok":false,"score":1.5,"amount":-123.45,"user":null,"level":"warn"}`,
+		`{"host":"host2","port":-8002,"tags":["a","b"],"attrs":{"cpu":2,"mem":1099511627776},"ts":"2019-10-19T06:44:44.123Z","day":"2019-10-19","ok":true,"score":1.5,"amount":3,"user":{"name":"mizutani"},"level":"info"}`,
+	}
+
+	for _, compression := range []uint64{orcTestNone, orcTestZlib, orcTestSnappy, orcTestLZ4, orcTestZstd} {
+		data := newORCTestFile(compression)
+		messages, requests := loadORC(t, data)
+
+		require.Equal(t, len(expects), len(messages), "compression %d", compression)
+		for i, expect := range expects {
+			require.NoError(t, messages[i].Error, "compression %d", compression)
+			assert.JSONEq(t, expect, string(messages[i].Raw), "compression %d", compression)
+			assert.Equal(t, i, messages[i].Seq)
+		}
+
+		// Tail and 2 stripes are read by range requests without whole object
+		require.Equal(t, 3, len(requests))
+		assert.Equal(t, "bytes=-16384", requests[0])
+		for _, r := range requests[1:] {
+			assert.NotEqual(t, fmt.Sprintf("bytes=0-%d", len(data)-1), r)
+		}
+	}
+}
+
+func TestS3ORCLoaderLargeFooter(t *testing.T) {
+	name := strings.Repeat("x", 20000)
+	w := newORCTestWriter(orcTestNone,
+		&orcTestColumn{kind: orcTestStruct, subtypes: []int{1}, names: []string{name}},
+		&orcTestColumn{kind: orcTestLong},
+	)
+	w.add(0, map[string]interface{}{name: 1})
+	w.flush(true)
+	data := w.close()
+
+	messages, requests := loadORC(t, data)
+	require.Equal(t, 1, len(messages))
+	require.NoError(t, messages[0].Error)
+	assert.JSONEq(t, fmt.Sprintf(`{"%s":1}`, name), string(messages[0].Raw))
+
+	// Footer is fetched again because it's larger than the first request
+	require.Equal(t, 3, len(requests))
+	assert.Equal(t, "bytes=-16384", requests[0])
+	assert.Regexp(t, `^bytes=\d+-\d+$`, requests[1])
+}
+
+func TestS3ORCLoaderErrorCase(t *testing.T) {
+	messages, _ := loadORC(t, []byte("blue\norange\nred\n"))
+	require.Equal(t, 1, len(messages))
+	require.Error(t, messages[0].Error)
+	assert.Contains(t, messages[0].Error.Error(), "Not ORC object")
+
+	messages, _ = loadORC(t, newORCTestFile(orcTestLZO))
+	require.Equal(t, 1, len(messages))
+	require.Error(t, messages[0].Error)
+	assert.Contains(t, messages[0].Error.Error(), "LZO compression of ORC is not supported")
+
+	// Truncated stream of a column
+	w := newORCTestWriter(orcTestNone,
+		&orcTestColumn{kind: orcTestStruct, subtypes: []int{1}, names: []string{"port"}},
+		&orcTestColumn{kind: orcTestLong},
+	)
+	w.add(0, map[string]interface{}{"port": 1})
+	w.cols[0].present = append(w.cols[0].present, true)
+	w.flush(true)
+	messages, _ = loadORC(t, w.close())
+	require.Equal(t, 2, len(messages))
+	require.NoError(t, messages[0].Error)
+	require.Error(t, messages[1].Error)
+	assert.Contains(t, messages[1].Error.Error(), "Fail to read ORC row [1]: Fail to read ORC column 1")
+}
+
+func TestORCIntRLE(t *testing.T) {
+	// Examples in ORC specification
+	testCases := []struct {
+		data    []byte
+		version int
+		signed  bool
+		expect  []int64
+	}{
+		{[]byte{0x0a, 0x27, 0x10}, 2, false, []int64{10000, 10000, 10000, 10000, 10000}},
+		{[]byte{0x5e, 0x03, 0x5c, 0xa1, 0xab, 0x1e, 0xde, 0xad, 0xbe, 0xef}, 2, false, []int64{23713, 43806, 57005, 48879}},
+		{[]byte{0x8e, 0x13, 0x2b, 0x21, 0x07, 0xd0, 0x1e, 0x00, 0x14, 0x70, 0x28, 0x32, 0x3c, 0x46, 0x50, 0x5a, 0x64, 0x6e,
+			0x78, 0x82, 0x8c, 0x96, 0xa0, 0xaa, 0xb4, 0xbe, 0xfc, 0xe8}, 2, false,
+			[]int64{2030, 2000, 2020, 1000000, 2040, 2050, 2060, 2070, 2080, 2090, 2100, 2110, 2120, 2130, 2140, 2150, 2160, 2170, 2180, 2190}},
+		{[]byte{0xc6, 0x09, 0x02, 0x02, 0x22, 0x42, 0x42, 0x46}, 2, false, []int64{2, 3, 5, 7, 11, 13, 17, 19, 23, 29}},
+		{[]byte{0xc0, 0x04, 0x05, 0x01}, 2, true, []int64{-3, -4, -5, -6, -7}},
+		{[]byte{0x61, 0xff, 0x64}, 1, false, []int64{100, 99, 98}},
+		{[]byte{0x02, 0x00, 0x64}, 1, false, []int64{100, 100, 100, 100, 100}},
+		{[]byte{0xfb, 0x02, 0x03, 0x06, 0x07, 0x0b}, 1, false, []int64{2, 3, 6, 7, 11}},
+		{[]byte{0xfe, 0x03, 0x04}, 1, true, []int64{-2, 2}},
+	}
+
+	for _, tc := range testCases {
+		values, err := rlogs.DecodeORCIntRLE(tc.data, tc.version, tc.signed, len(tc.expect))
+		require.NoError(t, err)
+		assert.Equal(t, tc.expect, values)
+	}
+
+	_, err := rlogs.DecodeORCIntRLE([]byte{0x5e, 0x03, 0x5c}, 2, false, 4)
+	assert.Error(t, err)
+}
+
+func TestORCByteRLE(t *testing.T) {
+	values, err := rlogs.DecodeORCByteRLE([]byte{0x61, 0x00, 0xfe, 0x44, 0x45}, 102)
+	require.NoError(t, err)
+	assert.Equal(t, append(make([]byte, 100), 0x44, 0x45), values)
+
+	_, err = rlogs.DecodeORCByteRLE([]byte{0xfe, 0x44}, 2)
+	assert.Error(t, err)
+}
@@ -0,0 +1,763 @@
+package rlogs
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Kinds of ORC type
+const (
+	orcBoolean = iota
+	orcByte
+	orcShort
+	orcInt
+	orcLong
+	orcFloat
+	orcDouble
+	orcString
+	orcBinary
+	orcTimestamp
+	orcList
+	orcMap
+	orcStruct
+	orcUnion
+	orcDecimal
+	orcDate
+	orcVarchar
+	orcChar
+	orcTimestampInstant
+)
+
+// orcTimestampBase is base of ORC timestamp, 2015-01-01 00:00:00 UTC.
+const orcTimestampBase = 1420070400
+
+// orcColumn reads value of a column row by row. A child column of struct, list,
+// map and union has values only for rows that have non-null parent value.
+type orcColumn func() (interface{}, error)
+
+// newColumn creates reader of column id and its children in the stripe.
+func (x *s3ORCFile) newColumn(id int, sd *orcStripeData) (orcColumn, error) {
+	t := x.types[id]
+	enc := sd.encodings[id]
+	stream := func(kind uint64) []byte { return sd.streams[orcStreamKey{column: id, kind: kind}] }
+	ints := func(kind uint64, signed bool) orcIntReader {
+		if enc.kind == orcEncodingDirectV2 || enc.kind == orcEncodingDictionaryV2 {
+			return &orcRLEv2{data: stream(kind), signed: signed}
+		}
+		return &orcRLEv1{data: stream(kind), signed: signed}
+	}
+
+	children := make([]orcColumn, len(t.subtypes))
+	for i, sub := range t.subtypes {
+		child, err := x.newColumn(sub, sd)
+		if err != nil {
+			return nil, err
+		}
+		children[i] = child
+	}
+
+	var read orcColumn
+	switch t.kind {
+	case orcBoolean:
+		data := &orcBoolReader{bytes: orcByteRLE{data: stream(orcStreamData)}}
+		read = func() (interface{}, error) { return data.next() }
+
+	case orcByte:
+		data := &orcByteRLE{data: stream(orcStreamData)}
+		read = func() (interface{}, error) {
+			b, err := data.next()
+			return int64(int8(b)), err
+		}
+
+	case orcShort, orcInt, orcLong:
+		data := ints(orcStreamData, true)
+		read = func() (interface{}, error) { return data.next() }
+
+	case orcFloat:
+		data := &orcBytes{data: stream(orcStreamData)}
+		read = func() (interface{}, error) {
+			b, err := data.next(4)
+			if err != nil {
+				return nil, err
+			}
+			return math.Float32frombits(binary.LittleEndian.Uint32(b)), nil
+		}
+
+	case orcDouble:
+		data := &orcBytes{data: stream(orcStreamData)}
+		read = func() (interface{}, error) {
+			b, err := data.next(8)
+			if err != nil {
+				return nil, err
+			}
+			return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+		}
+
+	case orcString, orcVarchar, orcChar, orcBinary:
+		var next func() ([]byte, error)
+		if enc.kind == orcEncodingDictionary || enc.kind == orcEncodingDictionaryV2 {
+			dict, err := readORCDictionary(stream(orcStreamDictionaryData), ints(orcStreamLength, false), enc.dictionarySize)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Fail to read dictionary of ORC column %d", id)
+			}
+			data := ints(orcStreamData, false)
+			next = func() ([]byte, error) {
+				idx, err := data.next()
+				if err != nil {
+					return nil, err
+				}
+				if idx < 0 || idx >= int64(len(dict)) {
+					return nil, fmt.Errorf("Invalid dictionary index %d", idx)
+				}
+				return dict[idx], nil
+			}
+		} else {
+			data, lengths := &orcBytes{data: stream(orcStreamData)}, ints(orcStreamLength, false)
+			next = func() ([]byte, error) {
+				n, err := lengths.next()
+				if err != nil {
+					return nil, err
+				}
+				return data.next(int(n))
+			}
+		}
+
+		read = func() (interface{}, error) {
+			b, err := next()
+			if err != nil || t.kind == orcBinary {
+				return b, err
+			}
+			return string(b), nil
+		}
+
+	case orcTimestamp, orcTimestampInstant:
+		seconds, nanos := ints(orcStreamData, true), ints(orcStreamSecondary, false)
+		read = func() (interface{}, error) {
+			s, err := seconds.next()
+			if err != nil {
+				return nil, err
+			}
+			n, err := nanos.next()
+			if err != nil {
+				return nil, err
+			}
+			return orcTimestampString(s, n), nil
+		}
+
+	case orcDate:
+		data := ints(orcStreamData, true)
+		read = func() (interface{}, error) {
+			days, err := data.next()
+			if err != nil {
+				return nil, err
+			}
+			return time.Unix(days*86400, 0).UTC().Format("2006-01-02"), nil
+		}
+
+	case orcDecimal:
+		data, scales := &orcBytes{data: stream(orcStreamData)}, ints(orcStreamSecondary, true)
+		read = func() (interface{}, error) {
+			v, err := data.bigVarint()
+			if err != nil {
+				return nil, err
+			}
+			scale, err := scales.next()
+			if err != nil {
+				return nil, err
+			}
+			return orcDecimalNumber(v, scale), nil
+		}
+
+	case orcStruct:
+		read = func() (interface{}, error) {
+			m := make(map[string]interface{}, len(children))
+			for i, child := range children {
+				v, err := child()
+				if err != nil {
+					return nil, err
+				}
+				m[t.fieldNames[i]] = v
+			}
+			return m, nil
+		}
+
+	case orcList:
+		if len(children) != 1 {
+			return nil, fmt.Errorf("List of ORC column %d has %d subtypes", id, len(children))
+		}
+		lengths := ints(orcStreamLength, false)
+		read = func() (interface{}, error) {
+			n, err := lengths.next()
+			if err != nil {
+				return nil, err
+			}
+			values := []interface{}{} // Not allocated by n that may be broken
+			for i := int64(0); i < n; i++ {
+				v, err := children[0]()
+				if err != nil {
+					return nil, err
+				}
+				values = append(values, v)
+			}
+			return values, nil
+		}
+
+	case orcMap:
+		if len(children) != 2 {
+			return nil, fmt.Errorf("Map of ORC column %d has %d subtypes", id, len(children))
+		}
+		lengths := ints(orcStreamLength, false)
+		read = func() (interface{}, error) {
+			n, err := lengths.next()
+			if err != nil {
+				return nil, err
+			}
+			m := map[string]interface{}{}
+			for i := int64(0); i < n; i++ {
+				k, err := children[0]()
+				if err != nil {
+					return nil, err
+				}
+				v, err := children[1]()
+				if err != nil {
+					return nil, err
+				}
+				m[fmt.Sprint(k)] = v
+			}
+			return m, nil
+		}
+
+	case orcUnion:
+		tags := &orcByteRLE{data: stream(orcStreamData)}
+		read = func() (interface{}, error) {
+			tag, err := tags.next()
+			if err != nil {
+				return nil, err
+			}
+			if int(tag) >= len(children) {
+				return nil, fmt.Errorf("Invalid union tag %d", tag)
+			}
+			return children[tag]()
+		}
+
+	default:
+		return nil, fmt.Errorf("Unsupported ORC type %d of column %d", t.kind, id)
+	}
+
+	if present := stream(orcStreamPresent); present != nil {
+		bits, value := &orcBoolReader{bytes: orcByteRLE{data: present}}, read
+		read = func() (interface{}, error) {
+			ok, err := bits.next()
+			if err != nil || !ok {
+				return nil, err
+			}
+			return value()
+		}
+	}
+
+	return func() (interface{}, error) {
+		v, err := read()
+		if err != nil {
+			if _, ok := err.(*orcColumnError); !ok {
+				err = &orcColumnError{column: id, err: err}
+			}
+			return nil, err
+		}
+		return v, nil
+	}, nil
+}
+
+// orcColumnError is error of reading a column. It's not wrapped again by parent
+// columns.
+type orcColumnError struct {
+	column int
+	err    error
+}
+
+func (x *orcColumnError) Error() string {
+	return fmt.Sprintf("Fail to read ORC column %d: %v", x.column, x.err)
+}
+
+// readORCDictionary reads entries of dictionary encoded string column.
+func readORCDictionary(data []byte, lengths orcIntReader, size uint64) ([][]byte, error) {
+	r := &orcBytes{data: data}
+	var dict [][]byte
+	for i := uint64(0); i < size; i++ {
+		n, err := lengths.next()
+		if err != nil {
+			return nil, err
+		}
+		entry, err := r.next(int(n))
+		if err != nil {
+			return nil, err
+		}
+		dict = append(dict, entry)
+	}
+	return dict, nil
+}
+
+// orcTimestampString converts seconds from orcTimestampBase and encoded nanos
+// to RFC3339 string. Trailing zeros of nanos are encoded in the lowest 3 bits.
+func orcTimestampString(seconds, encodedNanos int64) string {
+	nanos := encodedNanos >> 3
+	if zeros := encodedNanos & 7; zeros != 0 {
+		for i := int64(0); i <= zeros; i++ {
+			nanos *= 10
+		}
+	}
+
+	// Seconds of negative timestamp is truncated toward zero by writer
+	secs := orcTimestampBase + seconds
+	if secs < 0 && nanos > 999999 {
+		secs--
+	}
+	return time.Unix(secs, nanos).UTC().Format(time.RFC3339Nano)
+}
+
+// orcDecimalNumber converts unscaled value and scale to JSON number.
+func orcDecimalNumber(v *big.Int, scale int64) json.Number {
+	if scale <= 0 {
+		return json.Number(new(big.Int).Mul(v, new(big.Int).Exp(big.NewInt(10), big.NewInt(-scale), nil)).String())
+	}
+	r := new(big.Rat).SetFrac(v, new(big.Int).Exp(big.NewInt(10), big.NewInt(scale), nil))
+	return json.Number(r.FloatString(int(scale)))
+}
+
+// orcBytes reads raw bytes of stream.
+type orcBytes struct {
+	data []byte
+	pos  int
+}
+
+func (x *orcBytes) next(n int) ([]byte, error) {
+	if n < 0 || n > len(x.data)-x.pos {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := x.data[x.pos : x.pos+n]
+	x.pos += n
+	return b, nil
+}
+
+// bigVarint reads unbounded base 128 varint with zigzag encoding.
+func (x *orcBytes) bigVarint() (*big.Int, error) {
+	v, shift := new(big.Int), uint(0)
+	for {
+		if x.pos >= len(x.data) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		b := x.data[x.pos]
+		x.pos++
+		v.Or(v, new(big.Int).Lsh(big.NewInt(int64(b&0x7f)), shift))
+		shift += 7
+		if b < 0x80 {
+			break
+		}
+	}
+
+	negative := v.Bit(0) == 1
+	v.Rsh(v, 1)
+	if negative {
+		v.Neg(v).Sub(v, big.NewInt(1))
+	}
+	return v, nil
+}
+
+// orcByteRLE reads stream of byte run length encoding.
+type orcByteRLE struct {
+	data   []byte
+	pos    int
+	values []byte
+	idx    int
+}
+
+func (x *orcByteRLE) next() (byte, error) {
+	if x.idx >= len(x.values) {
+		if x.pos >= len(x.data) {
+			return 0, io.ErrUnexpectedEOF
+		}
+		control := x.data[x.pos]
+		x.pos++
+
+		if control < 0x80 { // Run of a byte
+			if x.pos >= len(x.data) {
+				return 0, io.ErrUnexpectedEOF
+			}
+			x.values = bytesRepeat(x.data[x.pos], int(control)+3)
+			x.pos++
+		} else { // Literals
+			n := 0x100 - int(control)
+			if n > len(x.data)-x.pos {
+				return 0, io.ErrUnexpectedEOF
+			}
+			x.values = x.data[x.pos : x.pos+n]
+			x.pos += n
+		}
+		x.idx = 0
+	}
+
+	b := x.values[x.idx]
+	x.idx++
+	return b, nil
+}
+
+func bytesRepeat(b byte, n int) []byte {
+	values := make([]byte, n)
+	for i := range values {
+		values[i] = b
+	}
+	return values
+}
+
+// orcBoolReader reads stream of booleans, that are bits (MSB first) in byte
+// run length encoding.
+type orcBoolReader struct {
+	bytes orcByteRLE
+	cur   byte
+	bits  int
+}
+
+func (x *orcBoolReader) next() (bool, error) {
+	if x.bits == 0 {
+		b, err := x.bytes.next()
+		if err != nil {
+			return false, err
+		}
+		x.cur, x.bits = b, 8
+	}
+	x.bits--
+	return (x.cur>>uint(x.bits))&1 == 1, nil
+}
+
+// orcIntReader reads stream of integer run length encoding.
+type orcIntReader interface {
+	next() (int64, error)
+}
+
+func zigzagDecode(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+// orcRLEv1 reads integer run length encoding version 1 for DIRECT and
+// DICTIONARY column encoding.
+type orcRLEv1 struct {
+	data   []byte
+	pos    int
+	signed bool
+	values []int64
+	idx    int
+}
+
+func (x *orcRLEv1) varint() (int64, error) {
+	v, n := binary.Uvarint(x.data[x.pos:])
+	if n <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	x.pos += n
+	if x.signed {
+		return zigzagDecode(v), nil
+	}
+	return int64(v), nil
+}
+
+func (x *orcRLEv1) next() (int64, error) {
+	if x.idx >= len(x.values) {
+		if x.pos >= len(x.data) {
+			return 0, io.ErrUnexpectedEOF
+		}
+		control := int8(x.data[x.pos])
+		x.pos++
+		x.values, x.idx = x.values[:0], 0
+
+		if control >= 0 { // Run with delta
+			if x.pos >= len(x.data) {
+				return 0, io.ErrUnexpectedEOF
+			}
+			delta := int64(int8(x.data[x.pos]))
+			x.pos++
+			base, err := x.varint()
+			if err != nil {
+				return 0, err
+			}
+			for i := int64(0); i < int64(control)+3; i++ {
+				x.values = append(x.values, base+i*delta)
+			}
+		} else { // Literals
+			for i := 0; i < -int(control); i++ {
+				v, err := x.varint()
+				if err != nil {
+					return 0, err
+				}
+				x.values = append(x.values, v)
+			}
+		}
+	}
+
+	v := x.values[x.idx]
+	x.idx++
+	return v, nil
+}
+
+// orcRLEv2 reads integer run length encoding version 2 for DIRECT_V2 and
+// DICTIONARY_V2 column encoding.
+type orcRLEv2 struct {
+	data   []byte
+	pos    int
+	signed bool
+	values []int64
+	idx    int
+}
+
+// Sub-encodings of integer run length encoding version 2
+const (
+	orcRLEv2ShortRepeat = 0
+	orcRLEv2Direct      = 1
+	orcRLEv2PatchedBase = 2
+	orcRLEv2Delta       = 3
+)
+
+func (x *orcRLEv2) next() (int64, error) {
+	if x.idx >= len(x.values) {
+		if err := x.readRun(); err != nil {
+			return 0, err
+		}
+	}
+
+	v := x.values[x.idx]
+	x.idx++
+	return v, nil
+}
+
+func (x *orcRLEv2) readRun() error {
+	if x.pos >= len(x.data) {
+		return io.ErrUnexpectedEOF
+	}
+	header := x.data[x.pos]
+	x.values, x.idx = x.values[:0], 0
+
+	switch header >> 6 {
+	case orcRLEv2ShortRepeat:
+		width, count := int(header>>3&7)+1, int(header&7)+3
+		if 1+width > len(x.data)-x.pos {
+			return io.ErrUnexpectedEOF
+		}
+		var v uint64
+		for _, b := range x.data[x.pos+1 : x.pos+1+width] {
+			v = v<<8 | uint64(b)
+		}
+		x.pos += 1 + width
+		for i := 0; i < count; i++ {
+			x.values = append(x.values, x.decode(v))
+		}
+
+	case orcRLEv2Direct:
+		if 2 > len(x.data)-x.pos {
+			return io.ErrUnexpectedEOF
+		}
+		width := orcDecodeWidth(header >> 1 & 0x1f)
+		length := (int(header&1)<<8 | int(x.data[x.pos+1])) + 1
+		x.pos += 2
+		values, err := x.unpack(width, length)
+		if err != nil {
+			return err
+		}
+		for _, v := range values {
+			x.values = append(x.values, x.decode(v))
+		}
+
+	case orcRLEv2PatchedBase:
+		return x.readPatchedBase()
+
+	case orcRLEv2Delta:
+		return x.readDelta()
+	}
+
+	return nil
+}
+
+func (x *orcRLEv2) decode(v uint64) int64 {
+	if x.signed {
+		return zigzagDecode(v)
+	}
+	return int64(v)
+}
+
+func (x *orcRLEv2) readPatchedBase() error {
+	if 4 > len(x.data)-x.pos {
+		return io.ErrUnexpectedEOF
+	}
+	h := x.data[x.pos : x.pos+4]
+	x.pos += 4
+
+	width := orcDecodeWidth(h[0] >> 1 & 0x1f)
+	length := (int(h[0]&1)<<8 | int(h[1])) + 1
+	baseWidth := int(h[2]>>5&7) + 1
+	patchWidth := orcDecodeWidth(h[2] & 0x1f)
+	gapWidth := int(h[3]>>5&7) + 1
+	patchLength := int(h[3] & 0x1f)
+
+	// Base value is big endian and the most significant bit is sign
+	if baseWidth > len(x.data)-x.pos {
+		return io.ErrUnexpectedEOF
+	}
+	var ubase uint64
+	for _, b := range x.data[x.pos : x.pos+baseWidth] {
+		ubase = ubase<<8 | uint64(b)
+	}
+	x.pos += baseWidth
+	signBit := uint64(1) << uint(baseWidth*8-1)
+	base := int64(ubase &^ signBit)
+	if ubase&signBit != 0 {
+		base = -base
+	}
+
+	values, err := x.unpack(width, length)
+	if err != nil {
+		return err
+	}
+	patches, err := x.unpack(orcClosestFixedBits(gapWidth+patchWidth), patchLength)
+	if err != nil {
+		return err
+	}
+
+	// A patch has gap from the previous patch and the high bits of the value
+	pos := 0
+	for _, p := range patches {
+		pos += int(p >> uint(patchWidth))
+		patch := p & (1<<uint(patchWidth) - 1)
+		if patch == 0 {
+			continue // Only gap longer than 255
+		}
+		if pos >= len(values) {
+			return fmt.Errorf("Invalid patch position %d", pos)
+		}
+		values[pos] |= patch << uint(width)
+	}
+
+	for _, v := range values {
+		x.values = append(x.values, base+int64(v))
+	}
+	return nil
+}
+
+func (x *orcRLEv2) readDelta() error {
+	if 2 > len(x.data)-x.pos {
+		return io.ErrUnexpectedEOF
+	}
+	header := x.data[x.pos]
+	width := 0 // Fixed delta if width is 0
+	if code := header >> 1 & 0x1f; code != 0 {
+		width = orcDecodeWidth(code)
+	}
+	length := (int(header&1)<<8 | int(x.data[x.pos+1])) + 1
+	x.pos += 2
+
+	ubase, n := binary.Uvarint(x.data[x.pos:])
+	if n <= 0 {
+		return io.ErrUnexpectedEOF
+	}
+	x.pos += n
+	udelta, n := binary.Uvarint(x.data[x.pos:])
+	if n <= 0 {
+		return io.ErrUnexpectedEOF
+	}
+	x.pos += n
+
+	v, delta := x.decode(ubase), zigzagDecode(udelta)
+	x.values = append(x.values, v)
+	if length == 1 {
+		return nil
+	}
+	v += delta
+	x.values = append(x.values, v)
+
+	if width == 0 {
+		for i := 2; i < length; i++ {
+			v += delta
+			x.values = append(x.values, v)
+		}
+		return nil
+	}
+
+	// Deltas after the first one are absolute values with sign of the first one
+	deltas, err := x.unpack(width, length-2)
+	if err != nil {
+		return err
+	}
+	for _, d := range deltas {
+		if delta < 0 {
+			v -= int64(d)
+		} else {
+			v += int64(d)
+		}
+		x.values = append(x.values, v)
+	}
+	return nil
+}
+
+// unpack reads n values of width bits (big endian) that are aligned to byte
+// boundary at the end.
+func (x *orcRLEv2) unpack(width, n int) ([]uint64, error) {
+	size := (width*n + 7) / 8
+	if size > len(x.data)-x.pos {
+		return nil, io.ErrUnexpectedEOF
+	}
+	data := x.data[x.pos : x.pos+size]
+	x.pos += size
+
+	values := make([]uint64, n)
+	bit := 0
+	for i := range values {
+		var v uint64
+		for w := width; w > 0; {
+			avail := 8 - bit%8
+			take := avail
+			if w < take {
+				take = w
+			}
+			b := data[bit/8] >> uint(avail-take) & (1<<uint(take) - 1)
+			v = v<<uint(take) | uint64(b)
+			w -= take
+			bit += take
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// orcDecodeWidth converts 5 bits width code to bit width.
+func orcDecodeWidth(code byte) int {
+	if code < 24 {
+		return int(code) + 1
+	}
+	return []int{26, 28, 30, 32, 40, 48, 56, 64}[code-24]
+}
+
+// orcClosestFixedBits rounds up n to bit width that can be encoded.
+func orcClosestFixedBits(n int) int {
+	switch {
+	case n <= 24:
+		return n
+	case n <= 26:
+		return 26
+	case n <= 28:
+		return 28
+	case n <= 30:
+		return 30
+	case n <= 32:
+		return 32
+	case n <= 40:
+		return 40
+	case n <= 48:
+		return 48
+	case n <= 56:
+		return 56
+	}
+	return 64
+}
@@ -0,0 +1,391 @@
+package rlogs
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/schema"
+	"github.com/xitongsys/parquet-go/source"
+	"github.com/xitongsys/parquet-go/types"
+)
+
+const (
+	defaultS3ParquetLoaderBatchSize     = 1000
+	defaultS3ParquetLoaderReadAheadSize = 1024 * 1024 // 1 MB
+)
+
+// S3ParquetLoader is for Apache Parquet file on AWS S3, e.g. AWS Security Lake,
+// Athena CTAS output and Firehose record format conversion. The loader reads
+// footer and column chunks with range requests instead of downloading whole
+// object. One row is converted to one MessageQueue and Raw of the MessageQueue
+// is the row encoded as JSON object by column names. INT96 timestamp is
+// converted to RFC3339 string and other values are converted as physical type.
+//
+// S3ParquetLoader does not have S3Options. Reading is not resumed and checksum
+// of the object is not verified because the object is read by ranges and
+// checksum of the whole object can not be computed.
+type S3ParquetLoader struct {
+	// BatchSize is number of rows read at once. 1000 is used if zero.
+	BatchSize int
+	// ReadAheadSize is size of range request to read column chunks. 1 MB is
+	// used if zero. The footer is fetched by one request regardless of the size.
+	ReadAheadSize int64
+}
+
+// Load of S3ParquetLoader reads a Parquet object row by row
+func (x *S3ParquetLoader) Load(src LogSource) chan *MessageQueue {
+	return x.LoadContext(context.Background(), src)
+}
+
+// LoadContext of S3ParquetLoader is Load that stops reading the object when ctx is done
+func (x *S3ParquetLoader) LoadContext(ctx context.Context, src LogSource) chan *MessageQueue {
+	chMsg := make(chan *MessageQueue)
+
+	go func() {
+		defer close(chMsg)
+
+		s3src, ok := src.(*AwsS3LogSource)
+		if !ok {
+			sendMessage(ctx, chMsg, &MessageQueue{Error: fmt.Errorf("S3ParquetLoader accepts only AwsS3LogSource: %v", src)})
+			return
+		}
+
+		pf, err := newS3ParquetFile(ctx, s3src, x.ReadAheadSize)
+		if err != nil {
+			sendMessage(ctx, chMsg, &MessageQueue{Error: err})
+			return
+		}
+
+		pr, err := reader.NewParquetReader(pf, nil, 1)
+		if err != nil {
+			sendMessage(ctx, chMsg, &MessageQueue{Error: errors.Wrap(err, "Fail to read Parquet footer")})
+			return
+		}
+		defer pr.ReadStop()
+
+		batchSize := defaultS3ParquetLoaderBatchSize
+		if x.BatchSize > 0 {
+			batchSize = x.BatchSize
+		}
+
+		conv := newParquetConverter(pr.SchemaHandler)
+		numRows := int(pr.GetNumRows())
+		for seq := 0; seq < numRows; {
+			n := batchSize
+			if numRows-seq < n {
+				n = numRows - seq
+			}
+
+			rows, err := pr.ReadByNumber(n)
+			if err != nil {
+				sendMessage(ctx, chMsg, &MessageQueue{Error: errors.Wrap(err, "Fail to read Parquet rows")})
+				return
+			}
+			if len(rows) == 0 {
+				return
+			}
+
+			for _, row := range rows {
+				raw, err := json.Marshal(conv.convert(reflect.ValueOf(row), 0))
+				if err != nil {
+					sendMessage(ctx, chMsg, &MessageQueue{Error: errors.Wrapf(err, "Fail to encode Parquet row [%d]", seq)})
+					return
+				}
+
+				if !sendMessage(ctx, chMsg, &MessageQueue{
+					Raw: raw,
+					Seq: seq,
+					Src: src,
+				}) {
+					return
+				}
+				seq++
+			}
+		}
+	}()
+
+	return chMsg
+}
+
+// s3ParquetCache has the footer (file metadata, its length and magic number) at
+// tail of Parquet object and read-ahead buffer. It's shared by readers of the
+// object because Parquet reader opens the object for each column chunk and
+// column chunks of a row group are contiguous.
+type s3ParquetCache struct {
+	mutex        sync.Mutex
+	footer       []byte
+	footerOffset int64
+	buf          []byte
+	bufOffset    int64
+}
+
+// s3ParquetFile is source.ParquetFile that reads S3 object with range requests.
+// The footer is fetched by one request and column chunks are read with
+// read-ahead buffer.
+type s3ParquetFile struct {
+	ctx       context.Context
+	client    s3Client
+	src       *AwsS3LogSource
+	size      int64
+	offset    int64
+	readAhead int64
+	cache     *s3ParquetCache
+}
+
+func newS3ParquetFile(ctx context.Context, src *AwsS3LogSource, readAhead int64) (*s3ParquetFile, error) {
+	if readAhead <= 0 {
+		readAhead = defaultS3ParquetLoaderReadAheadSize
+	}
+	pf := &s3ParquetFile{
+		ctx:       ctx,
+		client:    NewS3Client(src.Region),
+		src:       src,
+		readAhead: readAhead,
+	}
+
+	// Get object size from Content-Range and footer length from the last 8 bytes,
+	// that are 4 bytes little endian footer length and magic number "PAR1"
+	trailer, contentRange, err := pf.fetch("bytes=-8")
+	if err != nil {
+		return nil, err
+	}
+	if len(trailer) != 8 || string(trailer[4:]) != "PAR1" {
+		return nil, fmt.Errorf("Not Parquet object (no magic number): %s", src.Key)
+	}
+	if pf.size, err = parseContentRangeSize(contentRange); err != nil {
+		return nil, err
+	}
+
+	footerLen := int64(binary.LittleEndian.Uint32(trailer[:4]))
+	begin := pf.size - 8 - footerLen
+	if begin < 4 {
+		return nil, fmt.Errorf("Invalid Parquet footer length %d: %s", footerLen, src.Key)
+	}
+
+	data, _, err := pf.fetch(fmt.Sprintf("bytes=%d-%d", begin, pf.size-1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != pf.size-begin {
+		return nil, fmt.Errorf("Fail to read Parquet footer (%d bytes): %s", len(data), src.Key)
+	}
+	pf.cache = &s3ParquetCache{footer: data, footerOffset: begin}
+
+	return pf, nil
+}
+
+// fetch sends range request and returns data and Content-Range of the response.
+func (x *s3ParquetFile) fetch(rangeHeader string) ([]byte, *string, error) {
+	return getObjectRange(x.ctx, x.client, x.src, rangeHeader)
+}
+
+// cached returns data from offset in the footer or read-ahead buffer.
+func (x *s3ParquetCache) cached(offset int64) []byte {
+	if offset >= x.footerOffset {
+		return x.footer[offset-x.footerOffset:]
+	}
+	if x.bufOffset <= offset && offset < x.bufOffset+int64(len(x.buf)) {
+		return x.buf[offset-x.bufOffset:]
+	}
+	return nil
+}
+
+func (x *s3ParquetFile) Read(p []byte) (int, error) {
+	if x.offset >= x.size {
+		return 0, io.EOF
+	}
+
+	x.cache.mutex.Lock()
+	defer x.cache.mutex.Unlock()
+
+	n := 0
+	for n < len(p) && x.offset < x.size {
+		data := x.cache.cached(x.offset)
+		if data == nil {
+			// Read ahead, but the footer is not read again
+			end := x.offset + x.readAhead
+			if size := int64(len(p) - n); size > x.readAhead {
+				end = x.offset + size
+			}
+			if end > x.cache.footerOffset {
+				end = x.cache.footerOffset
+			}
+
+			buf, _, err := x.fetch(fmt.Sprintf("bytes=%d-%d", x.offset, end-1))
+			if err != nil {
+				return n, err
+			}
+			if int64(len(buf)) != end-x.offset {
+				return n, fmt.Errorf("Unexpected size of S3 object range %d-%d: %d", x.offset, end-1, len(buf))
+			}
+			x.cache.buf, x.cache.bufOffset = buf, x.offset
+			data = buf
+		}
+
+		c := copy(p[n:], data)
+		n += c
+		x.offset += int64(c)
+	}
+
+	return n, nil
+}
+
+func (x *s3ParquetFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += x.offset
+	case io.SeekEnd:
+		offset += x.size
+	default:
+		return 0, fmt.Errorf("Invalid whence: %d", whence)
+	}
+
+	if offset < 0 {
+		return 0, fmt.Errorf("Invalid offset: %d", offset)
+	}
+	x.offset = offset
+	return x.offset, nil
+}
+
+func (x *s3ParquetFile) Write(p []byte) (int, error) {
+	return 0, fmt.Errorf("s3ParquetFile is read only")
+}
+
+func (x *s3ParquetFile) Close() error { return nil }
+
+// Open of s3ParquetFile returns a new reader of the same object. Parquet reader
+// opens the file for each column chunk.
+func (x *s3ParquetFile) Open(name string) (source.ParquetFile, error) {
+	return &s3ParquetFile{
+		ctx:       x.ctx,
+		client:    x.client,
+		src:       x.src,
+		size:      x.size,
+		readAhead: x.readAhead,
+		cache:     x.cache,
+	}, nil
+}
+
+func (x *s3ParquetFile) Create(name string) (source.ParquetFile, error) {
+	return nil, fmt.Errorf("s3ParquetFile is read only")
+}
+
+// parquetConverter converts a row read by parquet-go (struct created from
+// schema) to map by column names of the schema.
+type parquetConverter struct {
+	sh       *schema.SchemaHandler
+	children [][]int
+}
+
+func newParquetConverter(sh *schema.SchemaHandler) *parquetConverter {
+	conv := &parquetConverter{
+		sh:       sh,
+		children: make([][]int, len(sh.SchemaElements)),
+	}
+
+	// Schema elements are flattened in depth-first order
+	var walk func(idx int) int
+	walk = func(idx int) int {
+		next := idx + 1
+		for i := 0; i < int(sh.SchemaElements[idx].GetNumChildren()); i++ {
+			conv.children[idx] = append(conv.children[idx], next)
+			next = walk(next)
+		}
+		return next
+	}
+	walk(0)
+
+	return conv
+}
+
+func (x *parquetConverter) convert(v reflect.Value, idx int) interface{} {
+	elem := x.sh.SchemaElements[idx]
+	children := x.children[idx]
+
+	if len(children) == 0 {
+		if elem.GetRepetitionType() == parquet.FieldRepetitionType_REPEATED {
+			return x.convertSlice(v, func(e reflect.Value) interface{} { return x.convertLeaf(e, elem) })
+		}
+		return x.convertLeaf(v, elem)
+	}
+
+	// LIST and MAP are converted in the same way as SchemaHandler.GetTypes
+	if elem.ConvertedType != nil && len(children) == 1 {
+		inner := x.children[children[0]]
+		switch {
+		case *elem.ConvertedType == parquet.ConvertedType_LIST &&
+			x.sh.GetInName(children[0]) == "List" &&
+			len(inner) == 1 && x.sh.GetInName(inner[0]) == "Element":
+			return x.convertSlice(v, func(e reflect.Value) interface{} { return x.convert(e, inner[0]) })
+
+		case *elem.ConvertedType == parquet.ConvertedType_MAP &&
+			x.sh.GetInName(children[0]) == "Key_value" &&
+			len(inner) == 2 && x.sh.GetInName(inner[0]) == "Key" && x.sh.GetInName(inner[1]) == "Value":
+			if v.IsNil() {
+				return nil
+			}
+			m := map[string]interface{}{}
+			iter := v.MapRange()
+			for iter.Next() {
+				key := fmt.Sprint(x.convert(iter.Key(), inner[0]))
+				m[key] = x.convert(iter.Value(), inner[1])
+			}
+			return m
+		}
+	}
+
+	convertStruct := func(s reflect.Value) interface{} {
+		if s.Kind() == reflect.Ptr {
+			if s.IsNil() {
+				return nil
+			}
+			s = s.Elem()
+		}
+		m := map[string]interface{}{}
+		for i, ci := range children {
+			m[x.sh.GetExName(ci)] = x.convert(s.Field(i), ci)
+		}
+		return m
+	}
+
+	if idx > 0 && elem.GetRepetitionType() == parquet.FieldRepetitionType_REPEATED {
+		return x.convertSlice(v, convertStruct)
+	}
+	return convertStruct(v)
+}
+
+func (x *parquetConverter) convertSlice(v reflect.Value, conv func(reflect.Value) interface{}) interface{} {
+	if v.IsNil() {
+		return nil
+	}
+	values := make([]interface{}, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		values[i] = conv(v.Index(i))
+	}
+	return values
+}
+
+func (x *parquetConverter) convertLeaf(v reflect.Value, elem *parquet.SchemaElement) interface{} {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	if elem.GetType() == parquet.Type_INT96 {
+		return types.INT96ToTime(v.String()).UTC().Format(time.RFC3339Nano)
+	}
+
+	return v.Interface()
+}
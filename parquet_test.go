@@ -0,0 +1,163 @@
+package rlogs_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/m-mizutani/rlogs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xitongsys/parquet-go/types"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+type parquetTestRow struct {
+	Name  string            `parquet:"name=name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Port  int32             `parquet:"name=port, type=INT32"`
+	Note  *string           `parquet:"name=note, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	Tags  []string          `parquet:"name=tags, type=MAP, convertedtype=LIST, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+	Attrs map[string]string `parquet:"name=attrs, type=MAP, convertedtype=MAP, keytype=BYTE_ARRAY, keyconvertedtype=UTF8, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+	Time  string            `parquet:"name=time, type=INT96"`
+}
+
+// dummyS3ClientRange serves data with range request.
+type dummyS3ClientRange struct {
+	rlogs.TestS3ClientBase
+	data     []byte
+	requests []string
+}
+
+func (x *dummyS3ClientRange) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	if input.Range == nil {
+		return nil, fmt.Errorf("No range")
+	}
+	x.requests = append(x.requests, *input.Range)
+
+	rng := strings.Split(strings.TrimPrefix(*input.Range, "bytes="), "-")
+	begin, _ := strconv.Atoi(rng[0])
+	end, _ := strconv.Atoi(rng[1])
+	if rng[0] == "" {
+		// Suffix range, e.g. "bytes=-8"
+		begin, end = len(x.data)-end, len(x.data)-1
+		if begin < 0 {
+			begin = 0
+		}
+	}
+	if end >= len(x.data) {
+		end = len(x.data) - 1
+	}
+
+	return &s3.GetObjectOutput{
+		Body:         ioutil.NopCloser(bytes.NewReader(x.data[begin : end+1])),
+		ContentRange: aws.String(fmt.Sprintf("bytes %d-%d/%d", begin, end, len(x.data))),
+	}, nil
+}
+
+func TestS3ParquetLoader(t *testing.T) {
+	ts := time.Date(2019, 10, 19, 4, 44, 44, 123000000, time.UTC)
+	note := "hello"
+
+	var buf bytes.Buffer
+	pw, err := writer.NewParquetWriterFromWriter(&buf, new(parquetTestRow), 1)
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		row := parquetTestRow{
+			Name: fmt.Sprintf("host%d", i),
+			Port: int32(8000 + i),
+			Tags: []string{"a", "b"},
+			Time: types.TimeToINT96(ts),
+		}
+		if i == 0 {
+			row.Note = &note
+			row.Attrs = map[string]string{"env": "prod"}
+		}
+		require.NoError(t, pw.Write(row))
+	}
+	require.NoError(t, pw.WriteStop())
+
+	dummy := dummyS3ClientRange{data: buf.Bytes()}
+	rlogs.InjectNewS3Client(&dummy)
+	defer rlogs.FixNewS3Client()
+
+	ldr := rlogs.S3ParquetLoader{BatchSize: 2}
+	var messages []*rlogs.MessageQueue
+	for msg := range ldr.Load(&rlogs.AwsS3LogSource{Region: "ap-northeast-1", Bucket: "my-own-bucket", Key: "data.parquet"}) {
+		require.NoError(t, msg.Error)
+		messages = append(messages, msg)
+	}
+
+	require.Equal(t, 5, len(messages))
+	assert.Equal(t, 0, messages[0].Seq)
+	assert.Equal(t, 4, messages[4].Seq)
+	assert.JSONEq(t, `{"name":"host0","port":8000,"note":"hello","tags":["a","b"],"attrs":{"env":"prod"},"time":"2019-10-19T04:44:44.123Z"}`, string(messages[0].Raw))
+	assert.JSONEq(t, `{"name":"host4","port":8004,"note":null,"tags":["a","b"],"attrs":{},"time":"2019-10-19T04:44:44.123Z"}`, string(messages[4].Raw))
+
+	// Whole object should not be requested at once
+	for _, r := range dummy.requests {
+		assert.NotEqual(t, fmt.Sprintf("bytes=0-%d", len(dummy.data)-1), r)
+	}
+
+	// Footer is fetched by one request after the trailer, and column chunks are
+	// read with read-ahead
+	require.True(t, len(dummy.requests) > 2)
+	assert.Equal(t, "bytes=-8", dummy.requests[0])
+	assert.Regexp(t, fmt.Sprintf(`^bytes=\d+-%d$`, len(dummy.data)-1), dummy.requests[1])
+	for _, r := range dummy.requests[2:] {
+		assert.NotEqual(t, dummy.requests[1], r)
+	}
+	assert.True(t, len(dummy.requests) < 20, "too many requests: %d", len(dummy.requests))
+}
+
+func TestS3ParquetLoaderReadAhead(t *testing.T) {
+	var buf bytes.Buffer
+	pw, err := writer.NewParquetWriterFromWriter(&buf, new(parquetTestRow), 1)
+	require.NoError(t, err)
+	for i := 0; i < 20000; i++ {
+		require.NoError(t, pw.Write(parquetTestRow{
+			Name: fmt.Sprintf("host%d", i),
+			Port: int32(i),
+			Time: types.TimeToINT96(time.Date(2019, 10, 19, 4, 44, 44, 0, time.UTC)),
+		}))
+	}
+	require.NoError(t, pw.WriteStop())
+
+	load := func(readAhead int64) int {
+		dummy := dummyS3ClientRange{data: buf.Bytes()}
+		rlogs.InjectNewS3Client(&dummy)
+		defer rlogs.FixNewS3Client()
+
+		n := 0
+		for msg := range (&rlogs.S3ParquetLoader{ReadAheadSize: readAhead}).Load(&rlogs.AwsS3LogSource{Region: "ap-northeast-1", Bucket: "my-own-bucket", Key: "data.parquet"}) {
+			require.NoError(t, msg.Error)
+			n++
+		}
+		require.Equal(t, 20000, n)
+		return len(dummy.requests)
+	}
+
+	// Contiguous column chunks are read by a request of 1 MB read-ahead
+	assert.True(t, load(0) < load(1024))
+}
+
+func TestS3ParquetLoaderNotParquet(t *testing.T) {
+	dummy := dummyS3ClientRange{data: []byte("blue\norange\nred\n")}
+	rlogs.InjectNewS3Client(&dummy)
+	defer rlogs.FixNewS3Client()
+
+	ldr := rlogs.S3ParquetLoader{}
+	var messages []*rlogs.MessageQueue
+	for msg := range ldr.Load(&rlogs.AwsS3LogSource{Region: "ap-northeast-1", Bucket: "my-own-bucket", Key: "data.json"}) {
+		messages = append(messages, msg)
+	}
+
+	require.Equal(t, 1, len(messages))
+	require.Error(t, messages[0].Error)
+	assert.Contains(t, messages[0].Error.Error(), "Not Parquet object")
+}
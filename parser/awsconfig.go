@@ -0,0 +1,80 @@
+package parser
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/pkg/errors"
+)
+
+// AwsConfigRelationship is relationship between the resource and other one.
+type AwsConfigRelationship struct {
+	ResourceID   string `json:"resourceId"`
+	ResourceName string `json:"resourceName"`
+	ResourceType string `json:"resourceType"`
+	Name         string `json:"name"`
+}
+
+// AwsConfigItem is a configuration item in configuration history and snapshot
+// files delivered by AWS Config. Configuration and SupplementaryConfiguration
+// depend on ResourceType, then they are kept as generic map.
+type AwsConfigItem struct {
+	ConfigurationItemVersion     string                  `json:"configurationItemVersion"`
+	ConfigurationItemCaptureTime string                  `json:"configurationItemCaptureTime"`
+	ConfigurationStateID         int64                   `json:"configurationStateId"`
+	AwsAccountID                 string                  `json:"awsAccountId"`
+	ConfigurationItemStatus      string                  `json:"configurationItemStatus"`
+	ResourceType                 string                  `json:"resourceType"`
+	ResourceID                   string                  `json:"resourceId"`
+	ResourceName                 string                  `json:"resourceName"`
+	ARN                          string                  `json:"ARN"`
+	AwsRegion                    string                  `json:"awsRegion"`
+	AvailabilityZone             string                  `json:"availabilityZone"`
+	ConfigurationStateMd5Hash    string                  `json:"configurationStateMd5Hash"`
+	ResourceCreationTime         string                  `json:"resourceCreationTime"`
+	Tags                         map[string]string       `json:"tags"`
+	RelatedEvents                []string                `json:"relatedEvents"`
+	Relationships                []AwsConfigRelationship `json:"relationships"`
+	Configuration                map[string]interface{}  `json:"configuration"`
+	SupplementaryConfiguration   map[string]interface{}  `json:"supplementaryConfiguration"`
+}
+
+// AwsConfig is parser of AWS Config configuration history and snapshot files.
+// A file has multiple configuration items in one JSON document, then S3FileLoader
+// is required.
+type AwsConfig struct{}
+
+// Parse of AwsConfig converts configurationItems to LogRecord(s).
+func (x *AwsConfig) Parse(msg *rlogs.MessageQueue) ([]*rlogs.LogRecord, error) {
+	var logs []*rlogs.LogRecord
+
+	records, err := unwrapRecords(msg.Raw, "configurationItems")
+	if err != nil {
+		return nil, errors.Wrap(err, "Fail to parse AWS Config file")
+	}
+
+	for idx, raw := range records {
+		var item AwsConfigItem
+		if err := json.Unmarshal(raw, &item); err != nil {
+			return nil, errors.Wrapf(err, "Fail to unmarshal AWS Config item [%d]: %s", idx, string(raw))
+		}
+
+		// 2019-03-14T12:34:56.789Z
+		ts, err := time.Parse(time.RFC3339Nano, item.ConfigurationItemCaptureTime)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Fail to parse timestamp of AWS Config item: %v", item.ConfigurationItemCaptureTime)
+		}
+
+		logs = append(logs, &rlogs.LogRecord{
+			Tag:       "aws.config",
+			Timestamp: ts.UTC(),
+			Raw:       raw,
+			Values:    &item,
+			Seq:       idx,
+			Src:       msg.Src,
+		})
+	}
+
+	return logs, nil
+}
@@ -0,0 +1,55 @@
+package parser_test
+
+import (
+	"testing"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/m-mizutani/rlogs/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAwsConfigParser(t *testing.T) {
+	msg := `{"fileVersion":"1.0","configurationItems":[{"configurationItemVersion":"1.3","configurationItemCaptureTime":"2019-03-14T12:34:56.789Z","configurationStateId":1552566896789,"awsAccountId":"123456789012","configurationItemStatus":"OK","resourceType":"AWS::EC2::SecurityGroup","resourceId":"sg-0123456789abcdef0","resourceName":"web","ARN":"arn:aws:ec2:ap-northeast-1:123456789012:security-group/sg-0123456789abcdef0","awsRegion":"ap-northeast-1","availabilityZone":"Not Applicable","tags":{"Name":"web"},"relatedEvents":[],"relationships":[{"resourceId":"vpc-0a1b2c3d","resourceType":"AWS::EC2::VPC","name":"Is contained in Vpc"}],"configuration":{"groupName":"web"},"supplementaryConfiguration":{}},{"configurationItemVersion":"1.3","configurationItemCaptureTime":"2019-03-14T13:00:00.000Z","configurationStateId":1552568400000,"awsAccountId":"123456789012","configurationItemStatus":"ResourceDeleted","resourceType":"AWS::S3::Bucket","resourceId":"my-bucket","awsRegion":"ap-northeast-1","relationships":[]}]}`
+	src := &rlogs.AwsS3LogSource{Region: "test-r", Bucket: "test-b", Key: "test-k"}
+	psr := parser.AwsConfig{}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(msg), Src: src})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(logs))
+
+	assert.Equal(t, "aws.config", logs[0].Tag)
+	assert.Equal(t, 0, logs[0].Seq)
+	assert.Equal(t, 1, logs[1].Seq)
+	assert.Equal(t, "2019-03-14T12:34:56", logs[0].Timestamp.Format("2006-01-02T15:04:05"))
+
+	item := logs[0].Values.(*parser.AwsConfigItem)
+	assert.Equal(t, "AWS::EC2::SecurityGroup", item.ResourceType)
+	assert.Equal(t, "sg-0123456789abcdef0", item.ResourceID)
+	assert.Equal(t, int64(1552566896789), item.ConfigurationStateID)
+	assert.Equal(t, "web", item.Tags["Name"])
+	require.Equal(t, 1, len(item.Relationships))
+	assert.Equal(t, "AWS::EC2::VPC", item.Relationships[0].ResourceType)
+	assert.Equal(t, "web", item.Configuration["groupName"])
+
+	item = logs[1].Values.(*parser.AwsConfigItem)
+	assert.Equal(t, "ResourceDeleted", item.ConfigurationItemStatus)
+	assert.Equal(t, "my-bucket", item.ResourceID)
+}
+
+func TestAwsConfigParserNoItems(t *testing.T) {
+	// ConfigWritabilityCheckFile has no configuration items
+	psr := parser.AwsConfig{}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(`{"fileVersion":"1.0"}`)})
+	require.NoError(t, err)
+	assert.Equal(t, 0, len(logs))
+}
+
+func TestAwsConfigParserInvalidItems(t *testing.T) {
+	psr := parser.AwsConfig{}
+
+	_, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(`{"configurationItems":{"resourceId":"x"}}`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "configurationItems")
+}
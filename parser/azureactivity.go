@@ -0,0 +1,104 @@
+package parser
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/pkg/errors"
+)
+
+// AzureAuthorization is authorization information of Azure Activity Log.
+type AzureAuthorization struct {
+	Scope    string                 `json:"scope"`
+	Action   string                 `json:"action"`
+	Evidence map[string]interface{} `json:"evidence,omitempty"`
+}
+
+// AzureIdentity is identity of the caller in Azure Activity Log.
+type AzureIdentity struct {
+	Authorization *AzureAuthorization `json:"authorization,omitempty"`
+	Claims        map[string]string   `json:"claims,omitempty"`
+}
+
+// AzureActivityLogRecord is a record of Azure Activity Log written by diagnostic
+// settings. Caller is filled with UPN or name claim if "caller" field does not exist.
+type AzureActivityLogRecord struct {
+	Time            string                 `json:"time"`
+	ResourceID      string                 `json:"resourceId"`
+	OperationName   string                 `json:"operationName"`
+	Category        string                 `json:"category"`
+	ResultType      string                 `json:"resultType"`
+	ResultSignature string                 `json:"resultSignature"`
+	DurationMs      json.Number            `json:"durationMs"`
+	Caller          string                 `json:"caller"`
+	CallerIPAddress string                 `json:"callerIpAddress"`
+	CorrelationID   string                 `json:"correlationId"`
+	Identity        *AzureIdentity         `json:"identity,omitempty"`
+	Level           string                 `json:"level"`
+	Location        string                 `json:"location"`
+	Properties      map[string]interface{} `json:"properties,omitempty"`
+}
+
+var azureCallerClaims = []string{
+	"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/upn",
+	"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/name",
+	"appid",
+}
+
+// AzureActivityLog is parser of Azure Activity Log. It accepts both of a document
+// that has "records" array (use with S3FileLoader) and a single record per line
+// (use with S3LineLoader).
+type AzureActivityLog struct{}
+
+// Parse of AzureActivityLog converts record(s) to LogRecord(s) and uses time as timestamp.
+func (x *AzureActivityLog) Parse(msg *rlogs.MessageQueue) ([]*rlogs.LogRecord, error) {
+	records, err := unwrapRecords(msg.Raw, "records")
+	if err != nil {
+		return nil, errors.Wrap(err, "Fail to parse Azure Activity Log")
+	}
+
+	wrapped := records != nil
+	if !wrapped {
+		records = []json.RawMessage{msg.Raw}
+	}
+
+	var logs []*rlogs.LogRecord
+	for idx, raw := range records {
+		var record AzureActivityLogRecord
+		if err := json.Unmarshal(raw, &record); err != nil {
+			return nil, errors.Wrapf(err, "Fail to unmarshal Azure Activity Log record [%d]: %s", idx, string(raw))
+		}
+
+		if record.Caller == "" && record.Identity != nil {
+			for _, claim := range azureCallerClaims {
+				if v, ok := record.Identity.Claims[claim]; ok {
+					record.Caller = v
+					break
+				}
+			}
+		}
+
+		// 2019-10-19T04:44:44.1234567Z
+		ts, err := time.Parse(time.RFC3339Nano, record.Time)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Fail to parse time of Azure Activity Log: %v", record.Time)
+		}
+
+		seq := msg.Seq
+		if wrapped {
+			seq = idx
+		}
+
+		logs = append(logs, &rlogs.LogRecord{
+			Tag:       "azure.activity",
+			Timestamp: ts.UTC(),
+			Raw:       raw,
+			Values:    &record,
+			Seq:       seq,
+			Src:       msg.Src,
+		})
+	}
+
+	return logs, nil
+}
@@ -0,0 +1,61 @@
+package parser_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/m-mizutani/rlogs/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAzureActivityLogParserRecords(t *testing.T) {
+	msg := `{"records":[{"time":"2019-10-19T04:44:44.1234567Z","resourceId":"/SUBSCRIPTIONS/0000/RESOURCEGROUPS/RG/PROVIDERS/MICROSOFT.COMPUTE/VIRTUALMACHINES/VM1","operationName":"MICROSOFT.COMPUTE/VIRTUALMACHINES/WRITE","category":"Administrative","resultType":"Success","resultSignature":"Succeeded.Created","durationMs":"1234","callerIpAddress":"203.0.113.1","correlationId":"c1","identity":{"authorization":{"scope":"/subscriptions/0000","action":"Microsoft.Compute/virtualMachines/write"},"claims":{"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/upn":"alice@example.com"}},"level":"Information","location":"global","properties":{"statusCode":"Created"}},{"time":"2019-10-19T04:45:00Z","resourceId":"/SUBSCRIPTIONS/0000","operationName":"MICROSOFT.AUTHORIZATION/ROLEASSIGNMENTS/DELETE","category":"Administrative","resultType":"Start","durationMs":0,"caller":"bob@example.com"}]}`
+	src := &rlogs.AwsS3LogSource{Region: "test-r", Bucket: "test-b", Key: "test-k"}
+	psr := parser.AzureActivityLog{}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(msg), Src: src})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(logs))
+	assert.Equal(t, "azure.activity", logs[0].Tag)
+	assert.Equal(t, 0, logs[0].Seq)
+	assert.Equal(t, 1, logs[1].Seq)
+	assert.Equal(t, "2019-10-19T04:44:44.1234567Z", logs[0].Timestamp.Format(time.RFC3339Nano))
+
+	r0 := logs[0].Values.(*parser.AzureActivityLogRecord)
+	assert.Equal(t, "alice@example.com", r0.Caller)
+	assert.Equal(t, "MICROSOFT.COMPUTE/VIRTUALMACHINES/WRITE", r0.OperationName)
+	assert.Equal(t, "/SUBSCRIPTIONS/0000/RESOURCEGROUPS/RG/PROVIDERS/MICROSOFT.COMPUTE/VIRTUALMACHINES/VM1", r0.ResourceID)
+	assert.Equal(t, "1234", r0.DurationMs.String())
+	assert.Equal(t, "Microsoft.Compute/virtualMachines/write", r0.Identity.Authorization.Action)
+
+	r1 := logs[1].Values.(*parser.AzureActivityLogRecord)
+	assert.Equal(t, "bob@example.com", r1.Caller)
+	assert.Equal(t, "0", r1.DurationMs.String())
+}
+
+func TestAzureActivityLogParserSingleRecord(t *testing.T) {
+	psr := parser.AzureActivityLog{}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{
+		Raw: []byte(`{"time":"2019-10-19T04:44:44Z","operationName":"MICROSOFT.STORAGE/STORAGEACCOUNTS/LISTKEYS/ACTION","identity":{"claims":{"appid":"app-1"}}}`),
+		Seq: 7,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, 7, logs[0].Seq)
+	assert.Equal(t, "app-1", logs[0].Values.(*parser.AzureActivityLogRecord).Caller)
+}
+
+func TestAzureActivityLogParserErrorCase(t *testing.T) {
+	psr := parser.AzureActivityLog{}
+
+	_, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(`{"records":{}}`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "records")
+
+	_, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte(`{"records":[{"time":"yesterday"}]}`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Fail to parse time")
+}
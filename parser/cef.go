@@ -0,0 +1,193 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/pkg/errors"
+)
+
+// CEFEvent is an event of ArcSight Common Event Format. Syslog is available
+// only if the event has syslog header prefix.
+type CEFEvent struct {
+	Version       int
+	DeviceVendor  string
+	DeviceProduct string
+	DeviceVersion string
+	SignatureID   string
+	Name          string
+	Severity      string
+	Extension     map[string]string
+	Syslog        *SyslogMessage
+}
+
+// CEF is parser of ArcSight Common Event Format (CEF). Timestamp of LogRecord
+// comes from "rt" extension, otherwise timestamp of syslog header. One line should
+// have one event, then S3LineLoader is required.
+type CEF struct {
+	// Tag of LogRecord. "cef" is used if empty.
+	Tag string
+	// Syslog is used to parse syslog header prefix. Syslog{} is used if nil.
+	Syslog *Syslog
+}
+
+var cefTimeLayouts = []string{
+	"Jan 02 2006 15:04:05.000 MST",
+	"Jan 02 2006 15:04:05.000",
+	"Jan 02 2006 15:04:05 MST",
+	"Jan 02 2006 15:04:05",
+}
+
+// Parse of CEF parses one CEF event.
+func (x *CEF) Parse(msg *rlogs.MessageQueue) ([]*rlogs.LogRecord, error) {
+	line := strings.TrimRight(string(msg.Raw), "\r\n")
+
+	pos := strings.Index(line, "CEF:")
+	if pos < 0 {
+		return nil, fmt.Errorf("CEF header is not found: %s", line)
+	}
+
+	var event CEFEvent
+	if pos > 0 {
+		hdr, err := parseSyslogHeader(x.Syslog, line[:pos])
+		if err != nil {
+			return nil, errors.Wrap(err, "Fail to parse syslog header of CEF")
+		}
+		event.Syslog = hdr
+	}
+
+	fields, ext := splitEscaped(line[pos+len("CEF:"):], '|', 7)
+	if len(fields) < 7 {
+		return nil, fmt.Errorf("CEF header requires 7 fields, but %d: %s", len(fields), line)
+	}
+
+	v, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid CEF version: %s", fields[0])
+	}
+	event.Version = v
+	event.DeviceVendor = fields[1]
+	event.DeviceProduct = fields[2]
+	event.DeviceVersion = fields[3]
+	event.SignatureID = fields[4]
+	event.Name = fields[5]
+	event.Severity = fields[6]
+	event.Extension = parseCEFExtension(ext)
+
+	var ts time.Time
+	if rt, ok := event.Extension["rt"]; ok {
+		t, err := parseCEFTime(rt)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Fail to parse rt of CEF: %s", rt)
+		}
+		ts = t
+	} else if event.Syslog != nil {
+		ts = event.Syslog.Timestamp
+	}
+
+	tag := x.Tag
+	if tag == "" {
+		tag = "cef"
+	}
+
+	return []*rlogs.LogRecord{
+		{
+			Tag:       tag,
+			Timestamp: ts.UTC(),
+			Raw:       msg.Raw,
+			Values:    &event,
+			Seq:       msg.Seq,
+			Src:       msg.Src,
+		},
+	}, nil
+}
+
+// parseSyslogHeader parses syslog header prefixed to CEF or LEEF such as
+// "<134>Feb 14 19:04:54 myhost ".
+func parseSyslogHeader(psr *Syslog, hdr string) (*SyslogMessage, error) {
+	if psr == nil {
+		psr = &Syslog{}
+	}
+	return psr.parseMessage(strings.TrimRight(hdr, " "))
+}
+
+// splitEscaped splits s by sep that is not escaped by '\' into at most n fields
+// and returns the fields and the rest. "\\" and "\<sep>" in the fields are unescaped.
+func splitEscaped(s string, sep byte, n int) ([]string, string) {
+	var fields []string
+	var buf strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && (s[i+1] == '\\' || s[i+1] == sep):
+			buf.WriteByte(s[i+1])
+			i++
+		case s[i] == sep:
+			fields = append(fields, buf.String())
+			buf.Reset()
+			if len(fields) == n {
+				return fields, s[i+1:]
+			}
+		default:
+			buf.WriteByte(s[i])
+		}
+	}
+
+	return append(fields, buf.String()), ""
+}
+
+// parseCEFExtension parses "key1=value1 key2=value with space". A key is a token
+// just before unescaped '=' and the value continues until the next key.
+func parseCEFExtension(ext string) map[string]string {
+	type pair struct{ keyStart, eq int }
+	var pairs []pair
+
+	for i := 0; i < len(ext); i++ {
+		if ext[i] == '\\' {
+			i++
+			continue
+		}
+		if ext[i] == '=' {
+			start := strings.LastIndexByte(ext[:i], ' ') + 1
+			if len(pairs) > 0 && start <= pairs[len(pairs)-1].eq {
+				continue // '=' in value without escape and no key
+			}
+			pairs = append(pairs, pair{keyStart: start, eq: i})
+		}
+	}
+
+	values := map[string]string{}
+	for i, p := range pairs {
+		end := len(ext)
+		if i+1 < len(pairs) {
+			end = pairs[i+1].keyStart
+		}
+		key := ext[p.keyStart:p.eq]
+		values[key] = unescapeCEFValue(strings.TrimRight(ext[p.eq+1:end], " "))
+	}
+
+	return values
+}
+
+var cefValueUnescaper = strings.NewReplacer(`\\`, `\`, `\=`, `=`, `\|`, `|`, `\n`, "\n", `\r`, "\r")
+
+func unescapeCEFValue(s string) string {
+	return cefValueUnescaper.Replace(s)
+}
+
+func parseCEFTime(s string) (time.Time, error) {
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond)), nil
+	}
+
+	for _, layout := range cefTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("Unsupported time format: %s", s)
+}
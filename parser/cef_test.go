@@ -0,0 +1,81 @@
+package parser_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/m-mizutani/rlogs/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCEFParser(t *testing.T) {
+	msg := `CEF:0|Security|threatmanager|1.0|100|detected a \| in message|10|src=10.0.0.1 act=blocked a \= dst=2.1.2.2 msg=Detected a threat. No action needed. rt=1581206401123`
+	src := &rlogs.AwsS3LogSource{Region: "test-r", Bucket: "test-b", Key: "test-k"}
+	psr := parser.CEF{}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(msg), Src: src, Seq: 4})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "cef", logs[0].Tag)
+	assert.Equal(t, 4, logs[0].Seq)
+	assert.Equal(t, int64(1581206401), logs[0].Timestamp.Unix())
+
+	event := logs[0].Values.(*parser.CEFEvent)
+	assert.Equal(t, 0, event.Version)
+	assert.Equal(t, "Security", event.DeviceVendor)
+	assert.Equal(t, "threatmanager", event.DeviceProduct)
+	assert.Equal(t, "1.0", event.DeviceVersion)
+	assert.Equal(t, "100", event.SignatureID)
+	assert.Equal(t, "detected a | in message", event.Name)
+	assert.Equal(t, "10", event.Severity)
+	assert.Equal(t, "10.0.0.1", event.Extension["src"])
+	assert.Equal(t, "blocked a =", event.Extension["act"])
+	assert.Equal(t, "2.1.2.2", event.Extension["dst"])
+	assert.Equal(t, "Detected a threat. No action needed.", event.Extension["msg"])
+	assert.Nil(t, event.Syslog)
+}
+
+func TestCEFParserWithSyslogHeader(t *testing.T) {
+	msg := `<134>Feb 14 19:04:54 fw01 CEF:0|Vendor|Firewall|2.3|deny|Traffic denied|5|request=https://example.com/?a=b suser=alice`
+	psr := parser.CEF{Tag: "fw", Syslog: &parser.Syslog{Year: 2020}}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(msg)})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "fw", logs[0].Tag)
+	assert.Equal(t, "2020-02-14T19:04:54Z", logs[0].Timestamp.Format(time.RFC3339))
+
+	event := logs[0].Values.(*parser.CEFEvent)
+	require.NotNil(t, event.Syslog)
+	assert.Equal(t, "fw01", event.Syslog.Hostname)
+	assert.Equal(t, "local0", event.Syslog.FacilityName)
+	assert.Equal(t, "https://example.com/?a=b", event.Extension["request"])
+	assert.Equal(t, "alice", event.Extension["suser"])
+}
+
+func TestCEFParserTimeFormat(t *testing.T) {
+	msg := `CEF:0|Vendor|Proxy|1.0|1|Access|3|rt=Sep 19 2019 08:26:10 dhost=example.com`
+	psr := parser.CEF{}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(msg)})
+	require.NoError(t, err)
+	assert.Equal(t, "2019-09-19T08:26:10Z", logs[0].Timestamp.Format(time.RFC3339))
+}
+
+func TestCEFParserErrorCase(t *testing.T) {
+	psr := parser.CEF{}
+
+	_, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(`not cef`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "CEF header is not found")
+
+	_, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte(`CEF:0|Vendor|Proxy|1.0|1`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "requires 7 fields")
+
+	_, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte(`CEF:0|Vendor|Proxy|1.0|1|Access|3|rt=yesterday`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Fail to parse rt")
+}
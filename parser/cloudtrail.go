@@ -8,10 +8,6 @@ import (
 	"github.com/pkg/errors"
 )
 
-type cloudTrailEventWrapper struct {
-	Records []interface{} `json:"Records"`
-}
-
 type CloudTrailRecord map[string]interface{}
 
 // CloudTrail is parser of AWS CloudTrail logs.
@@ -21,26 +17,22 @@ type CloudTrail struct{}
 func (x *CloudTrail) Parse(msg *rlogs.MessageQueue) ([]*rlogs.LogRecord, error) {
 	var logs []*rlogs.LogRecord
 
-	var event cloudTrailEventWrapper
-	if err := json.Unmarshal(msg.Raw, &event); err != nil {
+	records, err := unwrapRecords(msg.Raw, "Records")
+	if err != nil {
 		return nil, errors.Wrap(err, "Fail to parse CloudTrail logs")
 	}
 
-	for idx, v := range event.Records {
-		logmsg, err := json.Marshal(v)
-		if err != nil {
-			return nil, errors.Wrapf(err, "Fail to marshal CloudTrail log [%d]", idx)
-		}
-
+	for idx, logmsg := range records {
 		var record CloudTrailRecord
 		if err := json.Unmarshal(logmsg, &record); err != nil {
-			return nil, errors.Wrapf(err, "Fail to re-unmarshal CloudTrail log: %s", string(logmsg))
+			return nil, errors.Wrapf(err, "Fail to unmarshal CloudTrail log [%d]: %s", idx, string(logmsg))
 		}
 
 		// 2018-12-18T00:07:21Z
-		ts, err := time.Parse("2006-01-02T15:04:05Z", record["eventTime"].(string))
+		eventTime, _ := record["eventTime"].(string)
+		ts, err := time.Parse("2006-01-02T15:04:05Z", eventTime)
 		if err != nil {
-			return nil, errors.Wrapf(err, "Fail to parse timestamp of CloudTrail: %v", record["eventTime"].(string))
+			return nil, errors.Wrapf(err, "Fail to parse timestamp of CloudTrail: %v", record["eventTime"])
 		}
 
 		log := rlogs.LogRecord{
@@ -0,0 +1,187 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/pkg/errors"
+)
+
+// CSV is parser of CSV/TSV logs. Column names come from Columns or a header row
+// (Header = true) such as VpcFlowLogs. Parsed record is map[string]interface{}
+// by column name. S3CSVLoader is required because a quoted field can have line
+// breaks. Seq of the first record in an object must be 0 to detect start of the
+// object.
+type CSV struct {
+	Tag string
+	// Delimiter of fields. ',' is used if zero. Use '\t' for TSV.
+	Delimiter rune
+	// Quote is quote character of field. '"' is used if zero.
+	Quote rune
+	// Comment is prefix of comment line. Comment lines are ignored if not zero.
+	Comment rune
+	// SkipLines is number of records to be ignored at beginning of an object.
+	SkipLines int
+	// Header indicates first record (after SkipLines and comments) is header row.
+	Header bool
+	// Columns is column names. It's used if Header is false.
+	Columns []string
+	// Types specifies type conversion by column name. Available types are
+	// "string" (default), "int", "float" and "bool".
+	Types           map[string]string
+	TimestampField  *string
+	TimestampFormat *string
+
+	header []string
+	// ready is true after configuration is validated
+	ready        bool
+	delim, quote rune
+}
+
+// setup validates configuration and sets default values at the first Parse.
+func (x *CSV) setup() error {
+	if x.ready {
+		return nil
+	}
+
+	if err := validateValueTypes(x.Types); err != nil {
+		return err
+	}
+	if x.TimestampField != nil && x.TimestampFormat == nil {
+		return fmt.Errorf("TimestampFormat is required, but not set")
+	}
+
+	x.delim, x.quote = x.Delimiter, x.Quote
+	if x.delim == 0 {
+		x.delim = ','
+	}
+	if x.quote == 0 {
+		x.quote = '"'
+	}
+
+	x.ready = true
+	return nil
+}
+
+// Parse of CSV parses one CSV record. Header row, skipped records, comment lines
+// and empty lines return no LogRecord.
+func (x *CSV) Parse(msg *rlogs.MessageQueue) ([]*rlogs.LogRecord, error) {
+	if err := x.setup(); err != nil {
+		return nil, err
+	}
+
+	if msg.Seq == 0 {
+		x.header = nil
+	}
+	if msg.Seq < x.SkipLines {
+		return nil, nil
+	}
+
+	raw := string(msg.Raw)
+	if raw == "" {
+		return nil, nil
+	}
+	if x.Comment != 0 && strings.HasPrefix(raw, string(x.Comment)) {
+		return nil, nil
+	}
+
+	row, err := splitCSVRecord(raw, x.delim, x.quote)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Fail to parse CSV record: %s", raw)
+	}
+
+	columns := x.Columns
+	if x.Header {
+		if x.header == nil {
+			x.header = row
+			return nil, nil // Skip header
+		}
+		columns = x.header
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("Either one of Header and Columns is required")
+	}
+
+	if len(row) != len(columns) {
+		return nil, fmt.Errorf("Invalid row length (expected %d, but %d)", len(columns), len(row))
+	}
+
+	values := map[string]interface{}{}
+	for i, col := range columns {
+		v, err := convertValue(row[i], x.Types[col])
+		if err != nil {
+			return nil, errors.Wrapf(err, "Fail to convert column '%s'", col)
+		}
+		values[col] = v
+	}
+
+	var t time.Time
+	if x.TimestampField != nil {
+		p, err := parseTimestampValue(values, *x.TimestampField, *x.TimestampFormat)
+		if err != nil {
+			return nil, err
+		}
+		t = p
+	}
+
+	return []*rlogs.LogRecord{
+		{
+			Tag:       x.Tag,
+			Timestamp: t,
+			Raw:       msg.Raw,
+			Values:    values,
+			Seq:       msg.Seq,
+			Src:       msg.Src,
+		},
+	}, nil
+}
+
+// splitCSVRecord splits a record of RFC 4180 with delimiter and quote. A quote in
+// quoted field is escaped by doubling it.
+func splitCSVRecord(record string, delim, quote rune) ([]string, error) {
+	var fields []string
+	var buf strings.Builder
+
+	s := []rune(record)
+	for i := 0; i <= len(s); {
+		buf.Reset()
+
+		if i < len(s) && s[i] == quote {
+			i++
+			closed := false
+			for i < len(s) {
+				if s[i] == quote {
+					if i+1 < len(s) && s[i+1] == quote {
+						buf.WriteRune(quote)
+						i += 2
+						continue
+					}
+					i++
+					closed = true
+					break
+				}
+				buf.WriteRune(s[i])
+				i++
+			}
+
+			if !closed {
+				return nil, fmt.Errorf("Unterminated quoted field")
+			}
+			if i < len(s) && s[i] != delim {
+				return nil, fmt.Errorf("Unexpected character after quoted field at %d", i)
+			}
+		} else {
+			for i < len(s) && s[i] != delim {
+				buf.WriteRune(s[i])
+				i++
+			}
+		}
+
+		fields = append(fields, buf.String())
+		i++ // skip delimiter
+	}
+
+	return fields, nil
+}
@@ -0,0 +1,134 @@
+package parser_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/m-mizutani/rlogs/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSVParserHeader(t *testing.T) {
+	lines := []string{
+		`# exported by SaaS`,
+		`time,user,action,"bytes",note`,
+		`2019-10-19 04:44:44,alice,download,1024,"multi` + "\n" + `line, ""quoted"" note"`,
+		`2019-10-19 04:45:00,bob,upload,2048,`,
+	}
+	src := &rlogs.AwsS3LogSource{Region: "test-r", Bucket: "test-b", Key: "test-k"}
+	psr := parser.CSV{
+		Tag:             "saas",
+		Comment:         '#',
+		Header:          true,
+		Types:           map[string]string{"bytes": "int"},
+		TimestampField:  rlogs.String("time"),
+		TimestampFormat: rlogs.String("2006-01-02 15:04:05"),
+	}
+
+	var logs []*rlogs.LogRecord
+	for i, line := range lines {
+		r, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(line), Src: src, Seq: i})
+		require.NoError(t, err)
+		logs = append(logs, r...)
+	}
+
+	require.Equal(t, 2, len(logs))
+	assert.Equal(t, "saas", logs[0].Tag)
+	assert.Equal(t, 2, logs[0].Seq)
+	assert.Equal(t, "2019-10-19T04:44:44Z", logs[0].Timestamp.Format(time.RFC3339))
+
+	v0 := logs[0].Values.(map[string]interface{})
+	assert.Equal(t, "alice", v0["user"])
+	assert.Equal(t, int64(1024), v0["bytes"])
+	assert.Equal(t, "multi\nline, \"quoted\" note", v0["note"])
+
+	v1 := logs[1].Values.(map[string]interface{})
+	assert.Equal(t, "bob", v1["user"])
+	assert.Equal(t, "", v1["note"])
+}
+
+func TestCSVParserColumnsTSV(t *testing.T) {
+	lines := []string{
+		"Report generated at 2019-10-19",
+		"Ignored line",
+		"1571460284\t10.0.0.1\ttrue",
+	}
+	psr := parser.CSV{
+		Delimiter: '\t',
+		Quote:     '\'',
+		SkipLines: 2,
+		Columns:   []string{"ts", "src", "blocked"},
+		Types:     map[string]string{"blocked": "bool"},
+	}
+
+	var logs []*rlogs.LogRecord
+	for i, line := range lines {
+		r, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(line), Seq: i})
+		require.NoError(t, err)
+		logs = append(logs, r...)
+	}
+
+	require.Equal(t, 1, len(logs))
+	assert.True(t, logs[0].Timestamp.IsZero())
+	v := logs[0].Values.(map[string]interface{})
+	assert.Equal(t, "1571460284", v["ts"])
+	assert.Equal(t, "10.0.0.1", v["src"])
+	assert.Equal(t, true, v["blocked"])
+}
+
+func TestCSVParserHeaderForEachObject(t *testing.T) {
+	psr := parser.CSV{Header: true}
+
+	// 1st object
+	_, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte("a,b"), Seq: 0})
+	require.NoError(t, err)
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte("1,2"), Seq: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "2", logs[0].Values.(map[string]interface{})["b"])
+
+	// 2nd object has other header
+	_, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte("c,d"), Seq: 0})
+	require.NoError(t, err)
+	logs, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte("3,4"), Seq: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "4", logs[0].Values.(map[string]interface{})["d"])
+}
+
+func TestCSVParserErrorCase(t *testing.T) {
+	testCases := []struct {
+		psr    *parser.CSV
+		line   string
+		errMsg string
+	}{
+		{&parser.CSV{}, "a,b", "Either one of Header and Columns is required"},
+		{&parser.CSV{Columns: []string{"a", "b"}}, "1,2,3", "Invalid row length"},
+		{&parser.CSV{Columns: []string{"a"}}, `"abc`, "Unterminated quoted field"},
+		{&parser.CSV{Columns: []string{"a", "b"}}, `"abc"d,e`, "Unexpected character"},
+		{&parser.CSV{Columns: []string{"a"}, Types: map[string]string{"a": "int"}}, "x", "Fail to convert column 'a'"},
+		{&parser.CSV{Columns: []string{"a"}, Types: map[string]string{"a": "date"}}, "x", "Unsupported type"},
+		{&parser.CSV{Columns: []string{"a"}, TimestampField: rlogs.String("a")}, "x", "TimestampFormat is required"},
+	}
+
+	for _, tc := range testCases {
+		_, err := tc.psr.Parse(&rlogs.MessageQueue{Raw: []byte(tc.line)})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), tc.errMsg)
+	}
+}
+
+func TestCSVParserEmptyLine(t *testing.T) {
+	lines := []string{"color,note", "", "blue,sky", ""}
+	psr := parser.CSV{Header: true}
+
+	var logs []*rlogs.LogRecord
+	for i, line := range lines {
+		r, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(line), Seq: i})
+		require.NoError(t, err)
+		logs = append(logs, r...)
+	}
+
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "blue", logs[0].Values.(map[string]interface{})["color"])
+}
@@ -0,0 +1,105 @@
+package parser
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/pkg/errors"
+)
+
+// GCPAuthenticationInfo is authentication information of the caller.
+type GCPAuthenticationInfo struct {
+	PrincipalEmail               string                   `json:"principalEmail"`
+	PrincipalSubject             string                   `json:"principalSubject,omitempty"`
+	ServiceAccountKeyName        string                   `json:"serviceAccountKeyName,omitempty"`
+	ServiceAccountDelegationInfo []map[string]interface{} `json:"serviceAccountDelegationInfo,omitempty"`
+}
+
+// GCPAuthorizationInfo is authorization information for the operation.
+type GCPAuthorizationInfo struct {
+	Resource   string `json:"resource"`
+	Permission string `json:"permission"`
+	Granted    bool   `json:"granted"`
+}
+
+// GCPRequestMetadata is metadata of the request.
+type GCPRequestMetadata struct {
+	CallerIP                string `json:"callerIp"`
+	CallerSuppliedUserAgent string `json:"callerSuppliedUserAgent"`
+	CallerNetwork           string `json:"callerNetwork,omitempty"`
+}
+
+// GCPStatus is status of the operation.
+type GCPStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// GCPAuditLogPayload is protoPayload of Cloud Audit Logs (google.cloud.audit.AuditLog).
+type GCPAuditLogPayload struct {
+	Type               string                 `json:"@type"`
+	ServiceName        string                 `json:"serviceName"`
+	MethodName         string                 `json:"methodName"`
+	ResourceName       string                 `json:"resourceName"`
+	AuthenticationInfo GCPAuthenticationInfo  `json:"authenticationInfo"`
+	AuthorizationInfo  []GCPAuthorizationInfo `json:"authorizationInfo,omitempty"`
+	RequestMetadata    GCPRequestMetadata     `json:"requestMetadata"`
+	Status             *GCPStatus             `json:"status,omitempty"`
+	Request            map[string]interface{} `json:"request,omitempty"`
+	Response           map[string]interface{} `json:"response,omitempty"`
+	Metadata           map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// GCPMonitoredResource is the resource that produced the log entry.
+type GCPMonitoredResource struct {
+	Type   string            `json:"type"`
+	Labels map[string]string `json:"labels"`
+}
+
+// GCPAuditLogEntry is LogEntry of Cloud Audit Logs.
+type GCPAuditLogEntry struct {
+	LogName          string                 `json:"logName"`
+	InsertID         string                 `json:"insertId"`
+	Severity         string                 `json:"severity"`
+	Timestamp        string                 `json:"timestamp"`
+	ReceiveTimestamp string                 `json:"receiveTimestamp"`
+	Resource         GCPMonitoredResource   `json:"resource"`
+	ProtoPayload     GCPAuditLogPayload     `json:"protoPayload"`
+	Operation        map[string]interface{} `json:"operation,omitempty"`
+}
+
+// GCPAuditLog is parser of Google Cloud Audit Logs exported as LogEntry JSON.
+// Timestamp comes from timestamp, otherwise receiveTimestamp. One line should
+// have one LogEntry, then S3LineLoader is required.
+type GCPAuditLog struct{}
+
+// Parse of GCPAuditLog parses one LogEntry of Cloud Audit Logs.
+func (x *GCPAuditLog) Parse(msg *rlogs.MessageQueue) ([]*rlogs.LogRecord, error) {
+	var entry GCPAuditLogEntry
+	if err := json.Unmarshal(msg.Raw, &entry); err != nil {
+		return nil, errors.Wrapf(err, "Fail to parse GCP audit log: %s", string(msg.Raw))
+	}
+
+	tsField := entry.Timestamp
+	if tsField == "" {
+		tsField = entry.ReceiveTimestamp
+	}
+
+	// 2019-10-19T04:44:44.123456789Z
+	ts, err := time.Parse(time.RFC3339Nano, tsField)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Fail to parse timestamp of GCP audit log: %v", tsField)
+	}
+
+	return []*rlogs.LogRecord{
+		{
+			Tag:       "gcp.audit",
+			Timestamp: ts.UTC(),
+			Raw:       msg.Raw,
+			Values:    &entry,
+			Seq:       msg.Seq,
+			Src:       msg.Src,
+		},
+	}, nil
+}
@@ -0,0 +1,47 @@
+package parser_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/m-mizutani/rlogs/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGCPAuditLogParser(t *testing.T) {
+	msg := `{"protoPayload":{"@type":"type.googleapis.com/google.cloud.audit.AuditLog","status":{},"authenticationInfo":{"principalEmail":"alice@example.com"},"requestMetadata":{"callerIp":"203.0.113.1","callerSuppliedUserAgent":"google-cloud-sdk gcloud/268.0.0"},"serviceName":"storage.googleapis.com","methodName":"storage.buckets.create","authorizationInfo":[{"resource":"projects/_/buckets/my-bucket","permission":"storage.buckets.create","granted":true}],"resourceName":"projects/_/buckets/my-bucket"},"insertId":"-abcdef1234","resource":{"type":"gcs_bucket","labels":{"bucket_name":"my-bucket","project_id":"my-project","location":"us"}},"timestamp":"2019-10-19T04:44:44.123456789Z","severity":"NOTICE","logName":"projects/my-project/logs/cloudaudit.googleapis.com%2Factivity","receiveTimestamp":"2019-10-19T04:44:45.000000000Z"}`
+	src := &rlogs.AwsS3LogSource{Region: "test-r", Bucket: "test-b", Key: "test-k"}
+	psr := parser.GCPAuditLog{}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(msg), Src: src, Seq: 1})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "gcp.audit", logs[0].Tag)
+	assert.Equal(t, "2019-10-19T04:44:44.123456789Z", logs[0].Timestamp.Format(time.RFC3339Nano))
+
+	entry := logs[0].Values.(*parser.GCPAuditLogEntry)
+	assert.Equal(t, "NOTICE", entry.Severity)
+	assert.Equal(t, "gcs_bucket", entry.Resource.Type)
+	assert.Equal(t, "my-project", entry.Resource.Labels["project_id"])
+	assert.Equal(t, "alice@example.com", entry.ProtoPayload.AuthenticationInfo.PrincipalEmail)
+	assert.Equal(t, "storage.buckets.create", entry.ProtoPayload.MethodName)
+	assert.Equal(t, "projects/_/buckets/my-bucket", entry.ProtoPayload.ResourceName)
+	assert.Equal(t, "203.0.113.1", entry.ProtoPayload.RequestMetadata.CallerIP)
+	require.Equal(t, 1, len(entry.ProtoPayload.AuthorizationInfo))
+	assert.True(t, entry.ProtoPayload.AuthorizationInfo[0].Granted)
+}
+
+func TestGCPAuditLogParserReceiveTimestamp(t *testing.T) {
+	psr := parser.GCPAuditLog{}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(`{"protoPayload":{"methodName":"v1.compute.instances.delete"},"receiveTimestamp":"2019-10-19T04:44:45Z"}`)})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, 45, logs[0].Timestamp.Second())
+
+	_, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte(`{"protoPayload":{}}`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Fail to parse timestamp")
+}
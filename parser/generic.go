@@ -2,15 +2,12 @@ package parser
 
 import (
 	"encoding/json"
-	"fmt"
-	"strconv"
-	"time"
 
 	"github.com/m-mizutani/rlogs"
 	"github.com/pkg/errors"
 )
 
-// JSON is basic json log parser.
+// JSON is basic json log parser. Timestamp fields are same as TimestampOptions.
 type JSON struct {
 	Tag                 string
 	UnixtimeField       *string
@@ -29,48 +26,16 @@ func (x *JSON) Parse(msg *rlogs.MessageQueue) ([]*rlogs.LogRecord, error) {
 		return nil, errors.Wrapf(err, "Fail to unmarshal log message: %v", msg)
 	}
 
-	var t time.Time
-	switch {
-	case x.UnixtimeField != nil:
-		if ts, ok := value[*x.UnixtimeField].(float64); ok {
-			t = time.Unix(int64(ts), 0).UTC()
-		} else {
-			return nil, fmt.Errorf("No unixtime field (%s): %v", *x.UnixtimeField, value)
-		}
-
-	case x.UnixtimeMilliField != nil:
-		if ts, ok := value[*x.UnixtimeMilliField].(float64); ok {
-			t = time.Unix(int64(ts)/1000, (int64(ts)%1000)*1000).UTC()
-		} else {
-			return nil, fmt.Errorf("No unixtime milliseconds field (%s): %v", *x.UnixtimeMilliField, value)
-		}
-
-	case x.UnixtimeStringField != nil:
-		if str, ok := value[*x.UnixtimeStringField].(string); ok {
-			ts, err := strconv.ParseInt(str, 10, 64)
-			if err != nil {
-				return nil, errors.Wrapf(err, "Fail to parse UnixTimeString: %v", str)
-			}
-			t = time.Unix(int64(ts), 0).UTC()
-		} else {
-			return nil, fmt.Errorf("No unixtime milliseconds field (%s): %v", *x.UnixtimeStringField, value)
-		}
-
-	case x.TimestampField != nil:
-		if x.TimestampFormat == nil {
-			return nil, fmt.Errorf("TimestampFormat is required, but not set")
-		}
-
-		if ts, ok := value[*x.TimestampField].(string); ok {
-			if p, err := time.Parse(*x.TimestampFormat, ts); err == nil {
-				t = p.UTC()
-			} else {
-				return nil, errors.Wrapf(err, "Fail to parse timestamp field by format '%s': %v", *x.TimestampFormat, value)
-			}
-		}
-
-	default:
-		return nil, fmt.Errorf("No timestamp field arguments. One of UnixtimeField, UnixtimeMilliField and TimestampField is required")
+	opt := TimestampOptions{
+		UnixtimeField:       x.UnixtimeField,
+		UnixtimeStringField: x.UnixtimeStringField,
+		UnixtimeMilliField:  x.UnixtimeMilliField,
+		TimestampField:      x.TimestampField,
+		TimestampFormat:     x.TimestampFormat,
+	}
+	t, err := opt.extract(value, false)
+	if err != nil {
+		return nil, err
 	}
 
 	return []*rlogs.LogRecord{
@@ -2,6 +2,7 @@ package parser_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/m-mizutani/rlogs"
 	"github.com/m-mizutani/rlogs/parser"
@@ -87,3 +88,29 @@ func TestJSONParserNoTimestampField(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "No timestamp field arguments")
 }
+
+func TestJSONParserUnixtimeMilliSecondsFraction(t *testing.T) {
+	psr := parser.JSON{
+		UnixtimeMilliField: rlogs.String("unix"),
+	}
+	logs, err := psr.Parse(&rlogs.MessageQueue{
+		Raw: []byte(`{"color":"blue","unix":1571630400123}`),
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, int64(1571630400), logs[0].Timestamp.Unix())
+	assert.Equal(t, 123*time.Millisecond, time.Duration(logs[0].Timestamp.Nanosecond()))
+}
+
+func TestJSONParserUnixtimeNumberString(t *testing.T) {
+	psr := parser.JSON{
+		UnixtimeField: rlogs.String("unix"),
+	}
+	_, err := psr.Parse(&rlogs.MessageQueue{
+		Raw: []byte(`{"color":"blue","unix":"1571630400"}`),
+	})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "No unixtime field")
+}
@@ -0,0 +1,76 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/pkg/errors"
+)
+
+// GitHubActorLocation is location of the actor.
+type GitHubActorLocation struct {
+	CountryCode string `json:"country_code"`
+}
+
+// GitHubAuditEvent is an event of GitHub audit log streaming. Target of the
+// action is one of Repo, User, Team and Org according to Action. Fields
+// specific to the action are available only in Raw of LogRecord.
+type GitHubAuditEvent struct {
+	Timestamp     int64                `json:"@timestamp"`
+	CreatedAt     int64                `json:"created_at"`
+	DocumentID    string               `json:"_document_id"`
+	Action        string               `json:"action"`
+	OperationType string               `json:"operation_type,omitempty"`
+	Actor         string               `json:"actor"`
+	ActorID       int64                `json:"actor_id,omitempty"`
+	ActorIP       string               `json:"actor_ip,omitempty"`
+	ActorLocation *GitHubActorLocation `json:"actor_location,omitempty"`
+	UserAgent     string               `json:"user_agent,omitempty"`
+	Business      string               `json:"business,omitempty"`
+	Org           string               `json:"org,omitempty"`
+	OrgID         int64                `json:"org_id,omitempty"`
+	Repo          string               `json:"repo,omitempty"`
+	RepoID        int64                `json:"repo_id,omitempty"`
+	User          string               `json:"user,omitempty"`
+	UserID        int64                `json:"user_id,omitempty"`
+	Team          string               `json:"team,omitempty"`
+	Visibility    string               `json:"visibility,omitempty"`
+	TokenScopes   string               `json:"token_scopes,omitempty"`
+	RequestID     string               `json:"request_id,omitempty"`
+}
+
+// GitHubAudit is parser of GitHub audit log streaming (Enterprise audit log
+// streamed to S3). One line should have one event, then S3LineLoader is required.
+type GitHubAudit struct{}
+
+// Parse of GitHubAudit parses one event and uses @timestamp (unix time in
+// milliseconds) as timestamp, otherwise created_at.
+func (x *GitHubAudit) Parse(msg *rlogs.MessageQueue) ([]*rlogs.LogRecord, error) {
+	var event GitHubAuditEvent
+	if err := json.Unmarshal(msg.Raw, &event); err != nil {
+		return nil, errors.Wrapf(err, "Fail to parse GitHub audit log: %s", string(msg.Raw))
+	}
+
+	msec := event.Timestamp
+	if msec == 0 {
+		msec = event.CreatedAt
+	}
+	if msec == 0 {
+		return nil, fmt.Errorf("No @timestamp and created_at in GitHub audit log: %s", string(msg.Raw))
+	}
+
+	ts := time.Unix(msec/1000, (msec%1000)*int64(time.Millisecond))
+
+	return []*rlogs.LogRecord{
+		{
+			Tag:       "github.audit",
+			Timestamp: ts.UTC(),
+			Raw:       msg.Raw,
+			Values:    &event,
+			Seq:       msg.Seq,
+			Src:       msg.Src,
+		},
+	}, nil
+}
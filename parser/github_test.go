@@ -0,0 +1,46 @@
+package parser_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/m-mizutani/rlogs/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitHubAuditParser(t *testing.T) {
+	msg := `{"@timestamp":1571460284123,"_document_id":"xKzD9Y3bW1","action":"repo.access","actor":"alice","actor_id":1234,"actor_ip":"203.0.113.1","actor_location":{"country_code":"JP"},"business":"example","created_at":1571460284123,"operation_type":"modify","org":"example-org","org_id":5678,"repo":"example-org/secret","repo_id":9012,"visibility":"public","user_agent":"Mozilla/5.0"}`
+	src := &rlogs.AwsS3LogSource{Region: "test-r", Bucket: "test-b", Key: "test-k"}
+	psr := parser.GitHubAudit{}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(msg), Src: src, Seq: 1})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "github.audit", logs[0].Tag)
+	assert.Equal(t, "2019-10-19T04:44:44.123Z", logs[0].Timestamp.Format(time.RFC3339Nano))
+
+	event := logs[0].Values.(*parser.GitHubAuditEvent)
+	assert.Equal(t, "repo.access", event.Action)
+	assert.Equal(t, "alice", event.Actor)
+	assert.Equal(t, "203.0.113.1", event.ActorIP)
+	require.NotNil(t, event.ActorLocation)
+	assert.Equal(t, "JP", event.ActorLocation.CountryCode)
+	assert.Equal(t, "example-org/secret", event.Repo)
+	assert.Equal(t, int64(9012), event.RepoID)
+}
+
+func TestGitHubAuditParserCreatedAt(t *testing.T) {
+	psr := parser.GitHubAudit{}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(`{"action":"org.add_member","actor":"alice","user":"bob","created_at":1571460284000}`)})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "2019-10-19T04:44:44Z", logs[0].Timestamp.Format(time.RFC3339Nano))
+	assert.Equal(t, "bob", logs[0].Values.(*parser.GitHubAuditEvent).User)
+
+	_, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte(`{"action":"org.add_member"}`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "No @timestamp")
+}
@@ -0,0 +1,135 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// grokPatterns is built-in pattern library for Grok expression. The patterns are
+// based on logstash grok-patterns and simplified for RE2 syntax.
+var grokPatterns = map[string]string{
+	"USERNAME":          `[a-zA-Z0-9._-]+`,
+	"USER":              `%{USERNAME}`,
+	"EMAILLOCALPART":    `[a-zA-Z0-9!#$%&'*+\-/=?^_{|}~]+(?:\.[a-zA-Z0-9!#$%&'*+\-/=?^_{|}~]+)*`,
+	"EMAILADDRESS":      `%{EMAILLOCALPART}@%{HOSTNAME}`,
+	"INT":               `[+-]?[0-9]+`,
+	"BASE10NUM":         `[+-]?(?:[0-9]+(?:\.[0-9]+)?|\.[0-9]+)`,
+	"NUMBER":            `%{BASE10NUM}`,
+	"BASE16NUM":         `[+-]?(?:0x)?[0-9A-Fa-f]+`,
+	"POSINT":            `[1-9][0-9]*`,
+	"NONNEGINT":         `[0-9]+`,
+	"WORD":              `\b\w+\b`,
+	"NOTSPACE":          `\S+`,
+	"SPACE":             `\s*`,
+	"DATA":              `.*?`,
+	"GREEDYDATA":        `.*`,
+	"QUOTEDSTRING":      `"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'`,
+	"UUID":              `[A-Fa-f0-9]{8}-(?:[A-Fa-f0-9]{4}-){3}[A-Fa-f0-9]{12}`,
+	"MAC":               `(?:[A-Fa-f0-9]{2}[:-]){5}[A-Fa-f0-9]{2}|(?:[A-Fa-f0-9]{4}\.){2}[A-Fa-f0-9]{4}`,
+	"IPV4":              `(?:(?:25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])\.){3}(?:25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])`,
+	"IPV6":              `(?:[0-9A-Fa-f]{0,4}:){2,7}(?:[0-9A-Fa-f]{1,4}|%{IPV4})?`,
+	"IP":                `%{IPV6}|%{IPV4}`,
+	"HOSTNAME":          `\b[0-9A-Za-z][0-9A-Za-z-]{0,62}(?:\.[0-9A-Za-z][0-9A-Za-z-]{0,62})*\.?\b`,
+	"IPORHOST":          `%{IP}|%{HOSTNAME}`,
+	"HOSTPORT":          `%{IPORHOST}:%{POSINT}`,
+	"PATH":              `(?:/[^\s?#]*)+`,
+	"URIPROTO":          `[A-Za-z][A-Za-z0-9+\-.]+`,
+	"URIHOST":           `%{IPORHOST}(?::%{POSINT})?`,
+	"URIPATH":           `(?:/[A-Za-z0-9$.+!*'(){},~:;=@#%&_\-]*)+`,
+	"URIPARAM":          `\?[A-Za-z0-9$.+!*'|(){},~@#%&/=:;_?\-\[\]<>]*`,
+	"URIPATHPARAM":      `%{URIPATH}(?:%{URIPARAM})?`,
+	"URI":               `%{URIPROTO}://(?:%{USER}(?::[^@]*)?@)?(?:%{URIHOST})?(?:%{URIPATHPARAM})?`,
+	"MONTH":             `\b(?:[Jj]an(?:uary)?|[Ff]eb(?:ruary)?|[Mm]ar(?:ch)?|[Aa]pr(?:il)?|[Mm]ay|[Jj]un(?:e)?|[Jj]ul(?:y)?|[Aa]ug(?:ust)?|[Ss]ep(?:tember)?|[Oo]ct(?:ober)?|[Nn]ov(?:ember)?|[Dd]ec(?:ember)?)\b`,
+	"MONTHNUM":          `0?[1-9]|1[0-2]`,
+	"MONTHDAY":          `(?:0[1-9])|(?:[12][0-9])|(?:3[01])|[1-9]`,
+	"DAY":               `(?:Mon(?:day)?|Tue(?:sday)?|Wed(?:nesday)?|Thu(?:rsday)?|Fri(?:day)?|Sat(?:urday)?|Sun(?:day)?)`,
+	"YEAR":              `(?:\d\d){1,2}`,
+	"HOUR":              `2[0123]|[01]?[0-9]`,
+	"MINUTE":            `[0-5][0-9]`,
+	"SECOND":            `(?:[0-5]?[0-9]|60)(?:[:.,][0-9]+)?`,
+	"TIME":              `%{HOUR}:%{MINUTE}(?::%{SECOND})?`,
+	"DATE_US":           `%{MONTHNUM}[/-]%{MONTHDAY}[/-]%{YEAR}`,
+	"DATE_EU":           `%{MONTHDAY}[./-]%{MONTHNUM}[./-]%{YEAR}`,
+	"ISO8601_TIMEZONE":  `Z|[+-]%{HOUR}(?::?%{MINUTE})`,
+	"TIMESTAMP_ISO8601": `%{YEAR}-%{MONTHNUM}-%{MONTHDAY}[T ]%{HOUR}:?%{MINUTE}(?::?%{SECOND})?(?:%{ISO8601_TIMEZONE})?`,
+	"HTTPDATE":          `%{MONTHDAY}/%{MONTH}/%{YEAR}:%{TIME} %{INT}`,
+	"SYSLOGTIMESTAMP":   `%{MONTH} +%{MONTHDAY} %{TIME}`,
+	"LOGLEVEL":          `[Aa]lert|ALERT|[Tt]race|TRACE|[Dd]ebug|DEBUG|[Nn]otice|NOTICE|[Ii]nfo|INFO|[Ww]arn(?:ing)?|WARN(?:ING)?|[Ee]rr(?:or)?|ERR(?:OR)?|[Cc]rit(?:ical)?|CRIT(?:ICAL)?|[Ff]atal|FATAL|[Ss]evere|SEVERE|[Ee]merg(?:ency)?|EMERG(?:ENCY)?`,
+}
+
+const grokMaxDepth = 16
+
+var grokReference = regexp.MustCompile(`%\{(\w+)(?::([\w.\[\]@-]+))?(?::(\w+))?\}`)
+
+// grokExpr is a Grok expression compiled to regular expression. Field names of
+// Grok may have characters that are not allowed in group name of regexp, then
+// groups are named as "g0", "g1", ... and mapped to field names by fields.
+type grokExpr struct {
+	regex  *regexp.Regexp
+	fields map[string]string
+	types  map[string]string
+}
+
+func compileGrok(expr string, custom map[string]string) (*grokExpr, error) {
+	g := &grokExpr{
+		fields: map[string]string{},
+		types:  map[string]string{},
+	}
+
+	pattern, err := g.expand(expr, custom, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	g.regex = regex
+
+	return g, nil
+}
+
+func (x *grokExpr) expand(expr string, custom map[string]string, depth int) (string, error) {
+	if depth > grokMaxDepth {
+		return "", fmt.Errorf("Too deep Grok pattern reference: %s", expr)
+	}
+
+	var result strings.Builder
+	prev := 0
+	for _, m := range grokReference.FindAllStringSubmatchIndex(expr, -1) {
+		result.WriteString(expr[prev:m[0]])
+		prev = m[1]
+
+		name := expr[m[2]:m[3]]
+		pattern, ok := custom[name]
+		if !ok {
+			pattern, ok = grokPatterns[name]
+		}
+		if !ok {
+			return "", fmt.Errorf("Grok pattern not found: %s", name)
+		}
+
+		sub, err := x.expand(pattern, custom, depth+1)
+		if err != nil {
+			return "", err
+		}
+
+		if m[4] < 0 { // no field name
+			result.WriteString("(?:" + sub + ")")
+			continue
+		}
+
+		field := expr[m[4]:m[5]]
+		group := fmt.Sprintf("g%d", len(x.fields))
+		x.fields[group] = field
+		if m[6] >= 0 {
+			x.types[field] = expr[m[6]:m[7]]
+		}
+		result.WriteString("(?P<" + group + ">" + sub + ")")
+	}
+	result.WriteString(expr[prev:])
+
+	return result.String(), nil
+}
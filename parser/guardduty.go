@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/pkg/errors"
+)
+
+// GuardDutyResource is resource information affected by the finding. Details of
+// resource depend on ResourceType, then they are kept as generic map.
+type GuardDutyResource struct {
+	ResourceType     string                 `json:"resourceType"`
+	AccessKeyDetails map[string]interface{} `json:"accessKeyDetails,omitempty"`
+	InstanceDetails  map[string]interface{} `json:"instanceDetails,omitempty"`
+	S3BucketDetails  []interface{}          `json:"s3BucketDetails,omitempty"`
+}
+
+// GuardDutyAction is action information of the finding. Details of action
+// depend on ActionType, then they are kept as generic map.
+type GuardDutyAction struct {
+	ActionType              string                 `json:"actionType"`
+	AwsAPICallAction        map[string]interface{} `json:"awsApiCallAction,omitempty"`
+	DNSRequestAction        map[string]interface{} `json:"dnsRequestAction,omitempty"`
+	NetworkConnectionAction map[string]interface{} `json:"networkConnectionAction,omitempty"`
+	PortProbeAction         map[string]interface{} `json:"portProbeAction,omitempty"`
+}
+
+// GuardDutyService is service information of the finding.
+type GuardDutyService struct {
+	ServiceName    string          `json:"serviceName"`
+	DetectorID     string          `json:"detectorId"`
+	Action         GuardDutyAction `json:"action"`
+	Archived       bool            `json:"archived"`
+	Count          int             `json:"count"`
+	EventFirstSeen string          `json:"eventFirstSeen"`
+	EventLastSeen  string          `json:"eventLastSeen"`
+	ResourceRole   string          `json:"resourceRole"`
+}
+
+// GuardDutyFinding is a finding exported to S3 by Amazon GuardDuty.
+type GuardDutyFinding struct {
+	SchemaVersion string            `json:"schemaVersion"`
+	AccountID     string            `json:"accountId"`
+	Region        string            `json:"region"`
+	Partition     string            `json:"partition"`
+	ID            string            `json:"id"`
+	Arn           string            `json:"arn"`
+	Type          string            `json:"type"`
+	Resource      GuardDutyResource `json:"resource"`
+	Service       GuardDutyService  `json:"service"`
+	Severity      float64           `json:"severity"`
+	CreatedAt     string            `json:"createdAt"`
+	UpdatedAt     string            `json:"updatedAt"`
+	Title         string            `json:"title"`
+	Description   string            `json:"description"`
+}
+
+// GuardDuty is parser of GuardDuty findings exported to AWS S3. The exported
+// object is gzipped JSON lines, then S3LineLoader is required.
+type GuardDuty struct{}
+
+// Parse of GuardDuty parses one finding and uses updatedAt as timestamp.
+func (x *GuardDuty) Parse(msg *rlogs.MessageQueue) ([]*rlogs.LogRecord, error) {
+	var finding GuardDutyFinding
+	if err := json.Unmarshal(msg.Raw, &finding); err != nil {
+		return nil, errors.Wrapf(err, "Fail to parse GuardDuty finding: %s", string(msg.Raw))
+	}
+
+	// 2020-02-11T01:23:45.678Z
+	ts, err := time.Parse(time.RFC3339Nano, finding.UpdatedAt)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Fail to parse updatedAt of GuardDuty finding: %v", finding.UpdatedAt)
+	}
+
+	return []*rlogs.LogRecord{
+		{
+			Tag:       "aws.guardduty",
+			Timestamp: ts.UTC(),
+			Raw:       msg.Raw,
+			Values:    &finding,
+			Seq:       msg.Seq,
+			Src:       msg.Src,
+		},
+	}, nil
+}
@@ -0,0 +1,43 @@
+package parser_test
+
+import (
+	"testing"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/m-mizutani/rlogs/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGuardDutyParser(t *testing.T) {
+	msg := `{"schemaVersion":"2.0","accountId":"123456789012","region":"ap-northeast-1","partition":"aws","id":"16b8a1ac5a4d5c8f0c3f3e2e4b1f0a11","arn":"arn:aws:guardduty:ap-northeast-1:123456789012:detector/abcd/finding/16b8a1ac5a4d5c8f0c3f3e2e4b1f0a11","type":"Recon:EC2/PortProbeUnprotectedPort","resource":{"resourceType":"Instance","instanceDetails":{"instanceId":"i-0123456789abcdef0"}},"service":{"serviceName":"guardduty","detectorId":"abcd","action":{"actionType":"PORT_PROBE","portProbeAction":{"blocked":false}},"archived":false,"count":12,"eventFirstSeen":"2020-02-10T01:00:00Z","eventLastSeen":"2020-02-11T01:00:00Z","resourceRole":"TARGET"},"severity":2,"createdAt":"2020-02-10T01:05:12.345Z","updatedAt":"2020-02-11T01:23:45.678Z","title":"Unprotected port on EC2 instance i-0123456789abcdef0 is being probed.","description":"EC2 instance has an unprotected port which is being probed by a known malicious host."}`
+	src := &rlogs.AwsS3LogSource{Region: "test-r", Bucket: "test-b", Key: "test-k"}
+	psr := parser.GuardDuty{}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(msg), Src: src})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "aws.guardduty", logs[0].Tag)
+	assert.Equal(t, "2020-02-11T01:23:45", logs[0].Timestamp.Format("2006-01-02T15:04:05"))
+	assert.Equal(t, src, logs[0].Src)
+
+	finding := logs[0].Values.(*parser.GuardDutyFinding)
+	assert.Equal(t, "16b8a1ac5a4d5c8f0c3f3e2e4b1f0a11", finding.ID)
+	assert.Equal(t, "Recon:EC2/PortProbeUnprotectedPort", finding.Type)
+	assert.Equal(t, 2.0, finding.Severity)
+	assert.Equal(t, "123456789012", finding.AccountID)
+	assert.Equal(t, "ap-northeast-1", finding.Region)
+	assert.Equal(t, "Instance", finding.Resource.ResourceType)
+	assert.Equal(t, "i-0123456789abcdef0", finding.Resource.InstanceDetails["instanceId"])
+	assert.Equal(t, "PORT_PROBE", finding.Service.Action.ActionType)
+	assert.NotNil(t, finding.Service.Action.PortProbeAction)
+	assert.Equal(t, 12, finding.Service.Count)
+}
+
+func TestGuardDutyParserInvalidUpdatedAt(t *testing.T) {
+	psr := parser.GuardDuty{}
+
+	_, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(`{"id":"x","updatedAt":""}`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Fail to parse updatedAt")
+}
@@ -0,0 +1,100 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/pkg/errors"
+)
+
+// GWorkspaceActivityID is unique identifier of Google Workspace activity.
+type GWorkspaceActivityID struct {
+	Time            string `json:"time"`
+	UniqueQualifier string `json:"uniqueQualifier"`
+	ApplicationName string `json:"applicationName"`
+	CustomerID      string `json:"customerId"`
+}
+
+// GWorkspaceActor is user who performed the activity.
+type GWorkspaceActor struct {
+	CallerType string `json:"callerType,omitempty"`
+	Email      string `json:"email"`
+	ProfileID  string `json:"profileId"`
+	Key        string `json:"key,omitempty"`
+}
+
+// GWorkspaceParameter is a parameter of the event. Only one of value fields
+// is set according to type of the parameter.
+type GWorkspaceParameter struct {
+	Name          string   `json:"name"`
+	Value         string   `json:"value,omitempty"`
+	IntValue      int64    `json:"intValue,string,omitempty"`
+	BoolValue     bool     `json:"boolValue,omitempty"`
+	MultiValue    []string `json:"multiValue,omitempty"`
+	MultiIntValue []string `json:"multiIntValue,omitempty"`
+}
+
+// GWorkspaceEvent is an event in the activity. Target of the event (e.g. doc_id,
+// user_email) and outcome (e.g. login_failure) are represented by Name and Parameters.
+type GWorkspaceEvent struct {
+	Type       string                `json:"type"`
+	Name       string                `json:"name"`
+	Parameters []GWorkspaceParameter `json:"parameters,omitempty"`
+}
+
+// Parameter returns the parameter that has the name, otherwise nil.
+func (x *GWorkspaceEvent) Parameter(name string) *GWorkspaceParameter {
+	for i := range x.Parameters {
+		if x.Parameters[i].Name == name {
+			return &x.Parameters[i]
+		}
+	}
+	return nil
+}
+
+// GWorkspaceActivity is an activity of Google Workspace Reports API.
+type GWorkspaceActivity struct {
+	Kind        string               `json:"kind"`
+	ETag        string               `json:"etag"`
+	ID          GWorkspaceActivityID `json:"id"`
+	Actor       GWorkspaceActor      `json:"actor"`
+	OwnerDomain string               `json:"ownerDomain"`
+	IPAddress   string               `json:"ipAddress"`
+	Events      []GWorkspaceEvent    `json:"events"`
+}
+
+// GWorkspaceReport is parser of Google Workspace Reports API activities. Tag of
+// LogRecord is "gworkspace." + applicationName, e.g. "gworkspace.login". One
+// line should have one activity, then S3LineLoader is required.
+type GWorkspaceReport struct{}
+
+// Parse of GWorkspaceReport parses one activity and uses id.time as timestamp.
+func (x *GWorkspaceReport) Parse(msg *rlogs.MessageQueue) ([]*rlogs.LogRecord, error) {
+	var activity GWorkspaceActivity
+	if err := json.Unmarshal(msg.Raw, &activity); err != nil {
+		return nil, errors.Wrapf(err, "Fail to parse Google Workspace activity: %s", string(msg.Raw))
+	}
+
+	if activity.ID.ApplicationName == "" {
+		return nil, fmt.Errorf("No id.applicationName in Google Workspace activity: %s", string(msg.Raw))
+	}
+
+	// 2019-10-19T04:44:44.123Z
+	ts, err := time.Parse(time.RFC3339Nano, activity.ID.Time)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Fail to parse id.time of Google Workspace activity: %v", activity.ID.Time)
+	}
+
+	return []*rlogs.LogRecord{
+		{
+			Tag:       "gworkspace." + activity.ID.ApplicationName,
+			Timestamp: ts.UTC(),
+			Raw:       msg.Raw,
+			Values:    &activity,
+			Seq:       msg.Seq,
+			Src:       msg.Src,
+		},
+	}, nil
+}
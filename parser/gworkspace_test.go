@@ -0,0 +1,47 @@
+package parser_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/m-mizutani/rlogs/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGWorkspaceReportParser(t *testing.T) {
+	msg := `{"kind":"admin#reports#activity","id":{"time":"2019-10-19T04:44:44.123Z","uniqueQualifier":"-1234567890","applicationName":"login","customerId":"C01abcd"},"etag":"\"abc\"","actor":{"email":"alice@example.com","profileId":"1234567890"},"ipAddress":"203.0.113.1","events":[{"type":"login","name":"login_failure","parameters":[{"name":"login_type","value":"google_password"},{"name":"login_challenge_method","multiValue":["password"]},{"name":"is_suspicious","boolValue":true},{"name":"login_timestamp","intValue":"1571460284123000"}]}]}`
+	src := &rlogs.AwsS3LogSource{Region: "test-r", Bucket: "test-b", Key: "test-k"}
+	psr := parser.GWorkspaceReport{}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(msg), Src: src, Seq: 1})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "gworkspace.login", logs[0].Tag)
+	assert.Equal(t, "2019-10-19T04:44:44.123Z", logs[0].Timestamp.Format(time.RFC3339Nano))
+
+	activity := logs[0].Values.(*parser.GWorkspaceActivity)
+	assert.Equal(t, "alice@example.com", activity.Actor.Email)
+	assert.Equal(t, "203.0.113.1", activity.IPAddress)
+	require.Equal(t, 1, len(activity.Events))
+	event := activity.Events[0]
+	assert.Equal(t, "login_failure", event.Name)
+	assert.Equal(t, "google_password", event.Parameter("login_type").Value)
+	assert.Equal(t, []string{"password"}, event.Parameter("login_challenge_method").MultiValue)
+	assert.True(t, event.Parameter("is_suspicious").BoolValue)
+	assert.Equal(t, int64(1571460284123000), event.Parameter("login_timestamp").IntValue)
+	assert.Nil(t, event.Parameter("no_such_param"))
+}
+
+func TestGWorkspaceReportParserErrorCase(t *testing.T) {
+	psr := parser.GWorkspaceReport{}
+
+	_, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(`{"id":{"time":"2019-10-19T04:44:44.123Z"}}`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "No id.applicationName")
+
+	_, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte(`{"id":{"time":"2019/10/19","applicationName":"drive"}}`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Fail to parse id.time")
+}
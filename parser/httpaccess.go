@@ -0,0 +1,210 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/pkg/errors"
+)
+
+const (
+	// HTTPAccessCommon is Common Log Format in nginx log_format style.
+	HTTPAccessCommon = `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent`
+	// HTTPAccessCombined is Combined Log Format in nginx log_format style.
+	HTTPAccessCombined = `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent"`
+)
+
+// HTTPAccessLog is an access log record of HTTP server such as Apache and nginx.
+// Variables that are not mapped to a field are stored in Custom. "-" is converted
+// to empty string (or 0 for number).
+type HTTPAccessLog struct {
+	RemoteAddr string
+	RemoteUser string
+	Time       time.Time
+	Method     string
+	Path       string
+	Protocol   string
+	Status     int
+	Bytes      int64
+	Referer    string
+	UserAgent  string
+	Custom     map[string]string
+}
+
+// HTTPAccess is parser of HTTP server access logs. Format is nginx style
+// log_format string (e.g. `$remote_addr [$time_local] "$request"`) and
+// HTTPAccessCombined is used if empty. One line should have one record, then
+// S3LineLoader is required.
+type HTTPAccess struct {
+	// Tag of LogRecord. "http.access" is used if empty.
+	Tag string
+	// Format is nginx style log_format string.
+	Format string
+
+	once  sync.Once
+	regex *regexp.Regexp
+	err   error
+}
+
+var httpAccessVariable = regexp.MustCompile(`\$(?:\{(\w+)\}|(\w+))`)
+
+// compileHTTPAccessFormat converts log_format string to regular expression
+// that has named groups for variables.
+func compileHTTPAccessFormat(format string) (*regexp.Regexp, error) {
+	var expr strings.Builder
+	expr.WriteString("^")
+
+	vars := map[string]bool{}
+	prev := 0
+	for _, m := range httpAccessVariable.FindAllStringSubmatchIndex(format, -1) {
+		expr.WriteString(regexp.QuoteMeta(format[prev:m[0]]))
+
+		var name string
+		if m[2] >= 0 { // ${name}
+			name = format[m[2]:m[3]]
+		} else { // $name
+			name = format[m[4]:m[5]]
+		}
+		if vars[name] {
+			return nil, fmt.Errorf("Duplicated variable in log_format: $%s", name)
+		}
+		vars[name] = true
+
+		expr.WriteString("(?P<" + name + ">.*?)")
+		prev = m[1]
+	}
+	expr.WriteString(regexp.QuoteMeta(format[prev:]))
+	expr.WriteString("$")
+
+	if len(vars) == 0 {
+		return nil, fmt.Errorf("No variable in log_format: %s", format)
+	}
+
+	return regexp.Compile(expr.String())
+}
+
+// Parse of HTTPAccess parses one access log record.
+func (x *HTTPAccess) Parse(msg *rlogs.MessageQueue) ([]*rlogs.LogRecord, error) {
+	x.once.Do(func() {
+		format := x.Format
+		if format == "" {
+			format = HTTPAccessCombined
+		}
+		x.regex, x.err = compileHTTPAccessFormat(format)
+	})
+	if x.err != nil {
+		return nil, errors.Wrap(x.err, "Fail to compile log_format")
+	}
+
+	line := strings.TrimRight(string(msg.Raw), "\r\n")
+	match := x.regex.FindStringSubmatch(line)
+	if match == nil {
+		return nil, fmt.Errorf("Log does not match with log_format: %s", line)
+	}
+
+	log := HTTPAccessLog{Custom: map[string]string{}}
+	for i, name := range x.regex.SubexpNames() {
+		if i == 0 {
+			continue
+		}
+		if err := log.set(name, match[i]); err != nil {
+			return nil, errors.Wrapf(err, "Fail to parse $%s of access log: %s", name, line)
+		}
+	}
+
+	tag := x.Tag
+	if tag == "" {
+		tag = "http.access"
+	}
+
+	return []*rlogs.LogRecord{
+		{
+			Tag:       tag,
+			Timestamp: log.Time.UTC(),
+			Raw:       msg.Raw,
+			Values:    &log,
+			Seq:       msg.Seq,
+			Src:       msg.Src,
+		},
+	}, nil
+}
+
+func (x *HTTPAccessLog) set(name, value string) error {
+	if value == "-" {
+		value = ""
+	}
+
+	switch name {
+	case "remote_addr":
+		x.RemoteAddr = value
+	case "remote_user":
+		x.RemoteUser = value
+	case "time_local":
+		t, err := time.Parse("02/Jan/2006:15:04:05 -0700", value)
+		if err != nil {
+			return err
+		}
+		x.Time = t
+	case "time_iso8601":
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return err
+		}
+		x.Time = t
+	case "msec":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		x.Time = time.Unix(0, int64(math.Round(f*1000))*int64(time.Millisecond))
+	case "request":
+		// e.g. "GET /index.html HTTP/1.1"
+		parts := strings.SplitN(value, " ", 3)
+		if len(parts) >= 2 {
+			x.Method, x.Path = parts[0], parts[1]
+		} else {
+			x.Path = value
+		}
+		if len(parts) == 3 {
+			x.Protocol = parts[2]
+		}
+	case "request_method":
+		x.Method = value
+	case "request_uri", "uri":
+		x.Path = value
+	case "server_protocol":
+		x.Protocol = value
+	case "status":
+		if value == "" {
+			return nil
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		x.Status = n
+	case "body_bytes_sent", "bytes_sent":
+		if value == "" {
+			return nil
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		x.Bytes = n
+	case "http_referer":
+		x.Referer = value
+	case "http_user_agent":
+		x.UserAgent = value
+	default:
+		x.Custom[name] = value
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package parser_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/m-mizutani/rlogs/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPAccessParserCombined(t *testing.T) {
+	msg := `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08 [en] (Win98; I ;Nav)"`
+	src := &rlogs.AwsS3LogSource{Region: "test-r", Bucket: "test-b", Key: "test-k"}
+	psr := parser.HTTPAccess{}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(msg), Src: src, Seq: 5})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "http.access", logs[0].Tag)
+	assert.Equal(t, 5, logs[0].Seq)
+	assert.Equal(t, "2000-10-10T20:55:36Z", logs[0].Timestamp.Format(time.RFC3339))
+
+	log := logs[0].Values.(*parser.HTTPAccessLog)
+	assert.Equal(t, "127.0.0.1", log.RemoteAddr)
+	assert.Equal(t, "frank", log.RemoteUser)
+	_, offset := log.Time.Zone()
+	assert.Equal(t, -7*60*60, offset)
+	assert.Equal(t, "GET", log.Method)
+	assert.Equal(t, "/apache_pb.gif", log.Path)
+	assert.Equal(t, "HTTP/1.0", log.Protocol)
+	assert.Equal(t, 200, log.Status)
+	assert.Equal(t, int64(2326), log.Bytes)
+	assert.Equal(t, "http://www.example.com/start.html", log.Referer)
+	assert.Equal(t, "Mozilla/4.08 [en] (Win98; I ;Nav)", log.UserAgent)
+	assert.Equal(t, 0, len(log.Custom))
+}
+
+func TestHTTPAccessParserCommon(t *testing.T) {
+	msg := `10.0.0.8 - - [19/Oct/2019:04:44:44 +0000] "POST /login HTTP/1.1" 302 -`
+	psr := parser.HTTPAccess{Tag: "web", Format: parser.HTTPAccessCommon}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(msg)})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "web", logs[0].Tag)
+
+	log := logs[0].Values.(*parser.HTTPAccessLog)
+	assert.Equal(t, "", log.RemoteUser)
+	assert.Equal(t, "POST", log.Method)
+	assert.Equal(t, 302, log.Status)
+	assert.Equal(t, int64(0), log.Bytes)
+}
+
+func TestHTTPAccessParserCustomFormat(t *testing.T) {
+	msg := `2019-10-19T04:44:44+09:00 10.0.0.8 "GET /api?q=1 HTTP/2.0" 404 512 rt=0.012 upstream=10.1.0.5:8080`
+	psr := parser.HTTPAccess{
+		Format: `$time_iso8601 $remote_addr "$request" $status $bytes_sent rt=$request_time upstream=${upstream_addr}`,
+	}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(msg)})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "2019-10-18T19:44:44Z", logs[0].Timestamp.Format(time.RFC3339))
+
+	log := logs[0].Values.(*parser.HTTPAccessLog)
+	assert.Equal(t, "/api?q=1", log.Path)
+	assert.Equal(t, "HTTP/2.0", log.Protocol)
+	assert.Equal(t, 404, log.Status)
+	assert.Equal(t, int64(512), log.Bytes)
+	assert.Equal(t, "0.012", log.Custom["request_time"])
+	assert.Equal(t, "10.1.0.5:8080", log.Custom["upstream_addr"])
+}
+
+func TestHTTPAccessParserErrorCase(t *testing.T) {
+	psr := parser.HTTPAccess{}
+	_, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(`not an access log`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not match")
+
+	_, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte(`127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET / HTTP/1.0" OK 1 "-" "-"`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "$status")
+
+	invalid := parser.HTTPAccess{Format: `$status $status`}
+	_, err = invalid.Parse(&rlogs.MessageQueue{Raw: []byte(`200 200`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Duplicated variable")
+}
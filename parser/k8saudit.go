@@ -0,0 +1,158 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/pkg/errors"
+)
+
+// K8sUserInfo is user information of Kubernetes audit event.
+type K8sUserInfo struct {
+	Username string              `json:"username"`
+	UID      string              `json:"uid,omitempty"`
+	Groups   []string            `json:"groups,omitempty"`
+	Extra    map[string][]string `json:"extra,omitempty"`
+}
+
+// K8sObjectReference is reference to the object that the request is for.
+type K8sObjectReference struct {
+	Resource        string `json:"resource,omitempty"`
+	Namespace       string `json:"namespace,omitempty"`
+	Name            string `json:"name,omitempty"`
+	UID             string `json:"uid,omitempty"`
+	APIGroup        string `json:"apiGroup,omitempty"`
+	APIVersion      string `json:"apiVersion,omitempty"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+	Subresource     string `json:"subresource,omitempty"`
+}
+
+// K8sResponseStatus is status of the response.
+type K8sResponseStatus struct {
+	Status  string `json:"status,omitempty"`
+	Message string `json:"message,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+	Code    int    `json:"code,omitempty"`
+}
+
+// K8sAuditEvent is Kubernetes audit event (audit.k8s.io/v1 Event).
+type K8sAuditEvent struct {
+	Kind                     string                 `json:"kind"`
+	APIVersion               string                 `json:"apiVersion"`
+	Level                    string                 `json:"level"`
+	AuditID                  string                 `json:"auditID"`
+	Stage                    string                 `json:"stage"`
+	RequestURI               string                 `json:"requestURI"`
+	Verb                     string                 `json:"verb"`
+	User                     K8sUserInfo            `json:"user"`
+	ImpersonatedUser         *K8sUserInfo           `json:"impersonatedUser,omitempty"`
+	SourceIPs                []string               `json:"sourceIPs"`
+	UserAgent                string                 `json:"userAgent"`
+	ObjectRef                *K8sObjectReference    `json:"objectRef,omitempty"`
+	ResponseStatus           *K8sResponseStatus     `json:"responseStatus,omitempty"`
+	RequestObject            map[string]interface{} `json:"requestObject,omitempty"`
+	ResponseObject           map[string]interface{} `json:"responseObject,omitempty"`
+	RequestReceivedTimestamp string                 `json:"requestReceivedTimestamp"`
+	StageTimestamp           string                 `json:"stageTimestamp"`
+	Annotations              map[string]string      `json:"annotations,omitempty"`
+}
+
+// cloudWatchLogsData is CloudWatch Logs subscription data delivered to S3 via
+// Kinesis Data Firehose.
+type cloudWatchLogsData struct {
+	MessageType string `json:"messageType"`
+	LogGroup    string `json:"logGroup"`
+	LogStream   string `json:"logStream"`
+	LogEvents   []struct {
+		ID        string `json:"id"`
+		Timestamp int64  `json:"timestamp"`
+		Message   string `json:"message"`
+	} `json:"logEvents"`
+}
+
+// eksAuditLogStreamPrefix is prefix of log stream of audit logs in EKS control
+// plane log group. The log group also has streams of other components such as
+// authenticator, kube-scheduler and kube-controller-manager.
+const eksAuditLogStreamPrefix = "kube-apiserver-audit"
+
+// K8sAudit is parser of Kubernetes audit logs. The parser accepts following
+// formats.
+//   - Raw audit event JSON lines (audit log backend of kube-apiserver)
+//   - EKS control plane logs exported by CloudWatch Logs export task, e.g.
+//     `2019-10-19T04:44:44.123Z {"kind":"Event",...}`
+//   - EKS control plane logs delivered by CloudWatch Logs subscription and Firehose.
+//     Log events in log streams other than kube-apiserver-audit are skipped.
+//
+// S3LineLoader is required for the first two formats. Firehose concatenates
+// subscription data without line break, then S3MultilineLoader with JSON mode
+// is required for the last format. Log events in subscription data have Seq of
+// the message and Pipeline numbers them in the object.
+type K8sAudit struct{}
+
+// Parse of K8sAudit parses audit event(s) and uses requestReceivedTimestamp as timestamp.
+func (x *K8sAudit) Parse(msg *rlogs.MessageQueue) ([]*rlogs.LogRecord, error) {
+	raw := bytes.TrimSpace(msg.Raw)
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	var events [][]byte
+	if raw[0] == '{' {
+		var cwl cloudWatchLogsData
+		if err := json.Unmarshal(raw, &cwl); err != nil {
+			return nil, errors.Wrapf(err, "Fail to parse Kubernetes audit log: %s", string(raw))
+		}
+
+		if cwl.MessageType == "" {
+			events = [][]byte{raw}
+		} else {
+			if strings.HasPrefix(cwl.LogGroup, "/aws/eks/") &&
+				!strings.HasPrefix(cwl.LogStream, eksAuditLogStreamPrefix) {
+				return nil, nil // Not audit log stream
+			}
+
+			// CONTROL_MESSAGE has no audit event
+			for _, ev := range cwl.LogEvents {
+				events = append(events, []byte(ev.Message))
+			}
+		}
+	} else {
+		// Timestamp prefix by CloudWatch Logs export task
+		sp := bytes.IndexByte(raw, ' ')
+		if sp < 0 {
+			return nil, fmt.Errorf("Invalid Kubernetes audit log: %s", string(raw))
+		}
+		events = [][]byte{bytes.TrimSpace(raw[sp+1:])}
+	}
+
+	var logs []*rlogs.LogRecord
+	for _, ev := range events {
+		var event K8sAuditEvent
+		if err := json.Unmarshal(ev, &event); err != nil {
+			return nil, errors.Wrapf(err, "Fail to parse Kubernetes audit event: %s", string(ev))
+		}
+		if event.Kind != "Event" {
+			return nil, fmt.Errorf("Not Kubernetes audit event (kind: %s): %s", event.Kind, string(ev))
+		}
+
+		ts, err := time.Parse(time.RFC3339Nano, event.RequestReceivedTimestamp)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Fail to parse requestReceivedTimestamp of Kubernetes audit event: %v", event.RequestReceivedTimestamp)
+		}
+
+		logs = append(logs, &rlogs.LogRecord{
+			Tag:       "k8s.audit",
+			Timestamp: ts.UTC(),
+			Raw:       ev,
+			Values:    &event,
+			Seq:       msg.Seq,
+			Src:       msg.Src,
+		})
+	}
+
+	return logs, nil
+}
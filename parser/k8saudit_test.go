@@ -0,0 +1,123 @@
+package parser_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/m-mizutani/rlogs/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const k8sAuditEventSample = `{"kind":"Event","apiVersion":"audit.k8s.io/v1","level":"Metadata","auditID":"4d2ea952-0bf6-4b5e-9b1f-6c8a0c2b1e01","stage":"ResponseComplete","requestURI":"/api/v1/namespaces/default/secrets/db-password","verb":"get","user":{"username":"alice@example.com","groups":["system:authenticated"]},"sourceIPs":["10.0.0.1"],"userAgent":"kubectl/v1.16.0","objectRef":{"resource":"secrets","namespace":"default","name":"db-password","apiVersion":"v1"},"responseStatus":{"metadata":{},"code":200},"requestReceivedTimestamp":"2019-10-19T04:44:44.123456Z","stageTimestamp":"2019-10-19T04:44:44.130000Z","annotations":{"authorization.k8s.io/decision":"allow"}}`
+
+func TestK8sAuditParserRaw(t *testing.T) {
+	src := &rlogs.AwsS3LogSource{Region: "test-r", Bucket: "test-b", Key: "test-k"}
+	psr := parser.K8sAudit{}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(k8sAuditEventSample), Src: src, Seq: 2})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "k8s.audit", logs[0].Tag)
+	assert.Equal(t, 2, logs[0].Seq)
+	assert.Equal(t, "2019-10-19T04:44:44.123456Z", logs[0].Timestamp.Format(time.RFC3339Nano))
+
+	event := logs[0].Values.(*parser.K8sAuditEvent)
+	assert.Equal(t, "ResponseComplete", event.Stage)
+	assert.Equal(t, "get", event.Verb)
+	assert.Equal(t, "alice@example.com", event.User.Username)
+	assert.Equal(t, []string{"10.0.0.1"}, event.SourceIPs)
+	require.NotNil(t, event.ObjectRef)
+	assert.Equal(t, "secrets", event.ObjectRef.Resource)
+	assert.Equal(t, "db-password", event.ObjectRef.Name)
+	require.NotNil(t, event.ResponseStatus)
+	assert.Equal(t, 200, event.ResponseStatus.Code)
+	assert.Equal(t, "allow", event.Annotations["authorization.k8s.io/decision"])
+}
+
+func TestK8sAuditParserCloudWatchLogsExport(t *testing.T) {
+	psr := parser.K8sAudit{}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte("2019-10-19T04:44:44.200Z " + k8sAuditEventSample)})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "get", logs[0].Values.(*parser.K8sAuditEvent).Verb)
+}
+
+func TestK8sAuditParserCloudWatchLogsSubscription(t *testing.T) {
+	data := map[string]interface{}{
+		"messageType": "DATA_MESSAGE",
+		"owner":       "123456789012",
+		"logGroup":    "/aws/eks/my-cluster/cluster",
+		"logStream":   "kube-apiserver-audit-0123456789abcdef",
+		"logEvents": []map[string]interface{}{
+			{"id": "1", "timestamp": 1571460284200, "message": k8sAuditEventSample},
+			{"id": "2", "timestamp": 1571460284300, "message": k8sAuditEventSample},
+		},
+	}
+	raw, err := json.Marshal(data)
+	require.NoError(t, err)
+
+	psr := parser.K8sAudit{}
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: raw, Seq: 3})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(logs))
+	assert.Equal(t, k8sAuditEventSample, string(logs[1].Raw))
+	assert.Equal(t, 3, logs[0].Seq)
+	assert.Equal(t, 3, logs[1].Seq)
+
+	logs, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte(`{"messageType":"CONTROL_MESSAGE","logEvents":[]}`)})
+	require.NoError(t, err)
+	assert.Equal(t, 0, len(logs))
+}
+
+func TestK8sAuditParserCloudWatchLogsMixedStreams(t *testing.T) {
+	streams := []struct {
+		stream  string
+		message string
+	}{
+		{"authenticator-0123456789abcdef", `time="2019-10-19T04:44:44Z" level=info msg="access granted"`},
+		{"kube-apiserver-audit-0123456789abcdef", k8sAuditEventSample},
+		{"kube-scheduler-0123456789abcdef", `I1019 04:44:44.200000 1 scheduler.go:667] pod is bound`},
+		{"kube-controller-manager-0123456789abcdef", `I1019 04:44:44.300000 1 event.go:291] Event occurred`},
+	}
+
+	psr := parser.K8sAudit{}
+	var logs []*rlogs.LogRecord
+	for i, s := range streams {
+		raw, err := json.Marshal(map[string]interface{}{
+			"messageType": "DATA_MESSAGE",
+			"logGroup":    "/aws/eks/my-cluster/cluster",
+			"logStream":   s.stream,
+			"logEvents": []map[string]interface{}{
+				{"id": "1", "timestamp": 1571460284200, "message": s.message},
+			},
+		})
+		require.NoError(t, err)
+
+		r, err := psr.Parse(&rlogs.MessageQueue{Raw: raw, Seq: i})
+		require.NoError(t, err)
+		logs = append(logs, r...)
+	}
+
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "get", logs[0].Values.(*parser.K8sAuditEvent).Verb)
+}
+
+func TestK8sAuditParserErrorCase(t *testing.T) {
+	psr := parser.K8sAudit{}
+
+	_, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(`{"kind":"Pod","requestReceivedTimestamp":"2019-10-19T04:44:44Z"}`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Not Kubernetes audit event")
+
+	_, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte(`{"kind":"Event","requestReceivedTimestamp":""}`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Fail to parse requestReceivedTimestamp")
+
+	_, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte(`no-json-here`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid Kubernetes audit log")
+}
@@ -0,0 +1,222 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/pkg/errors"
+)
+
+// LEEFEvent is an event of IBM Log Event Extended Format. Syslog is available
+// only if the event has syslog header prefix.
+type LEEFEvent struct {
+	Version        string
+	Vendor         string
+	Product        string
+	ProductVersion string
+	EventID        string
+	Delimiter      string
+	Attributes     map[string]string
+	Syslog         *SyslogMessage
+}
+
+// LEEF is parser of Log Event Extended Format (LEEF) 1.0 and 2.0. Attributes are
+// delimited by tab in LEEF 1.0 and by the delimiter in header in LEEF 2.0. Tab
+// is also used for LEEF 2.0 if the optional delimiter field is omitted.
+// Timestamp of LogRecord comes from "devTime" attribute (with "devTimeFormat"),
+// otherwise timestamp of syslog header. One line should have one event, then
+// S3LineLoader is required.
+type LEEF struct {
+	// Tag of LogRecord. "leef" is used if empty.
+	Tag string
+	// Syslog is used to parse syslog header prefix. Syslog{} is used if nil.
+	Syslog *Syslog
+}
+
+// Parse of LEEF parses one LEEF event.
+func (x *LEEF) Parse(msg *rlogs.MessageQueue) ([]*rlogs.LogRecord, error) {
+	line := strings.TrimRight(string(msg.Raw), "\r\n")
+
+	pos := strings.Index(line, "LEEF:")
+	if pos < 0 {
+		return nil, fmt.Errorf("LEEF header is not found: %s", line)
+	}
+
+	var event LEEFEvent
+	if pos > 0 {
+		hdr, err := parseSyslogHeader(x.Syslog, line[:pos])
+		if err != nil {
+			return nil, errors.Wrap(err, "Fail to parse syslog header of LEEF")
+		}
+		event.Syslog = hdr
+	}
+
+	body := line[pos+len("LEEF:"):]
+	event.Version = body[:strings.IndexByte(body+"|", '|')]
+
+	if event.Version != "1.0" && event.Version != "2.0" {
+		return nil, fmt.Errorf("Unsupported LEEF version: %s", event.Version)
+	}
+
+	const nFields = 5
+	fields, attrs := splitEscaped(body, '|', nFields)
+	if len(fields) < nFields {
+		return nil, fmt.Errorf("LEEF %s header requires %d fields, but %d: %s", event.Version, nFields, len(fields), line)
+	}
+	event.Vendor = fields[1]
+	event.Product = fields[2]
+	event.ProductVersion = fields[3]
+	event.EventID = fields[4]
+
+	event.Delimiter = "\t"
+	if event.Version == "2.0" {
+		// Delimiter field is optional in LEEF 2.0. The field is attributes if it
+		// has '=' or is not a valid delimiter.
+		field, rest := attrs, ""
+		if i := strings.IndexByte(attrs, '|'); i >= 0 {
+			field, rest = attrs[:i], attrs[i+1:]
+		}
+
+		if field == "" {
+			attrs = rest
+		} else if !strings.Contains(field, "=") {
+			if d, err := parseLEEFDelimiter(field); err == nil {
+				event.Delimiter = d
+				attrs = rest
+			}
+		}
+	}
+
+	event.Attributes = map[string]string{}
+	for _, attr := range strings.Split(attrs, event.Delimiter) {
+		if attr == "" {
+			continue
+		}
+		kv := strings.SplitN(attr, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("Invalid LEEF attribute: %s", attr)
+		}
+		event.Attributes[kv[0]] = kv[1]
+	}
+
+	var ts time.Time
+	if devTime, ok := event.Attributes["devTime"]; ok {
+		t, err := parseLEEFTime(devTime, event.Attributes["devTimeFormat"])
+		if err != nil {
+			return nil, errors.Wrapf(err, "Fail to parse devTime of LEEF: %s", devTime)
+		}
+		ts = t
+	} else if event.Syslog != nil {
+		ts = event.Syslog.Timestamp
+	}
+
+	tag := x.Tag
+	if tag == "" {
+		tag = "leef"
+	}
+
+	return []*rlogs.LogRecord{
+		{
+			Tag:       tag,
+			Timestamp: ts.UTC(),
+			Raw:       msg.Raw,
+			Values:    &event,
+			Seq:       msg.Seq,
+			Src:       msg.Src,
+		},
+	}, nil
+}
+
+// parseLEEFDelimiter accepts a single character or hex such as "x09", "0x09" and "^".
+func parseLEEFDelimiter(s string) (string, error) {
+	if len(s) == 1 {
+		return s, nil
+	}
+
+	lower := strings.ToLower(s)
+	var hex string
+	switch {
+	case strings.HasPrefix(lower, "0x"):
+		hex = lower[2:]
+	case strings.HasPrefix(lower, "x"):
+		hex = lower[1:]
+	default:
+		return "", fmt.Errorf("Invalid LEEF delimiter: %s", s)
+	}
+	c, err := strconv.ParseUint(hex, 16, 8)
+	if err != nil {
+		return "", errors.Wrapf(err, "Invalid LEEF delimiter: %s", s)
+	}
+	return string([]byte{byte(c)}), nil
+}
+
+func parseLEEFTime(s, format string) (time.Time, error) {
+	if format == "" {
+		// devTime is epoch milliseconds or default format "MMM dd yyyy HH:mm:ss"
+		return parseCEFTime(s)
+	}
+
+	return time.Parse(javaTimeLayout(format), s)
+}
+
+var javaTimeTokens = map[string]string{
+	"yyyy": "2006",
+	"yy":   "06",
+	"MMMM": "January",
+	"MMM":  "Jan",
+	"MM":   "01",
+	"M":    "1",
+	"dd":   "02",
+	"d":    "2",
+	"EEEE": "Monday",
+	"EEE":  "Mon",
+	"HH":   "15",
+	"hh":   "03",
+	"h":    "3",
+	"mm":   "04",
+	"m":    "4",
+	"ss":   "05",
+	"s":    "5",
+	"SSS":  "000",
+	"a":    "PM",
+	"zzz":  "MST",
+	"z":    "MST",
+	"Z":    "-0700",
+	"XXX":  "-07:00",
+}
+
+// javaTimeLayout converts Java SimpleDateFormat pattern (used by devTimeFormat) to
+// Go time layout. Unknown pattern letters are kept as they are.
+func javaTimeLayout(format string) string {
+	var layout strings.Builder
+
+	for i := 0; i < len(format); {
+		c := format[i]
+		if c == '\'' { // quoted literal
+			end := strings.IndexByte(format[i+1:], '\'')
+			if end < 0 {
+				layout.WriteString(format[i+1:])
+				break
+			}
+			layout.WriteString(format[i+1 : i+1+end])
+			i += end + 2
+			continue
+		}
+
+		j := i
+		for j < len(format) && format[j] == c {
+			j++
+		}
+		if v, ok := javaTimeTokens[format[i:j]]; ok {
+			layout.WriteString(v)
+		} else {
+			layout.WriteString(format[i:j])
+		}
+		i = j
+	}
+
+	return layout.String()
+}
@@ -0,0 +1,104 @@
+package parser_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/m-mizutani/rlogs/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLEEFParserV1(t *testing.T) {
+	msg := "LEEF:1.0|Microsoft|MSExchange|4.0 SP1|15345|src=192.0.2.0\tdst=172.50.123.1\tsev=5\tcat=anomaly\tdevTime=Oct 11 2019 22:14:15\tmsg=User=alice logged in"
+	src := &rlogs.AwsS3LogSource{Region: "test-r", Bucket: "test-b", Key: "test-k"}
+	psr := parser.LEEF{}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(msg), Src: src})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "leef", logs[0].Tag)
+	assert.Equal(t, "2019-10-11T22:14:15Z", logs[0].Timestamp.Format(time.RFC3339))
+
+	event := logs[0].Values.(*parser.LEEFEvent)
+	assert.Equal(t, "1.0", event.Version)
+	assert.Equal(t, "Microsoft", event.Vendor)
+	assert.Equal(t, "MSExchange", event.Product)
+	assert.Equal(t, "4.0 SP1", event.ProductVersion)
+	assert.Equal(t, "15345", event.EventID)
+	assert.Equal(t, "\t", event.Delimiter)
+	assert.Equal(t, "192.0.2.0", event.Attributes["src"])
+	assert.Equal(t, "anomaly", event.Attributes["cat"])
+	assert.Equal(t, "User=alice logged in", event.Attributes["msg"])
+}
+
+func TestLEEFParserV2CustomDelimiter(t *testing.T) {
+	msg := `<13>Jan 18 11:07:53 proxy01 LEEF:2.0|Lancope|StealthWatch|1.0|41|^|src=10.0.1.8^dst=10.0.0.5^sev=5^devTime=2019-01-18 11:07:53.123 +0900^devTimeFormat=yyyy-MM-dd HH:mm:ss.SSS Z`
+	psr := parser.LEEF{}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(msg)})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "2019-01-18T02:07:53Z", logs[0].Timestamp.Format(time.RFC3339))
+
+	event := logs[0].Values.(*parser.LEEFEvent)
+	assert.Equal(t, "2.0", event.Version)
+	assert.Equal(t, "^", event.Delimiter)
+	assert.Equal(t, "10.0.1.8", event.Attributes["src"])
+	assert.Equal(t, "5", event.Attributes["sev"])
+	require.NotNil(t, event.Syslog)
+	assert.Equal(t, "proxy01", event.Syslog.Hostname)
+}
+
+func TestLEEFParserV2HexDelimiter(t *testing.T) {
+	msg := `LEEF:2.0|Vendor|Product|1.0|login|x7C|src=10.0.1.8|usrName=alice`
+	psr := parser.LEEF{Tag: "proxy"}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(msg)})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "proxy", logs[0].Tag)
+
+	event := logs[0].Values.(*parser.LEEFEvent)
+	assert.Equal(t, "|", event.Delimiter)
+	assert.Equal(t, "alice", event.Attributes["usrName"])
+}
+
+func TestLEEFParserV2NoDelimiter(t *testing.T) {
+	psr := parser.LEEF{}
+
+	for _, msg := range []string{
+		"LEEF:2.0|Vendor|Product|1.0|login|src=1.1.1.1\tdst=2.2.2.2",
+		"LEEF:2.0|Vendor|Product|1.0|login||src=1.1.1.1\tdst=2.2.2.2",
+	} {
+		logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(msg)})
+		require.NoError(t, err)
+		require.Equal(t, 1, len(logs))
+
+		event := logs[0].Values.(*parser.LEEFEvent)
+		assert.Equal(t, "\t", event.Delimiter)
+		assert.Equal(t, "1.1.1.1", event.Attributes["src"])
+		assert.Equal(t, "2.2.2.2", event.Attributes["dst"])
+	}
+}
+
+func TestLEEFParserErrorCase(t *testing.T) {
+	psr := parser.LEEF{}
+
+	_, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(`LEEF:3.0|Vendor|Product|1.0|login|`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Unsupported LEEF version")
+
+	_, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte(`LEEF:2.0|Vendor|Product|1.0|login|tab`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid LEEF attribute")
+
+	_, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte(`LEEF:2.0|Vendor|Product|1.0`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "requires 5 fields")
+
+	_, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte("LEEF:1.0|Vendor|Product|1.0|login|src")})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid LEEF attribute")
+}
@@ -0,0 +1,127 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/pkg/errors"
+)
+
+// logfmtNumber is plain decimal number that can be coerced by CoerceNumbers.
+var logfmtNumber = regexp.MustCompile(`^-?\d+(\.\d+)?([eE][+-]?\d+)?$`)
+
+// Logfmt is parser of logfmt (key=value) logs such as
+// `level=info msg="hello world" elapsed=1.5`. A key without value (e.g. `debug`)
+// has empty string. Timestamp is extracted by TimestampOptions and number string
+// is accepted for UnixtimeField and UnixtimeMilliField because values are string.
+type Logfmt struct {
+	Tag string
+	// PairSeparator separates key=value pairs. Whitespace is used if zero.
+	PairSeparator rune
+	// CoerceNumbers converts unquoted decimal number value (e.g. `-12`, `0.25`
+	// and `1e3`) to int64 or float64. Other values such as `Inf`, `NaN` and hex
+	// are kept as string.
+	CoerceNumbers bool
+
+	TimestampOptions
+}
+
+// Parse of Logfmt parses one logfmt line.
+func (x *Logfmt) Parse(msg *rlogs.MessageQueue) ([]*rlogs.LogRecord, error) {
+	line := strings.TrimRight(string(msg.Raw), "\r\n")
+
+	value, err := parseLogfmt(line, x.PairSeparator, x.CoerceNumbers)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Fail to parse logfmt: %s", line)
+	}
+
+	t, err := x.TimestampOptions.extract(value, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*rlogs.LogRecord{
+		{
+			Tag:       x.Tag,
+			Timestamp: t,
+			Raw:       msg.Raw,
+			Values:    value,
+			Seq:       msg.Seq,
+			Src:       msg.Src,
+		},
+	}, nil
+}
+
+func parseLogfmt(line string, sep rune, coerce bool) (map[string]interface{}, error) {
+	isSep := unicode.IsSpace
+	if sep != 0 {
+		isSep = func(r rune) bool { return r == sep }
+	}
+
+	value := map[string]interface{}{}
+	s := []rune(line)
+	for i := 0; i < len(s); {
+		// Skip separators and spaces around pairs
+		if isSep(s[i]) || unicode.IsSpace(s[i]) {
+			i++
+			continue
+		}
+
+		start := i
+		for i < len(s) && s[i] != '=' && !isSep(s[i]) {
+			i++
+		}
+		key := strings.TrimSpace(string(s[start:i]))
+		if key == "" {
+			return nil, fmt.Errorf("Empty key at %d", start)
+		}
+
+		if i >= len(s) || s[i] != '=' {
+			value[key] = "" // key without value
+			continue
+		}
+		i++ // skip '='
+
+		if i < len(s) && s[i] == '"' {
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, fmt.Errorf("Unterminated quoted value of %s", key)
+			}
+
+			v, err := strconv.Unquote(string(s[i : end+1]))
+			if err != nil {
+				return nil, errors.Wrapf(err, "Invalid quoted value of %s", key)
+			}
+			value[key] = v
+			i = end + 1
+			continue
+		}
+
+		start = i
+		for i < len(s) && !isSep(s[i]) {
+			i++
+		}
+		v := strings.TrimSpace(string(s[start:i]))
+		value[key] = v
+
+		if coerce && logfmtNumber.MatchString(v) {
+			if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+				value[key] = n
+			} else if f, err := strconv.ParseFloat(v, 64); err == nil {
+				value[key] = f
+			}
+		}
+	}
+
+	return value, nil
+}
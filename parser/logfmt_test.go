@@ -0,0 +1,128 @@
+package parser_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/m-mizutani/rlogs/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogfmtParser(t *testing.T) {
+	psr := parser.Logfmt{
+		Tag: "heroku",
+		TimestampOptions: parser.TimestampOptions{
+			TimestampField:  rlogs.String("at"),
+			TimestampFormat: rlogs.String(time.RFC3339),
+		},
+	}
+	src := &rlogs.AwsS3LogSource{Region: "test-r", Bucket: "test-b", Key: "test-k"}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{
+		Raw: []byte(`at=2019-10-19T04:44:44+09:00 level=info msg="user \"alice\" logged in" path=/login status=200 debug`),
+		Src: src,
+		Seq: 3,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "heroku", logs[0].Tag)
+	assert.Equal(t, 3, logs[0].Seq)
+	assert.Equal(t, "2019-10-18T19:44:44Z", logs[0].Timestamp.Format(time.RFC3339))
+
+	v := logs[0].Values.(map[string]interface{})
+	assert.Equal(t, "info", v["level"])
+	assert.Equal(t, `user "alice" logged in`, v["msg"])
+	assert.Equal(t, "/login", v["path"])
+	assert.Equal(t, "200", v["status"])
+	assert.Equal(t, "", v["debug"])
+}
+
+func TestLogfmtParserCoerceNumbers(t *testing.T) {
+	psr := parser.Logfmt{
+		CoerceNumbers:    true,
+		TimestampOptions: parser.TimestampOptions{UnixtimeMilliField: rlogs.String("ts")},
+	}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{
+		Raw: []byte(`ts=1571630400123 status=200 elapsed=0.25 code="404" host=web01`),
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, int64(1571630400), logs[0].Timestamp.Unix())
+	assert.Equal(t, 123000000, logs[0].Timestamp.Nanosecond())
+
+	v := logs[0].Values.(map[string]interface{})
+	assert.Equal(t, int64(200), v["status"])
+	assert.Equal(t, 0.25, v["elapsed"])
+	assert.Equal(t, "404", v["code"]) // quoted value is not coerced
+	assert.Equal(t, "web01", v["host"])
+
+	// Only plain decimal numbers are coerced
+	logs, err = psr.Parse(&rlogs.MessageQueue{
+		Raw: []byte(`ts=1571630400123 tag=Inf v=NaN w=infinity x=0x1p-2 y=+5 z=-3 e=1.5e3 n=1_000`),
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+
+	v = logs[0].Values.(map[string]interface{})
+	assert.Equal(t, "Inf", v["tag"])
+	assert.Equal(t, "NaN", v["v"])
+	assert.Equal(t, "infinity", v["w"])
+	assert.Equal(t, "0x1p-2", v["x"])
+	assert.Equal(t, "+5", v["y"])
+	assert.Equal(t, int64(-3), v["z"])
+	assert.Equal(t, 1500.0, v["e"])
+	assert.Equal(t, "1_000", v["n"])
+}
+
+func TestLogfmtParserPairSeparator(t *testing.T) {
+	psr := parser.Logfmt{
+		PairSeparator:    ';',
+		TimestampOptions: parser.TimestampOptions{UnixtimeStringField: rlogs.String("time")},
+	}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{
+		Raw: []byte(`time=1571630400; src=10.0.0.1; action=blocked by policy;user="a;b"`),
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, int64(1571630400), logs[0].Timestamp.Unix())
+
+	v := logs[0].Values.(map[string]interface{})
+	assert.Equal(t, "10.0.0.1", v["src"])
+	assert.Equal(t, "blocked by policy", v["action"])
+	assert.Equal(t, "a;b", v["user"])
+}
+
+func TestLogfmtParserErrorCase(t *testing.T) {
+	psr := parser.Logfmt{TimestampOptions: parser.TimestampOptions{UnixtimeField: rlogs.String("ts")}}
+
+	_, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(`ts=1 msg="unterminated`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Unterminated quoted value of msg")
+
+	_, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte(`=value`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Empty key")
+
+	_, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte(`msg=hello`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "No unixtime field")
+
+	noTimestamp := parser.Logfmt{}
+	_, err = noTimestamp.Parse(&rlogs.MessageQueue{Raw: []byte(`msg=hello`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "No timestamp field arguments")
+}
+
+func TestLogfmtParserUnixtimeString(t *testing.T) {
+	psr := parser.Logfmt{TimestampOptions: parser.TimestampOptions{UnixtimeMilliField: rlogs.String("ts")}}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(`ts=1571630400123 status=200`)})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, int64(1571630400), logs[0].Timestamp.Unix())
+	assert.Equal(t, "200", logs[0].Values.(map[string]interface{})["status"])
+}
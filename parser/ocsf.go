@@ -0,0 +1,91 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/pkg/errors"
+)
+
+// OCSFProduct is product information in OCSF metadata.
+type OCSFProduct struct {
+	Name       string `json:"name"`
+	VendorName string `json:"vendor_name"`
+	Version    string `json:"version"`
+}
+
+// OCSFMetadata is metadata attribute of OCSF event.
+type OCSFMetadata struct {
+	Version string      `json:"version"`
+	UID     string      `json:"uid"`
+	Product OCSFProduct `json:"product"`
+}
+
+// OCSFEvent has base attributes of Open Cybersecurity Schema Framework (OCSF)
+// event. Attributes depending on event class are kept in Data as generic map
+// with base attributes.
+type OCSFEvent struct {
+	ClassUID     int          `json:"class_uid"`
+	ClassName    string       `json:"class_name"`
+	CategoryUID  int          `json:"category_uid"`
+	CategoryName string       `json:"category_name"`
+	TypeUID      int          `json:"type_uid"`
+	TypeName     string       `json:"type_name"`
+	ActivityID   int          `json:"activity_id"`
+	ActivityName string       `json:"activity_name"`
+	SeverityID   int          `json:"severity_id"`
+	Severity     string       `json:"severity"`
+	StatusID     int          `json:"status_id"`
+	Status       string       `json:"status"`
+	Time         int64        `json:"time"`
+	Message      string       `json:"message"`
+	Metadata     OCSFMetadata `json:"metadata"`
+
+	Data map[string]interface{} `json:"-"`
+}
+
+// OCSF is parser of OCSF formatted JSON event such as Amazon Security Lake data.
+// Tag of LogRecord is "ocsf." + class_name in snake case (e.g. "ocsf.detection_finding")
+// to route events by class. One line should have one event, then S3LineLoader is required.
+type OCSF struct{}
+
+// Parse of OCSF parses one OCSF event and uses time (epoch milliseconds) as
+// timestamp. The event without time is error because time is required attribute.
+func (x *OCSF) Parse(msg *rlogs.MessageQueue) ([]*rlogs.LogRecord, error) {
+	var event OCSFEvent
+	if err := json.Unmarshal(msg.Raw, &event); err != nil {
+		return nil, errors.Wrapf(err, "Fail to parse OCSF event: %s", string(msg.Raw))
+	}
+	if err := json.Unmarshal(msg.Raw, &event.Data); err != nil {
+		return nil, errors.Wrapf(err, "Fail to parse OCSF event: %s", string(msg.Raw))
+	}
+
+	if event.ClassUID == 0 {
+		return nil, fmt.Errorf("class_uid is required for OCSF event: %s", string(msg.Raw))
+	}
+
+	if _, ok := event.Data["time"]; !ok {
+		return nil, fmt.Errorf("time is required for OCSF event: %s", string(msg.Raw))
+	}
+
+	tag := "ocsf"
+	if event.ClassName != "" {
+		tag += "." + strings.ReplaceAll(strings.ToLower(event.ClassName), " ", "_")
+	}
+
+	ts := time.Unix(event.Time/1000, (event.Time%1000)*int64(time.Millisecond)).UTC()
+
+	return []*rlogs.LogRecord{
+		{
+			Tag:       tag,
+			Timestamp: ts,
+			Raw:       msg.Raw,
+			Values:    &event,
+			Seq:       msg.Seq,
+			Src:       msg.Src,
+		},
+	}, nil
+}
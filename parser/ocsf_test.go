@@ -0,0 +1,48 @@
+package parser_test
+
+import (
+	"testing"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/m-mizutani/rlogs/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOCSFParser(t *testing.T) {
+	msg := `{"class_uid":2004,"class_name":"Detection Finding","category_uid":2,"category_name":"Findings","type_uid":200401,"activity_id":1,"activity_name":"Create","severity_id":3,"severity":"Medium","status_id":1,"time":1700000000123,"message":"Suspicious login","metadata":{"version":"1.1.0","product":{"name":"Security Hub","vendor_name":"AWS"}},"finding_info":{"uid":"f-1","title":"Suspicious login"}}`
+	src := &rlogs.AwsS3LogSource{Region: "test-r", Bucket: "test-b", Key: "test-k"}
+	psr := parser.OCSF{}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(msg), Src: src})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "ocsf.detection_finding", logs[0].Tag)
+	assert.Equal(t, int64(1700000000), logs[0].Timestamp.Unix())
+	assert.Equal(t, 123000000, logs[0].Timestamp.Nanosecond())
+
+	event := logs[0].Values.(*parser.OCSFEvent)
+	assert.Equal(t, 2004, event.ClassUID)
+	assert.Equal(t, 3, event.SeverityID)
+	assert.Equal(t, "Security Hub", event.Metadata.Product.Name)
+	assert.Equal(t, "AWS", event.Metadata.Product.VendorName)
+	info, ok := event.Data["finding_info"].(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, "f-1", info["uid"])
+}
+
+func TestOCSFParserNoClassUID(t *testing.T) {
+	psr := parser.OCSF{}
+
+	_, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(`{"time":1700000000123}`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "class_uid is required")
+}
+
+func TestOCSFParserNoTime(t *testing.T) {
+	psr := parser.OCSF{}
+
+	_, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(`{"class_uid":2004,"class_name":"Detection Finding"}`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "time is required")
+}
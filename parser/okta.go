@@ -0,0 +1,111 @@
+package parser
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/pkg/errors"
+)
+
+// OktaActor is actor or target of Okta System Log event.
+type OktaActor struct {
+	ID          string                 `json:"id"`
+	Type        string                 `json:"type"`
+	AlternateID string                 `json:"alternateId"`
+	DisplayName string                 `json:"displayName"`
+	DetailEntry map[string]interface{} `json:"detailEntry,omitempty"`
+}
+
+// OktaGeolocation is geolocation of the client.
+type OktaGeolocation struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// OktaGeographicalContext is geographical context of the client.
+type OktaGeographicalContext struct {
+	City        string           `json:"city"`
+	State       string           `json:"state"`
+	Country     string           `json:"country"`
+	PostalCode  string           `json:"postalCode"`
+	Geolocation *OktaGeolocation `json:"geolocation,omitempty"`
+}
+
+// OktaUserAgent is user agent of the client.
+type OktaUserAgent struct {
+	RawUserAgent string `json:"rawUserAgent"`
+	OS           string `json:"os"`
+	Browser      string `json:"browser"`
+}
+
+// OktaClient is client that sent the request.
+type OktaClient struct {
+	ID                  string                   `json:"id"`
+	IPAddress           string                   `json:"ipAddress"`
+	Device              string                   `json:"device"`
+	Zone                string                   `json:"zone"`
+	UserAgent           *OktaUserAgent           `json:"userAgent,omitempty"`
+	GeographicalContext *OktaGeographicalContext `json:"geographicalContext,omitempty"`
+}
+
+// OktaOutcome is outcome of the event, e.g. Result is "SUCCESS" or "FAILURE".
+type OktaOutcome struct {
+	Result string `json:"result"`
+	Reason string `json:"reason"`
+}
+
+// OktaTransaction is transaction that the event belongs to.
+type OktaTransaction struct {
+	ID     string                 `json:"id"`
+	Type   string                 `json:"type"`
+	Detail map[string]interface{} `json:"detail,omitempty"`
+}
+
+// OktaSystemLogEvent is an event of Okta System Log.
+type OktaSystemLogEvent struct {
+	UUID                  string                 `json:"uuid"`
+	Published             string                 `json:"published"`
+	EventType             string                 `json:"eventType"`
+	Version               string                 `json:"version"`
+	Severity              string                 `json:"severity"`
+	LegacyEventType       string                 `json:"legacyEventType,omitempty"`
+	DisplayMessage        string                 `json:"displayMessage"`
+	Actor                 OktaActor              `json:"actor"`
+	Client                OktaClient             `json:"client"`
+	Outcome               OktaOutcome            `json:"outcome"`
+	Target                []OktaActor            `json:"target,omitempty"`
+	Transaction           *OktaTransaction       `json:"transaction,omitempty"`
+	DebugContext          map[string]interface{} `json:"debugContext,omitempty"`
+	AuthenticationContext map[string]interface{} `json:"authenticationContext,omitempty"`
+	SecurityContext       map[string]interface{} `json:"securityContext,omitempty"`
+}
+
+// OktaSystemLog is parser of Okta System Log events. One line should have one
+// event, then S3LineLoader is required.
+type OktaSystemLog struct{}
+
+// Parse of OktaSystemLog parses one event and uses published as timestamp.
+func (x *OktaSystemLog) Parse(msg *rlogs.MessageQueue) ([]*rlogs.LogRecord, error) {
+	var event OktaSystemLogEvent
+	if err := json.Unmarshal(msg.Raw, &event); err != nil {
+		return nil, errors.Wrapf(err, "Fail to parse Okta System Log: %s", string(msg.Raw))
+	}
+
+	// 2019-10-19T04:44:44.123Z
+	ts, err := time.Parse(time.RFC3339Nano, event.Published)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Fail to parse published of Okta System Log: %v", event.Published)
+	}
+
+	return []*rlogs.LogRecord{
+		{
+			Tag:       "okta.system",
+			Timestamp: ts.UTC(),
+			Raw:       msg.Raw,
+			Values:    &event,
+			Seq:       msg.Seq,
+			Src:       msg.Src,
+		},
+	}, nil
+}
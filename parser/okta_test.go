@@ -0,0 +1,43 @@
+package parser_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/m-mizutani/rlogs/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOktaSystemLogParser(t *testing.T) {
+	msg := `{"actor":{"id":"00u1abcd","type":"User","alternateId":"alice@example.com","displayName":"Alice","detailEntry":null},"client":{"userAgent":{"rawUserAgent":"Mozilla/5.0","os":"Mac OS X","browser":"CHROME"},"zone":"null","device":"Computer","id":null,"ipAddress":"203.0.113.1","geographicalContext":{"city":"Tokyo","state":"Tokyo","country":"Japan","postalCode":"100-0001","geolocation":{"lat":35.6895,"lon":139.6917}}},"authenticationContext":{"authenticationStep":0,"externalSessionId":"102abc"},"displayMessage":"User login to Okta","eventType":"user.session.start","outcome":{"result":"FAILURE","reason":"INVALID_CREDENTIALS"},"published":"2019-10-19T04:44:44.123Z","securityContext":{},"severity":"INFO","debugContext":{"debugData":{"requestUri":"/api/v1/authn"}},"legacyEventType":"core.user_auth.login_failed","transaction":{"type":"WEB","id":"XaBcD","detail":{}},"uuid":"5a7bc1e2-f223-11e9-a9f6-1b7a1f6c2b8d","version":"0","target":[{"id":"0oa1efgh","type":"AppInstance","alternateId":"Salesforce","displayName":"Salesforce.com","detailEntry":null}]}`
+	src := &rlogs.AwsS3LogSource{Region: "test-r", Bucket: "test-b", Key: "test-k"}
+	psr := parser.OktaSystemLog{}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(msg), Src: src, Seq: 2})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "okta.system", logs[0].Tag)
+	assert.Equal(t, 2, logs[0].Seq)
+	assert.Equal(t, "2019-10-19T04:44:44.123Z", logs[0].Timestamp.Format(time.RFC3339Nano))
+
+	event := logs[0].Values.(*parser.OktaSystemLogEvent)
+	assert.Equal(t, "user.session.start", event.EventType)
+	assert.Equal(t, "alice@example.com", event.Actor.AlternateID)
+	assert.Equal(t, "203.0.113.1", event.Client.IPAddress)
+	require.NotNil(t, event.Client.GeographicalContext)
+	assert.Equal(t, "Japan", event.Client.GeographicalContext.Country)
+	assert.Equal(t, "FAILURE", event.Outcome.Result)
+	assert.Equal(t, "INVALID_CREDENTIALS", event.Outcome.Reason)
+	require.Equal(t, 1, len(event.Target))
+	assert.Equal(t, "AppInstance", event.Target[0].Type)
+}
+
+func TestOktaSystemLogParserErrorCase(t *testing.T) {
+	psr := parser.OktaSystemLog{}
+
+	_, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(`{"eventType":"user.session.start"}`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Fail to parse published")
+}
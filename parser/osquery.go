@@ -0,0 +1,133 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/pkg/errors"
+)
+
+// OsqueryResult is a row of osquery scheduled query result. Action is "added"
+// or "removed" for differential results and "snapshot" for snapshot results.
+// Values of Columns are string, or number if numerics option of osquery is
+// enabled.
+type OsqueryResult struct {
+	Name           string
+	HostIdentifier string
+	CalendarTime   string
+	UnixTime       int64
+	Epoch          int64
+	Counter        int64
+	Numerics       bool
+	Decorations    map[string]string
+	Action         string
+	Columns        map[string]interface{}
+}
+
+// osqueryLog is a log line of osquery filesystem logger plugin.
+type osqueryLog struct {
+	Name           string            `json:"name"`
+	HostIdentifier string            `json:"hostIdentifier"`
+	CalendarTime   string            `json:"calendarTime"`
+	UnixTime       json.Number       `json:"unixTime"`
+	Epoch          json.Number       `json:"epoch"`
+	Counter        json.Number       `json:"counter"`
+	Numerics       bool              `json:"numerics"`
+	Decorations    map[string]string `json:"decorations"`
+	// Event format of differential results
+	Action  string                 `json:"action"`
+	Columns map[string]interface{} `json:"columns"`
+	// Batch format of differential results
+	DiffResults *struct {
+		Added   []map[string]interface{} `json:"added"`
+		Removed []map[string]interface{} `json:"removed"`
+	} `json:"diffResults"`
+	// Snapshot results
+	Snapshot []map[string]interface{} `json:"snapshot"`
+}
+
+// Osquery is parser of osquery results written by filesystem logger plugin
+// (osqueryd.results.log and osqueryd.snapshots.log). Differential results in
+// both of event and batch format and snapshot results are supported. One row
+// of the results is converted to one LogRecord and Tag is "osquery." + query
+// name. Rows of a result have Seq of the message and Pipeline numbers them in
+// the object. One line should have one result, then S3LineLoader is required.
+type Osquery struct{}
+
+// Parse of Osquery parses one result and uses unixTime (or calendarTime) as timestamp.
+func (x *Osquery) Parse(msg *rlogs.MessageQueue) ([]*rlogs.LogRecord, error) {
+	var log osqueryLog
+	if err := json.Unmarshal(msg.Raw, &log); err != nil {
+		return nil, errors.Wrapf(err, "Fail to parse osquery result: %s", string(msg.Raw))
+	}
+
+	if log.Name == "" {
+		return nil, fmt.Errorf("No name in osquery result: %s", string(msg.Raw))
+	}
+
+	var ts time.Time
+	if log.UnixTime != "" {
+		unixTime, err := strconv.ParseInt(log.UnixTime.String(), 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Fail to parse unixTime of osquery result: %v", log.UnixTime)
+		}
+		ts = time.Unix(unixTime, 0)
+	} else {
+		// Sat Oct 19 04:44:44 2019 UTC
+		t, err := time.Parse("Mon Jan _2 15:04:05 2006 MST", log.CalendarTime)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Fail to parse calendarTime of osquery result: %v", log.CalendarTime)
+		}
+		ts = t
+	}
+
+	base := OsqueryResult{
+		Name:           log.Name,
+		HostIdentifier: log.HostIdentifier,
+		CalendarTime:   log.CalendarTime,
+		UnixTime:       ts.Unix(),
+		Numerics:       log.Numerics,
+		Decorations:    log.Decorations,
+	}
+	base.Epoch, _ = log.Epoch.Int64()
+	base.Counter, _ = log.Counter.Int64()
+
+	var results []*OsqueryResult
+	appendRows := func(action string, rows []map[string]interface{}) {
+		for _, row := range rows {
+			result := base
+			result.Action = action
+			result.Columns = row
+			results = append(results, &result)
+		}
+	}
+
+	switch {
+	case log.Snapshot != nil:
+		appendRows("snapshot", log.Snapshot)
+	case log.DiffResults != nil:
+		appendRows("added", log.DiffResults.Added)
+		appendRows("removed", log.DiffResults.Removed)
+	case log.Columns != nil:
+		appendRows(log.Action, []map[string]interface{}{log.Columns})
+	default:
+		return nil, fmt.Errorf("No columns, diffResults nor snapshot in osquery result: %s", string(msg.Raw))
+	}
+
+	var logs []*rlogs.LogRecord
+	for _, result := range results {
+		logs = append(logs, &rlogs.LogRecord{
+			Tag:       "osquery." + log.Name,
+			Timestamp: ts.UTC(),
+			Raw:       msg.Raw,
+			Values:    result,
+			Seq:       msg.Seq,
+			Src:       msg.Src,
+		})
+	}
+
+	return logs, nil
+}
@@ -0,0 +1,82 @@
+package parser_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/m-mizutani/rlogs/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOsqueryParserEventFormat(t *testing.T) {
+	msg := `{"name":"pack_incident-response_listening_ports","hostIdentifier":"host1.example.com","calendarTime":"Sat Oct 19 04:44:44 2019 UTC","unixTime":1571460284,"epoch":0,"counter":3,"numerics":false,"decorations":{"host_uuid":"ABCD-1234"},"columns":{"pid":"1234","port":"4444","address":"0.0.0.0"},"action":"added"}`
+	src := &rlogs.AwsS3LogSource{Region: "test-r", Bucket: "test-b", Key: "test-k"}
+	psr := parser.Osquery{}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(msg), Src: src, Seq: 4})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "osquery.pack_incident-response_listening_ports", logs[0].Tag)
+	assert.Equal(t, "2019-10-19T04:44:44Z", logs[0].Timestamp.Format(time.RFC3339Nano))
+	assert.Equal(t, 4, logs[0].Seq)
+
+	result := logs[0].Values.(*parser.OsqueryResult)
+	assert.Equal(t, "added", result.Action)
+	assert.Equal(t, "host1.example.com", result.HostIdentifier)
+	assert.Equal(t, int64(3), result.Counter)
+	assert.Equal(t, "ABCD-1234", result.Decorations["host_uuid"])
+	assert.Equal(t, "4444", result.Columns["port"])
+}
+
+func TestOsqueryParserBatchFormat(t *testing.T) {
+	msg := `{"name":"users","hostIdentifier":"host1","calendarTime":"Sat Oct 19 04:44:44 2019 UTC","unixTime":"1571460284","epoch":1,"counter":2,"numerics":true,"diffResults":{"added":[{"username":"mallory","uid":1002}],"removed":[{"username":"alice","uid":1000},{"username":"bob","uid":1001}]}}`
+	psr := parser.Osquery{}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(msg)})
+	require.NoError(t, err)
+	require.Equal(t, 3, len(logs))
+
+	r0 := logs[0].Values.(*parser.OsqueryResult)
+	assert.Equal(t, "added", r0.Action)
+	assert.Equal(t, "mallory", r0.Columns["username"])
+	assert.Equal(t, float64(1002), r0.Columns["uid"])
+	assert.Equal(t, int64(1), r0.Epoch)
+
+	r2 := logs[2].Values.(*parser.OsqueryResult)
+	assert.Equal(t, "removed", r2.Action)
+	assert.Equal(t, "bob", r2.Columns["username"])
+	assert.Equal(t, "osquery.users", logs[2].Tag)
+}
+
+func TestOsqueryParserSnapshot(t *testing.T) {
+	msg := `{"snapshot":[{"name":"sshd","pid":"100"},{"name":"cron","pid":"200"}],"action":"snapshot","name":"processes","hostIdentifier":"host1","calendarTime":"Sat Oct 19 04:44:44 2019 UTC","epoch":0,"counter":0,"numerics":false}`
+	psr := parser.Osquery{}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(msg)})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(logs))
+	assert.Equal(t, "2019-10-19T04:44:44Z", logs[0].Timestamp.Format(time.RFC3339Nano))
+
+	r1 := logs[1].Values.(*parser.OsqueryResult)
+	assert.Equal(t, "snapshot", r1.Action)
+	assert.Equal(t, "cron", r1.Columns["name"])
+	assert.Equal(t, int64(1571460284), r1.UnixTime)
+}
+
+func TestOsqueryParserErrorCase(t *testing.T) {
+	psr := parser.Osquery{}
+
+	_, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(`{"unixTime":1571460284,"columns":{}}`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "No name")
+
+	_, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte(`{"name":"users","unixTime":1571460284}`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "No columns")
+
+	_, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte(`{"name":"users","calendarTime":"2019-10-19","columns":{}}`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Fail to parse calendarTime")
+}
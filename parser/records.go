@@ -0,0 +1,30 @@
+package parser
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// unwrapRecords extracts elements of JSON array in the field of a JSON object
+// such as `{"Records":[{...},{...}]}`. AWS services that deliver multiple
+// records in one S3 object (e.g. CloudTrail, AWS Config) use the structure.
+// An empty slice is returned if the field does not exist.
+func unwrapRecords(raw []byte, field string) ([]json.RawMessage, error) {
+	var wrapper map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &wrapper); err != nil {
+		return nil, errors.Wrap(err, "Fail to parse JSON object")
+	}
+
+	data, ok := wrapper[field]
+	if !ok {
+		return nil, nil
+	}
+
+	var records []json.RawMessage
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, errors.Wrapf(err, "Fail to parse '%s' field as array", field)
+	}
+
+	return records, nil
+}
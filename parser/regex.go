@@ -0,0 +1,131 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/pkg/errors"
+)
+
+// Regex is generic parser for line based logs with a regular expression or a Grok
+// expression. Named captures are stored to map[string]interface{} as Values.
+//
+// Pattern is a regular expression that has named groups such as
+// `^(?P<src>\S+) (?P<msg>.*)$`. Grok is a Grok expression such as
+// `%{IP:src} \[%{HTTPDATE:ts}\] %{INT:status:int}` and used if Pattern is empty.
+// Timestamp of LogRecord is zero if TimestampField is nil.
+type Regex struct {
+	Tag     string
+	Pattern string
+	Grok    string
+	// GrokPatterns is additional (or overwriting) Grok pattern library.
+	GrokPatterns map[string]string
+	// Types specifies type conversion of captured value by field name. Available
+	// types are "string" (default), "int", "float" and "bool".
+	Types           map[string]string
+	TimestampField  *string
+	TimestampFormat *string
+
+	once   sync.Once
+	regex  *regexp.Regexp
+	fields map[string]string
+	types  map[string]string
+	err    error
+}
+
+func (x *Regex) compile() error {
+	x.types = map[string]string{}
+
+	switch {
+	case x.Pattern != "":
+		regex, err := regexp.Compile(x.Pattern)
+		if err != nil {
+			return err
+		}
+		x.regex = regex
+		x.fields = map[string]string{}
+		for _, name := range regex.SubexpNames() {
+			if name != "" {
+				x.fields[name] = name
+			}
+		}
+
+	case x.Grok != "":
+		g, err := compileGrok(x.Grok, x.GrokPatterns)
+		if err != nil {
+			return err
+		}
+		x.regex, x.fields = g.regex, g.fields
+		for k, v := range g.types {
+			x.types[k] = v
+		}
+
+	default:
+		return fmt.Errorf("Either one of Pattern and Grok is required")
+	}
+
+	for k, v := range x.Types {
+		x.types[k] = v
+	}
+	if err := validateValueTypes(x.types); err != nil {
+		return err
+	}
+
+	if x.TimestampField != nil && x.TimestampFormat == nil {
+		return fmt.Errorf("TimestampFormat is required, but not set")
+	}
+
+	return nil
+}
+
+// Parse of Regex parses a line with the regular expression or the Grok expression.
+func (x *Regex) Parse(msg *rlogs.MessageQueue) ([]*rlogs.LogRecord, error) {
+	x.once.Do(func() { x.err = x.compile() })
+	if x.err != nil {
+		return nil, errors.Wrap(x.err, "Fail to compile Regex parser")
+	}
+
+	line := strings.TrimRight(string(msg.Raw), "\r\n")
+	match := x.regex.FindStringSubmatchIndex(line)
+	if match == nil {
+		return nil, fmt.Errorf("Log does not match with pattern: %s", line)
+	}
+
+	values := map[string]interface{}{}
+	for i, name := range x.regex.SubexpNames() {
+		field, ok := x.fields[name]
+		if !ok || match[2*i] < 0 {
+			continue // unnamed group or not participating group
+		}
+
+		v, err := convertValue(line[match[2*i]:match[2*i+1]], x.types[field])
+		if err != nil {
+			return nil, errors.Wrapf(err, "Fail to convert field '%s'", field)
+		}
+		values[field] = v
+	}
+
+	var t time.Time
+	if x.TimestampField != nil {
+		p, err := parseTimestampValue(values, *x.TimestampField, *x.TimestampFormat)
+		if err != nil {
+			return nil, err
+		}
+		t = p
+	}
+
+	return []*rlogs.LogRecord{
+		{
+			Tag:       x.Tag,
+			Timestamp: t,
+			Raw:       msg.Raw,
+			Values:    values,
+			Seq:       msg.Seq,
+			Src:       msg.Src,
+		},
+	}, nil
+}
@@ -0,0 +1,111 @@
+package parser_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/m-mizutani/rlogs/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegexParserPattern(t *testing.T) {
+	psr := parser.Regex{
+		Tag:             "app",
+		Pattern:         `^(?P<ts>\S+) \[(?P<level>\w+)\] (?P<user>\w+)(?: took (?P<elapsed>[0-9.]+)s)?: (?P<msg>.*)$`,
+		Types:           map[string]string{"elapsed": "float"},
+		TimestampField:  rlogs.String("ts"),
+		TimestampFormat: rlogs.String("2006-01-02T15:04:05"),
+	}
+	src := &rlogs.AwsS3LogSource{Region: "test-r", Bucket: "test-b", Key: "test-k"}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{
+		Raw: []byte(`2019-10-19T04:44:44 [INFO] alice took 0.25s: login succeeded`),
+		Src: src,
+		Seq: 2,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "app", logs[0].Tag)
+	assert.Equal(t, 2, logs[0].Seq)
+	assert.Equal(t, "2019-10-19T04:44:44Z", logs[0].Timestamp.Format(time.RFC3339))
+
+	values := logs[0].Values.(map[string]interface{})
+	assert.Equal(t, "INFO", values["level"])
+	assert.Equal(t, "alice", values["user"])
+	assert.Equal(t, 0.25, values["elapsed"])
+	assert.Equal(t, "login succeeded", values["msg"])
+
+	// Optional group does not participate
+	logs, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte(`2019-10-19T04:44:45 [WARN] bob: logout`)})
+	require.NoError(t, err)
+	values = logs[0].Values.(map[string]interface{})
+	_, ok := values["elapsed"]
+	assert.False(t, ok)
+}
+
+func TestRegexParserGrok(t *testing.T) {
+	psr := parser.Regex{
+		Grok:            `%{IPORHOST:src.ip} - %{USER:user} \[%{HTTPDATE:ts}\] "%{WORD:method} %{URIPATHPARAM:path} HTTP/%{NUMBER:version}" %{INT:status:int} %{NUMBER:bytes:int} %{LOGLEVEL:level}`,
+		TimestampField:  rlogs.String("ts"),
+		TimestampFormat: rlogs.String("02/Jan/2006:15:04:05 -0700"),
+	}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{
+		Raw: []byte(`192.168.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /index.html?q=1 HTTP/1.1" 200 2326 INFO`),
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "2000-10-10T20:55:36Z", logs[0].Timestamp.Format(time.RFC3339))
+
+	values := logs[0].Values.(map[string]interface{})
+	assert.Equal(t, "192.168.0.1", values["src.ip"])
+	assert.Equal(t, "frank", values["user"])
+	assert.Equal(t, "GET", values["method"])
+	assert.Equal(t, "/index.html?q=1", values["path"])
+	assert.Equal(t, "1.1", values["version"])
+	assert.Equal(t, int64(200), values["status"])
+	assert.Equal(t, int64(2326), values["bytes"])
+	assert.Equal(t, "INFO", values["level"])
+}
+
+func TestRegexParserGrokCustomPattern(t *testing.T) {
+	psr := parser.Regex{
+		Grok:         `%{SYSLOGTIMESTAMP:ts} %{ORDERID:order} %{GREEDYDATA:msg}`,
+		GrokPatterns: map[string]string{"ORDERID": `ORD-[0-9]{6}`},
+	}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(`Oct  1 22:14:15 ORD-123456 shipped to customer`)})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.True(t, logs[0].Timestamp.IsZero())
+
+	values := logs[0].Values.(map[string]interface{})
+	assert.Equal(t, "Oct  1 22:14:15", values["ts"])
+	assert.Equal(t, "ORD-123456", values["order"])
+	assert.Equal(t, "shipped to customer", values["msg"])
+}
+
+func TestRegexParserErrorCase(t *testing.T) {
+	testCases := []struct {
+		psr    *parser.Regex
+		line   string
+		errMsg string
+	}{
+		{&parser.Regex{}, "x", "Either one of Pattern and Grok is required"},
+		{&parser.Regex{Pattern: `(?P<a>`}, "x", "Fail to compile"},
+		{&parser.Regex{Grok: `%{NOT_EXISTS:a}`}, "x", "Grok pattern not found"},
+		{&parser.Regex{Grok: `%{LOOP}`, GrokPatterns: map[string]string{"LOOP": `%{LOOP}`}}, "x", "Too deep"},
+		{&parser.Regex{Pattern: `(?P<a>\w+)`, Types: map[string]string{"a": "time"}}, "x", "Unsupported type"},
+		{&parser.Regex{Pattern: `(?P<a>\w+)`, TimestampField: rlogs.String("a")}, "x", "TimestampFormat is required"},
+		{&parser.Regex{Pattern: `^(?P<a>\d+)$`}, "x", "does not match"},
+		{&parser.Regex{Pattern: `^(?P<a>\w+)$`, Types: map[string]string{"a": "int"}}, "x", "Fail to convert field 'a'"},
+	}
+
+	for _, tc := range testCases {
+		_, err := tc.psr.Parse(&rlogs.MessageQueue{Raw: []byte(tc.line)})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), tc.errMsg)
+	}
+}
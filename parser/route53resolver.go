@@ -0,0 +1,74 @@
+package parser
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/pkg/errors"
+)
+
+// Route53ResolverAnswer is one of answers in Route 53 Resolver query log.
+type Route53ResolverAnswer struct {
+	Rdata string `json:"Rdata"`
+	Type  string `json:"Type"`
+	Class string `json:"Class"`
+}
+
+// Route53ResolverSrcIDs indicates resource that sent the DNS query.
+type Route53ResolverSrcIDs struct {
+	Instance         string `json:"instance,omitempty"`
+	ResolverEndpoint string `json:"resolver_endpoint,omitempty"`
+}
+
+// Route53ResolverQueryLog is a DNS query record generated by Route 53 Resolver query logging.
+type Route53ResolverQueryLog struct {
+	Version        string                  `json:"version"`
+	AccountID      string                  `json:"account_id"`
+	Region         string                  `json:"region"`
+	VpcID          string                  `json:"vpc_id"`
+	QueryTimestamp string                  `json:"query_timestamp"`
+	QueryName      string                  `json:"query_name"`
+	QueryType      string                  `json:"query_type"`
+	QueryClass     string                  `json:"query_class"`
+	Rcode          string                  `json:"rcode"`
+	Answers        []Route53ResolverAnswer `json:"answers"`
+	SrcAddr        string                  `json:"srcaddr"`
+	SrcPort        string                  `json:"srcport"`
+	Transport      string                  `json:"transport"`
+	SrcIDs         Route53ResolverSrcIDs   `json:"srcids"`
+
+	// Available only if Route 53 Resolver DNS Firewall is enabled
+	FirewallRuleAction   string `json:"firewall_rule_action,omitempty"`
+	FirewallRuleGroupID  string `json:"firewall_rule_group_id,omitempty"`
+	FirewallDomainListID string `json:"firewall_domain_list_id,omitempty"`
+}
+
+// Route53ResolverQuery is parser of Route 53 Resolver query logs in AWS S3.
+// The log object is line delimitered JSON, then S3LineLoader is required.
+type Route53ResolverQuery struct{}
+
+// Parse of Route53ResolverQuery parses one query log record.
+func (x *Route53ResolverQuery) Parse(msg *rlogs.MessageQueue) ([]*rlogs.LogRecord, error) {
+	var log Route53ResolverQueryLog
+	if err := json.Unmarshal(msg.Raw, &log); err != nil {
+		return nil, errors.Wrapf(err, "Fail to parse Route 53 Resolver query log: %s", string(msg.Raw))
+	}
+
+	// 2021-02-04T17:51:55Z or 2021-02-04T17:51:55.123Z
+	ts, err := time.Parse(time.RFC3339Nano, log.QueryTimestamp)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Fail to parse timestamp of Route 53 Resolver query log: %v", log.QueryTimestamp)
+	}
+
+	return []*rlogs.LogRecord{
+		{
+			Tag:       "aws.route53resolver",
+			Timestamp: ts.UTC(),
+			Raw:       msg.Raw,
+			Values:    &log,
+			Seq:       msg.Seq,
+			Src:       msg.Src,
+		},
+	}, nil
+}
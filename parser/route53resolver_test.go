@@ -0,0 +1,62 @@
+package parser_test
+
+import (
+	"testing"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/m-mizutani/rlogs/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoute53ResolverQueryParser(t *testing.T) {
+	msg := `{"version":"1.100000","account_id":"123456789012","region":"ap-northeast-1","vpc_id":"vpc-0a1b2c3d4e5f67890","query_timestamp":"2021-02-04T17:51:55.123Z","query_name":"example.com.","query_type":"A","query_class":"IN","rcode":"NOERROR","answers":[{"Rdata":"93.184.216.34","Type":"A","Class":"IN"}],"srcaddr":"10.0.1.23","srcport":"41372","transport":"UDP","srcids":{"instance":"i-0123456789abcdef0"},"firewall_rule_action":"ALERT","firewall_rule_group_id":"rslvr-frg-0123456789abcdef","firewall_domain_list_id":"rslvr-fdl-0123456789abcdef"}`
+	src := &rlogs.AwsS3LogSource{Region: "test-r", Bucket: "test-b", Key: "test-k"}
+	psr := parser.Route53ResolverQuery{}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{
+		Raw: []byte(msg),
+		Src: src,
+		Seq: 3,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "aws.route53resolver", logs[0].Tag)
+	assert.Equal(t, 3, logs[0].Seq)
+	assert.Equal(t, "2021-02-04T17:51:55.123", logs[0].Timestamp.Format("2006-01-02T15:04:05.000"))
+
+	log := logs[0].Values.(*parser.Route53ResolverQueryLog)
+	assert.Equal(t, "vpc-0a1b2c3d4e5f67890", log.VpcID)
+	assert.Equal(t, "example.com.", log.QueryName)
+	assert.Equal(t, "A", log.QueryType)
+	assert.Equal(t, "NOERROR", log.Rcode)
+	require.Equal(t, 1, len(log.Answers))
+	assert.Equal(t, "93.184.216.34", log.Answers[0].Rdata)
+	assert.Equal(t, "10.0.1.23", log.SrcAddr)
+	assert.Equal(t, "i-0123456789abcdef0", log.SrcIDs.Instance)
+	assert.Equal(t, "ALERT", log.FirewallRuleAction)
+	assert.Equal(t, "rslvr-frg-0123456789abcdef", log.FirewallRuleGroupID)
+	assert.Equal(t, "rslvr-fdl-0123456789abcdef", log.FirewallDomainListID)
+}
+
+func TestRoute53ResolverQueryParserWithoutFraction(t *testing.T) {
+	msg := `{"version":"1.100000","query_timestamp":"2021-02-04T17:51:55Z","query_name":"example.org.","query_type":"AAAA","rcode":"NXDOMAIN","answers":[],"srcids":{"resolver_endpoint":"rslvr-in-0123456789abcdef"}}`
+	psr := parser.Route53ResolverQuery{}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(msg)})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, 55, logs[0].Timestamp.Second())
+
+	log := logs[0].Values.(*parser.Route53ResolverQueryLog)
+	assert.Equal(t, "rslvr-in-0123456789abcdef", log.SrcIDs.ResolverEndpoint)
+	assert.Equal(t, 0, len(log.Answers))
+}
+
+func TestRoute53ResolverQueryParserInvalidTimestamp(t *testing.T) {
+	psr := parser.Route53ResolverQuery{}
+
+	_, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(`{"query_timestamp":"2021/02/04 17:51:55"}`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Fail to parse timestamp")
+}
@@ -0,0 +1,53 @@
+package parser
+
+import (
+	"encoding/json"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/pkg/errors"
+)
+
+// Row is parser of a row of Parquet or ORC or a record of Avro that is encoded
+// to JSON object by S3ParquetLoader, S3ORCLoader or S3AvroLoader. Values of
+// LogRecord is map[string]interface{} by default, or a user struct created by
+// New. Timestamp is extracted from the map by TimestampOptions.
+type Row struct {
+	Tag string
+	// New returns pointer of a user struct that the row is unmarshaled to.
+	New func() interface{}
+
+	TimestampOptions
+}
+
+// Parse of Row converts one row to LogRecord.
+func (x *Row) Parse(msg *rlogs.MessageQueue) ([]*rlogs.LogRecord, error) {
+	var value map[string]interface{}
+	if err := json.Unmarshal(msg.Raw, &value); err != nil {
+		return nil, errors.Wrapf(err, "Fail to parse row: %s", string(msg.Raw))
+	}
+
+	t, err := x.TimestampOptions.extract(value, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var values interface{} = value
+	if x.New != nil {
+		obj := x.New()
+		if err := json.Unmarshal(msg.Raw, obj); err != nil {
+			return nil, errors.Wrapf(err, "Fail to unmarshal row to %T: %s", obj, string(msg.Raw))
+		}
+		values = obj
+	}
+
+	return []*rlogs.LogRecord{
+		{
+			Tag:       x.Tag,
+			Timestamp: t,
+			Raw:       msg.Raw,
+			Values:    values,
+			Seq:       msg.Seq,
+			Src:       msg.Src,
+		},
+	}, nil
+}
@@ -0,0 +1,61 @@
+package parser_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/m-mizutani/rlogs/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRowParserMap(t *testing.T) {
+	psr := parser.Row{
+		Tag:              "security_lake.cloudtrail",
+		TimestampOptions: parser.TimestampOptions{UnixtimeMilliField: rlogs.String("time")},
+	}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{
+		Raw: []byte(`{"time":1571460284123,"class_uid":3002,"api":{"operation":"ConsoleLogin"}}`),
+		Seq: 3,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "security_lake.cloudtrail", logs[0].Tag)
+	assert.Equal(t, 3, logs[0].Seq)
+	assert.Equal(t, "2019-10-19T04:44:44.123Z", logs[0].Timestamp.Format(time.RFC3339Nano))
+
+	value := logs[0].Values.(map[string]interface{})
+	assert.Equal(t, float64(3002), value["class_uid"])
+}
+
+type rowTestRecord struct {
+	Name string `json:"name"`
+	Port int    `json:"port"`
+	Time string `json:"time"`
+}
+
+func TestRowParserUserStruct(t *testing.T) {
+	psr := parser.Row{
+		Tag: "hosts",
+		New: func() interface{} { return &rowTestRecord{} },
+		TimestampOptions: parser.TimestampOptions{
+			TimestampField:  rlogs.String("time"),
+			TimestampFormat: rlogs.String(time.RFC3339Nano),
+		},
+	}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(`{"name":"host0","port":8000,"time":"2019-10-19T04:44:44.123Z"}`)})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, 44, logs[0].Timestamp.Second())
+
+	record := logs[0].Values.(*rowTestRecord)
+	assert.Equal(t, "host0", record.Name)
+	assert.Equal(t, 8000, record.Port)
+
+	_, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte(`{"name":"host0","port":"x","time":"2019-10-19T04:44:44.123Z"}`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Fail to unmarshal row")
+}
@@ -0,0 +1,110 @@
+package parser
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/pkg/errors"
+)
+
+// AsffSeverity is severity of AWS Security Finding Format.
+type AsffSeverity struct {
+	Label      string  `json:"Label"`
+	Normalized int     `json:"Normalized"`
+	Original   string  `json:"Original"`
+	Product    float64 `json:"Product"`
+}
+
+// AsffResource is a resource that the finding refers to. Details depend on
+// Type, then they are kept as generic map.
+type AsffResource struct {
+	Type      string                 `json:"Type"`
+	ID        string                 `json:"Id"`
+	Partition string                 `json:"Partition"`
+	Region    string                 `json:"Region"`
+	Tags      map[string]string      `json:"Tags,omitempty"`
+	Details   map[string]interface{} `json:"Details,omitempty"`
+}
+
+// AsffStatus is common structure of Compliance and Workflow in ASFF.
+type AsffStatus struct {
+	Status string `json:"Status"`
+}
+
+// AsffFinding is a finding of AWS Security Finding Format (ASFF) that is used
+// by AWS Security Hub.
+type AsffFinding struct {
+	SchemaVersion   string         `json:"SchemaVersion"`
+	ID              string         `json:"Id"`
+	ProductArn      string         `json:"ProductArn"`
+	ProductName     string         `json:"ProductName"`
+	CompanyName     string         `json:"CompanyName"`
+	Region          string         `json:"Region"`
+	GeneratorID     string         `json:"GeneratorId"`
+	AwsAccountID    string         `json:"AwsAccountId"`
+	Types           []string       `json:"Types"`
+	FirstObservedAt string         `json:"FirstObservedAt"`
+	LastObservedAt  string         `json:"LastObservedAt"`
+	CreatedAt       string         `json:"CreatedAt"`
+	UpdatedAt       string         `json:"UpdatedAt"`
+	Severity        AsffSeverity   `json:"Severity"`
+	Title           string         `json:"Title"`
+	Description     string         `json:"Description"`
+	Resources       []AsffResource `json:"Resources"`
+	Compliance      *AsffStatus    `json:"Compliance,omitempty"`
+	Workflow        *AsffStatus    `json:"Workflow,omitempty"`
+	RecordState     string         `json:"RecordState"`
+}
+
+type securityHubEvent struct {
+	Detail struct {
+		Findings []json.RawMessage `json:"findings"`
+	} `json:"detail"`
+}
+
+// SecurityHub is parser of AWS Security Hub findings in ASFF. The parser accepts
+// both of EventBridge event that has findings in .detail.findings and a single
+// ASFF finding. Findings in an EventBridge event have Seq of the message and
+// Pipeline numbers them in the object. One line should have one JSON document,
+// then S3LineLoader is required.
+type SecurityHub struct{}
+
+// Parse of SecurityHub converts ASFF finding(s) to LogRecord(s) and uses UpdatedAt as timestamp.
+func (x *SecurityHub) Parse(msg *rlogs.MessageQueue) ([]*rlogs.LogRecord, error) {
+	var event securityHubEvent
+	if err := json.Unmarshal(msg.Raw, &event); err != nil {
+		return nil, errors.Wrapf(err, "Fail to parse Security Hub event: %s", string(msg.Raw))
+	}
+
+	findings := event.Detail.Findings
+	if findings == nil {
+		// Not EventBridge event, but a single finding
+		findings = []json.RawMessage{msg.Raw}
+	}
+
+	var logs []*rlogs.LogRecord
+	for idx, raw := range findings {
+		var finding AsffFinding
+		if err := json.Unmarshal(raw, &finding); err != nil {
+			return nil, errors.Wrapf(err, "Fail to unmarshal ASFF finding [%d]: %s", idx, string(raw))
+		}
+
+		// 2020-03-22T13:22:13.933Z
+		ts, err := time.Parse(time.RFC3339Nano, finding.UpdatedAt)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Fail to parse UpdatedAt of ASFF finding: %v", finding.UpdatedAt)
+		}
+
+		logs = append(logs, &rlogs.LogRecord{
+			Tag:       "aws.securityhub",
+			Timestamp: ts.UTC(),
+			Raw:       raw,
+			Values:    &finding,
+			Seq:       msg.Seq,
+			Src:       msg.Src,
+		})
+	}
+
+	return logs, nil
+}
@@ -0,0 +1,56 @@
+package parser_test
+
+import (
+	"testing"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/m-mizutani/rlogs/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecurityHubParserEventBridge(t *testing.T) {
+	msg := `{"version":"0","id":"8e5622f9-d81c-4d81-612a-9319e7ee2506","detail-type":"Security Hub Findings - Imported","source":"aws.securityhub","account":"123456789012","time":"2020-03-22T13:22:14Z","region":"ap-northeast-1","resources":[],"detail":{"findings":[{"SchemaVersion":"2018-10-08","Id":"arn:aws:securityhub:ap-northeast-1:123456789012:subscription/aws-foundational-security-best-practices/v/1.0.0/S3.1/finding/1","ProductArn":"arn:aws:securityhub:ap-northeast-1::product/aws/securityhub","GeneratorId":"aws-foundational-security-best-practices/v/1.0.0/S3.1","AwsAccountId":"123456789012","Types":["Software and Configuration Checks/Industry and Regulatory Standards"],"CreatedAt":"2020-03-20T10:00:00.000Z","UpdatedAt":"2020-03-22T13:22:13.933Z","Severity":{"Label":"MEDIUM","Normalized":40,"Original":"MEDIUM"},"Title":"S3.1 S3 Block Public Access setting should be enabled","Resources":[{"Type":"AwsAccount","Id":"AWS::::Account:123456789012","Partition":"aws","Region":"ap-northeast-1"}],"Compliance":{"Status":"FAILED"},"Workflow":{"Status":"NEW"},"RecordState":"ACTIVE"},{"SchemaVersion":"2018-10-08","Id":"finding-2","AwsAccountId":"123456789012","UpdatedAt":"2020-03-22T13:22:14.000Z","Severity":{"Label":"LOW"}}]}}`
+	src := &rlogs.AwsS3LogSource{Region: "test-r", Bucket: "test-b", Key: "test-k"}
+	psr := parser.SecurityHub{}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(msg), Src: src, Seq: 2})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(logs))
+	assert.Equal(t, "aws.securityhub", logs[0].Tag)
+	assert.Equal(t, 2, logs[0].Seq)
+	assert.Equal(t, 2, logs[1].Seq)
+	assert.Equal(t, "2020-03-22T13:22:13", logs[0].Timestamp.Format("2006-01-02T15:04:05"))
+
+	finding := logs[0].Values.(*parser.AsffFinding)
+	assert.Equal(t, "aws-foundational-security-best-practices/v/1.0.0/S3.1", finding.GeneratorID)
+	assert.Equal(t, "MEDIUM", finding.Severity.Label)
+	assert.Equal(t, 40, finding.Severity.Normalized)
+	require.Equal(t, 1, len(finding.Resources))
+	assert.Equal(t, "AwsAccount", finding.Resources[0].Type)
+	assert.Equal(t, "FAILED", finding.Compliance.Status)
+	assert.Equal(t, "NEW", finding.Workflow.Status)
+
+	finding = logs[1].Values.(*parser.AsffFinding)
+	assert.Equal(t, "finding-2", finding.ID)
+	assert.Nil(t, finding.Compliance)
+}
+
+func TestSecurityHubParserSingleFinding(t *testing.T) {
+	msg := `{"SchemaVersion":"2018-10-08","Id":"finding-3","AwsAccountId":"123456789012","UpdatedAt":"2020-03-22T13:22:14Z","Severity":{"Label":"HIGH"}}`
+	psr := parser.SecurityHub{}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(msg), Seq: 5})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, 5, logs[0].Seq)
+	assert.Equal(t, "HIGH", logs[0].Values.(*parser.AsffFinding).Severity.Label)
+}
+
+func TestSecurityHubParserInvalidUpdatedAt(t *testing.T) {
+	psr := parser.SecurityHub{}
+
+	_, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(`{"Id":"finding-4"}`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Fail to parse UpdatedAt")
+}
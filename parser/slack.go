@@ -0,0 +1,100 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/pkg/errors"
+)
+
+// SlackAuditObject is an object of Slack audit log, e.g. user, channel and file.
+type SlackAuditObject struct {
+	ID      string `json:"id"`
+	Name    string `json:"name,omitempty"`
+	Email   string `json:"email,omitempty"`
+	Team    string `json:"team,omitempty"`
+	Domain  string `json:"domain,omitempty"`
+	Privacy string `json:"privacy,omitempty"`
+}
+
+// SlackAuditEntity is actor or entity (target) of Slack audit log. Only a
+// field that matches Type is set.
+type SlackAuditEntity struct {
+	Type       string            `json:"type"`
+	User       *SlackAuditObject `json:"user,omitempty"`
+	Channel    *SlackAuditObject `json:"channel,omitempty"`
+	File       *SlackAuditObject `json:"file,omitempty"`
+	App        *SlackAuditObject `json:"app,omitempty"`
+	Workspace  *SlackAuditObject `json:"workspace,omitempty"`
+	Enterprise *SlackAuditObject `json:"enterprise,omitempty"`
+	Workflow   *SlackAuditObject `json:"workflow,omitempty"`
+	Message    *SlackAuditObject `json:"message,omitempty"`
+}
+
+// SlackAuditContext is context where the action occurred.
+type SlackAuditContext struct {
+	Location  SlackAuditObject `json:"location"`
+	UA        string           `json:"ua"`
+	IPAddress string           `json:"ip_address"`
+	SessionID json.Number      `json:"session_id,omitempty"`
+}
+
+// SlackAuditEntry is an entry of Slack Audit Logs API.
+type SlackAuditEntry struct {
+	ID         string                 `json:"id"`
+	DateCreate int64                  `json:"date_create"`
+	Action     string                 `json:"action"`
+	Actor      SlackAuditEntity       `json:"actor"`
+	Entity     SlackAuditEntity       `json:"entity"`
+	Context    SlackAuditContext      `json:"context"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+}
+
+// SlackAudit is parser of Slack audit logs. It accepts both of a response of
+// Audit Logs API that has "entries" array (use with S3FileLoader) and a single
+// entry per line (use with S3LineLoader).
+type SlackAudit struct{}
+
+// Parse of SlackAudit converts entry(s) to LogRecord(s) and uses date_create
+// (unix time in seconds) as timestamp.
+func (x *SlackAudit) Parse(msg *rlogs.MessageQueue) ([]*rlogs.LogRecord, error) {
+	entries, err := unwrapRecords(msg.Raw, "entries")
+	if err != nil {
+		return nil, errors.Wrap(err, "Fail to parse Slack audit log")
+	}
+
+	wrapped := entries != nil
+	if !wrapped {
+		entries = []json.RawMessage{msg.Raw}
+	}
+
+	var logs []*rlogs.LogRecord
+	for idx, raw := range entries {
+		var entry SlackAuditEntry
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			return nil, errors.Wrapf(err, "Fail to unmarshal Slack audit log entry [%d]: %s", idx, string(raw))
+		}
+
+		if entry.DateCreate == 0 {
+			return nil, fmt.Errorf("No date_create in Slack audit log entry [%d]: %s", idx, string(raw))
+		}
+
+		seq := msg.Seq
+		if wrapped {
+			seq = idx
+		}
+
+		logs = append(logs, &rlogs.LogRecord{
+			Tag:       "slack.audit",
+			Timestamp: time.Unix(entry.DateCreate, 0).UTC(),
+			Raw:       raw,
+			Values:    &entry,
+			Seq:       seq,
+			Src:       msg.Src,
+		})
+	}
+
+	return logs, nil
+}
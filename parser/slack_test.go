@@ -0,0 +1,51 @@
+package parser_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/m-mizutani/rlogs/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlackAuditParserEntries(t *testing.T) {
+	msg := `{"entries":[{"id":"0123a45b-6c7d-8900-e12f-3456789gh0i1","date_create":1571460284,"action":"user_login","actor":{"type":"user","user":{"id":"W123AB456","name":"Alice","email":"alice@example.com","team":"T123AB456"}},"entity":{"type":"user","user":{"id":"W123AB456","name":"Alice","email":"alice@example.com","team":"T123AB456"}},"context":{"location":{"type":"enterprise","id":"E1701NCCA","name":"Example","domain":"example"},"ua":"Mozilla/5.0","ip_address":"203.0.113.1","session_id":1234567890}},{"id":"2","date_create":1571460300,"action":"file_downloaded","actor":{"type":"user","user":{"id":"W123AB456"}},"entity":{"type":"file","file":{"id":"F123","name":"secret.pdf"}},"context":{"location":{"type":"workspace","id":"T123AB456"}}}],"response_metadata":{"next_cursor":""}}`
+	src := &rlogs.AwsS3LogSource{Region: "test-r", Bucket: "test-b", Key: "test-k"}
+	psr := parser.SlackAudit{}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(msg), Src: src})
+	require.NoError(t, err)
+	require.Equal(t, 2, len(logs))
+	assert.Equal(t, "slack.audit", logs[0].Tag)
+	assert.Equal(t, 0, logs[0].Seq)
+	assert.Equal(t, 1, logs[1].Seq)
+	assert.Equal(t, "2019-10-19T04:44:44Z", logs[0].Timestamp.Format(time.RFC3339Nano))
+
+	e0 := logs[0].Values.(*parser.SlackAuditEntry)
+	assert.Equal(t, "user_login", e0.Action)
+	require.NotNil(t, e0.Actor.User)
+	assert.Equal(t, "alice@example.com", e0.Actor.User.Email)
+	assert.Equal(t, "203.0.113.1", e0.Context.IPAddress)
+	assert.Equal(t, "1234567890", e0.Context.SessionID.String())
+
+	e1 := logs[1].Values.(*parser.SlackAuditEntry)
+	assert.Equal(t, "file", e1.Entity.Type)
+	require.NotNil(t, e1.Entity.File)
+	assert.Equal(t, "secret.pdf", e1.Entity.File.Name)
+	assert.Nil(t, e1.Entity.User)
+}
+
+func TestSlackAuditParserSingleEntry(t *testing.T) {
+	psr := parser.SlackAudit{}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(`{"id":"1","date_create":1571460284,"action":"user_logout","actor":{"type":"user","user":{"id":"W1"}}}`), Seq: 5})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, 5, logs[0].Seq)
+
+	_, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte(`{"id":"1","action":"user_logout"}`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "No date_create")
+}
@@ -0,0 +1,165 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/pkg/errors"
+)
+
+// SuricataAlert is "alert" section of Suricata EVE event.
+type SuricataAlert struct {
+	Action      string              `json:"action"`
+	GID         int                 `json:"gid"`
+	SignatureID int                 `json:"signature_id"`
+	Rev         int                 `json:"rev"`
+	Signature   string              `json:"signature"`
+	Category    string              `json:"category"`
+	Severity    int                 `json:"severity"`
+	Metadata    map[string][]string `json:"metadata,omitempty"`
+}
+
+// SuricataFlow is "flow" section of Suricata EVE event.
+type SuricataFlow struct {
+	PktsToServer  int64  `json:"pkts_toserver"`
+	PktsToClient  int64  `json:"pkts_toclient"`
+	BytesToServer int64  `json:"bytes_toserver"`
+	BytesToClient int64  `json:"bytes_toclient"`
+	Start         string `json:"start"`
+	End           string `json:"end"`
+	Age           int64  `json:"age"`
+	State         string `json:"state"`
+	Reason        string `json:"reason"`
+	Alerted       bool   `json:"alerted"`
+}
+
+// SuricataDNSAnswer is an answer in "dns" section of Suricata EVE event.
+type SuricataDNSAnswer struct {
+	RRName string `json:"rrname"`
+	RRType string `json:"rrtype"`
+	TTL    int    `json:"ttl"`
+	RData  string `json:"rdata"`
+}
+
+// SuricataDNS is "dns" section of Suricata EVE event. Both of query and answer
+// (version 1 and 2 format) are supported.
+type SuricataDNS struct {
+	Version int                 `json:"version,omitempty"`
+	Type    string              `json:"type"`
+	ID      int                 `json:"id"`
+	Flags   string              `json:"flags,omitempty"`
+	RRName  string              `json:"rrname"`
+	RRType  string              `json:"rrtype"`
+	RCode   string              `json:"rcode,omitempty"`
+	RData   string              `json:"rdata,omitempty"`
+	TTL     int                 `json:"ttl,omitempty"`
+	TxID    int                 `json:"tx_id"`
+	Answers []SuricataDNSAnswer `json:"answers,omitempty"`
+}
+
+// SuricataHTTP is "http" section of Suricata EVE event.
+type SuricataHTTP struct {
+	Hostname        string `json:"hostname"`
+	URL             string `json:"url"`
+	HTTPUserAgent   string `json:"http_user_agent"`
+	HTTPContentType string `json:"http_content_type"`
+	HTTPRefer       string `json:"http_refer"`
+	HTTPMethod      string `json:"http_method"`
+	Protocol        string `json:"protocol"`
+	Status          int    `json:"status"`
+	Length          int64  `json:"length"`
+}
+
+// SuricataJA3 is JA3/JA3S fingerprint of TLS session.
+type SuricataJA3 struct {
+	Hash   string `json:"hash"`
+	String string `json:"string"`
+}
+
+// SuricataTLS is "tls" section of Suricata EVE event.
+type SuricataTLS struct {
+	Subject     string       `json:"subject"`
+	IssuerDN    string       `json:"issuerdn"`
+	Serial      string       `json:"serial"`
+	Fingerprint string       `json:"fingerprint"`
+	SNI         string       `json:"sni"`
+	Version     string       `json:"version"`
+	NotBefore   string       `json:"notbefore"`
+	NotAfter    string       `json:"notafter"`
+	JA3         *SuricataJA3 `json:"ja3,omitempty"`
+	JA3S        *SuricataJA3 `json:"ja3s,omitempty"`
+}
+
+// SuricataFileInfo is "fileinfo" section of Suricata EVE event.
+type SuricataFileInfo struct {
+	Filename string `json:"filename"`
+	Magic    string `json:"magic"`
+	Gaps     bool   `json:"gaps"`
+	State    string `json:"state"`
+	MD5      string `json:"md5"`
+	SHA1     string `json:"sha1"`
+	SHA256   string `json:"sha256"`
+	Stored   bool   `json:"stored"`
+	Size     int64  `json:"size"`
+	TxID     int    `json:"tx_id"`
+}
+
+// SuricataEvent is an event of Suricata EVE JSON output. Only a section
+// related to EventType is available in general (e.g. Alert for "alert"),
+// but an alert event may have also app layer sections such as HTTP and TLS.
+type SuricataEvent struct {
+	Timestamp   string `json:"timestamp"`
+	FlowID      int64  `json:"flow_id"`
+	InIface     string `json:"in_iface"`
+	EventType   string `json:"event_type"`
+	SrcIP       string `json:"src_ip"`
+	SrcPort     int    `json:"src_port"`
+	DestIP      string `json:"dest_ip"`
+	DestPort    int    `json:"dest_port"`
+	Proto       string `json:"proto"`
+	AppProto    string `json:"app_proto,omitempty"`
+	CommunityID string `json:"community_id,omitempty"`
+
+	Alert    *SuricataAlert    `json:"alert,omitempty"`
+	Flow     *SuricataFlow     `json:"flow,omitempty"`
+	DNS      *SuricataDNS      `json:"dns,omitempty"`
+	HTTP     *SuricataHTTP     `json:"http,omitempty"`
+	TLS      *SuricataTLS      `json:"tls,omitempty"`
+	FileInfo *SuricataFileInfo `json:"fileinfo,omitempty"`
+}
+
+// SuricataEVE is parser of Suricata EVE JSON output. Tag of LogRecord is
+// "suricata." + event_type, e.g. "suricata.alert" and "suricata.dns". One line
+// should have one event, then S3LineLoader is required.
+type SuricataEVE struct{}
+
+// Parse of SuricataEVE parses one EVE event.
+func (x *SuricataEVE) Parse(msg *rlogs.MessageQueue) ([]*rlogs.LogRecord, error) {
+	var event SuricataEvent
+	if err := json.Unmarshal(msg.Raw, &event); err != nil {
+		return nil, errors.Wrapf(err, "Fail to parse Suricata EVE event: %s", string(msg.Raw))
+	}
+
+	if event.EventType == "" {
+		return nil, fmt.Errorf("No event_type in Suricata EVE event: %s", string(msg.Raw))
+	}
+
+	// 2019-10-19T04:44:44.123456+0000
+	ts, err := time.Parse("2006-01-02T15:04:05.999999-0700", event.Timestamp)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Fail to parse timestamp of Suricata EVE event: %v", event.Timestamp)
+	}
+
+	return []*rlogs.LogRecord{
+		{
+			Tag:       "suricata." + event.EventType,
+			Timestamp: ts.UTC(),
+			Raw:       msg.Raw,
+			Values:    &event,
+			Seq:       msg.Seq,
+			Src:       msg.Src,
+		},
+	}, nil
+}
@@ -0,0 +1,105 @@
+package parser_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/m-mizutani/rlogs/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuricataEVEParserAlert(t *testing.T) {
+	msg := `{"timestamp":"2019-10-19T13:44:44.123456+0900","flow_id":1805461738637437,"in_iface":"eth0","event_type":"alert","src_ip":"10.0.0.1","src_port":52345,"dest_ip":"203.0.113.10","dest_port":80,"proto":"TCP","app_proto":"http","alert":{"action":"allowed","gid":1,"signature_id":2100498,"rev":7,"signature":"GPL ATTACK_RESPONSE id check returned root","category":"Potentially Bad Traffic","severity":2,"metadata":{"updated_at":["2010_09_23"]}},"http":{"hostname":"example.com","url":"/","http_user_agent":"curl/7.58.0","http_method":"GET","protocol":"HTTP/1.1","status":200,"length":39}}`
+	src := &rlogs.AwsS3LogSource{Region: "test-r", Bucket: "test-b", Key: "test-k"}
+	psr := parser.SuricataEVE{}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(msg), Src: src, Seq: 1})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "suricata.alert", logs[0].Tag)
+	assert.Equal(t, "2019-10-19T04:44:44.123456Z", logs[0].Timestamp.Format(time.RFC3339Nano))
+
+	event := logs[0].Values.(*parser.SuricataEvent)
+	assert.Equal(t, int64(1805461738637437), event.FlowID)
+	assert.Equal(t, "10.0.0.1", event.SrcIP)
+	assert.Equal(t, 80, event.DestPort)
+	require.NotNil(t, event.Alert)
+	assert.Equal(t, 2100498, event.Alert.SignatureID)
+	assert.Equal(t, 2, event.Alert.Severity)
+	assert.Equal(t, []string{"2010_09_23"}, event.Alert.Metadata["updated_at"])
+	require.NotNil(t, event.HTTP)
+	assert.Equal(t, "example.com", event.HTTP.Hostname)
+	assert.Equal(t, 200, event.HTTP.Status)
+	assert.Nil(t, event.DNS)
+}
+
+func TestSuricataEVEParserEventTypes(t *testing.T) {
+	testCases := []struct {
+		msg    string
+		tag    string
+		verify func(event *parser.SuricataEvent)
+	}{
+		{
+			msg: `{"timestamp":"2019-10-19T04:44:44.000000+0000","event_type":"dns","src_ip":"10.0.0.1","dest_ip":"8.8.8.8","proto":"UDP","dns":{"version":2,"type":"answer","id":4660,"rrname":"example.com","rrtype":"A","rcode":"NOERROR","answers":[{"rrname":"example.com","rrtype":"A","ttl":3600,"rdata":"93.184.216.34"}]}}`,
+			tag: "suricata.dns",
+			verify: func(event *parser.SuricataEvent) {
+				require.NotNil(t, event.DNS)
+				assert.Equal(t, "answer", event.DNS.Type)
+				require.Equal(t, 1, len(event.DNS.Answers))
+				assert.Equal(t, "93.184.216.34", event.DNS.Answers[0].RData)
+			},
+		},
+		{
+			msg: `{"timestamp":"2019-10-19T04:44:44.000000+0000","event_type":"flow","proto":"TCP","flow":{"pkts_toserver":10,"pkts_toclient":8,"bytes_toserver":1200,"bytes_toclient":5400,"start":"2019-10-19T04:44:00.000000+0000","end":"2019-10-19T04:44:30.000000+0000","age":30,"state":"closed","reason":"timeout","alerted":false}}`,
+			tag: "suricata.flow",
+			verify: func(event *parser.SuricataEvent) {
+				require.NotNil(t, event.Flow)
+				assert.Equal(t, int64(5400), event.Flow.BytesToClient)
+				assert.Equal(t, "closed", event.Flow.State)
+			},
+		},
+		{
+			msg: `{"timestamp":"2019-10-19T04:44:44.000000+0000","event_type":"tls","proto":"TCP","tls":{"subject":"CN=example.com","issuerdn":"CN=Example CA","sni":"example.com","version":"TLS 1.2","ja3":{"hash":"e7d705a3286e19ea42f587b344ee6865","string":"771,49195"}}}`,
+			tag: "suricata.tls",
+			verify: func(event *parser.SuricataEvent) {
+				require.NotNil(t, event.TLS)
+				assert.Equal(t, "example.com", event.TLS.SNI)
+				require.NotNil(t, event.TLS.JA3)
+				assert.Equal(t, "e7d705a3286e19ea42f587b344ee6865", event.TLS.JA3.Hash)
+				assert.Nil(t, event.TLS.JA3S)
+			},
+		},
+		{
+			msg: `{"timestamp":"2019-10-19T04:44:44.000000+0000","event_type":"fileinfo","proto":"TCP","app_proto":"http","fileinfo":{"filename":"/malware.exe","magic":"PE32 executable","state":"CLOSED","sha256":"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08","stored":false,"size":4096,"tx_id":0}}`,
+			tag: "suricata.fileinfo",
+			verify: func(event *parser.SuricataEvent) {
+				require.NotNil(t, event.FileInfo)
+				assert.Equal(t, "/malware.exe", event.FileInfo.Filename)
+				assert.Equal(t, int64(4096), event.FileInfo.Size)
+			},
+		},
+	}
+
+	psr := parser.SuricataEVE{}
+	for _, tc := range testCases {
+		logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(tc.msg)})
+		require.NoError(t, err)
+		require.Equal(t, 1, len(logs))
+		assert.Equal(t, tc.tag, logs[0].Tag)
+		tc.verify(logs[0].Values.(*parser.SuricataEvent))
+	}
+}
+
+func TestSuricataEVEParserErrorCase(t *testing.T) {
+	psr := parser.SuricataEVE{}
+
+	_, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(`{"timestamp":"2019-10-19T04:44:44.000000+0000"}`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "No event_type")
+
+	_, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte(`{"timestamp":"2019-10-19 04:44:44","event_type":"dns"}`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Fail to parse timestamp")
+}
@@ -0,0 +1,274 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/pkg/errors"
+)
+
+var syslogFacilityNames = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+var syslogSeverityNames = []string{
+	"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug",
+}
+
+// SyslogMessage is a syslog message of RFC 5424 or RFC 3164. Priority, Facility
+// and Severity are -1 if PRI part does not exist (e.g. a line written by syslog
+// daemon to a file). Version is 0 for RFC 3164 message.
+type SyslogMessage struct {
+	Priority     int
+	Facility     int
+	FacilityName string
+	Severity     int
+	SeverityName string
+	Version      int
+	Timestamp    time.Time
+	Hostname     string
+	AppName      string
+	ProcID       string
+	MsgID        string
+	// StructuredData has SD-PARAMs by SD-ID. It is available only in RFC 5424.
+	StructuredData map[string]map[string]string
+	Message        string
+}
+
+// Syslog is parser of syslog messages in RFC 5424 and RFC 3164. RFC 3164
+// timestamp has neither year nor timezone, then Year and Location are used for
+// it. One line should have one message, then S3LineLoader is required.
+type Syslog struct {
+	// Tag of LogRecord. "syslog" is used if empty.
+	Tag string
+	// Year is used for RFC 3164 timestamp. The current year is used if 0.
+	Year int
+	// Location is used for RFC 3164 timestamp. UTC is used if nil.
+	Location *time.Location
+}
+
+// Parse of Syslog parses one syslog message.
+func (x *Syslog) Parse(msg *rlogs.MessageQueue) ([]*rlogs.LogRecord, error) {
+	log, err := x.parseMessage(strings.TrimRight(string(msg.Raw), "\r\n"))
+	if err != nil {
+		return nil, err
+	}
+
+	return x.newLogRecord(msg, log), nil
+}
+
+// parseMessage parses a syslog message. It is also used to parse syslog header
+// prefixed to other formats such as CEF and LEEF.
+func (x *Syslog) parseMessage(line string) (*SyslogMessage, error) {
+	raw := line
+	log := SyslogMessage{Priority: -1, Facility: -1, Severity: -1}
+	if strings.HasPrefix(line, "<") {
+		end := strings.IndexByte(line, '>')
+		if end < 0 {
+			return nil, fmt.Errorf("Invalid syslog PRI part: %s", raw)
+		}
+		pri, err := strconv.Atoi(line[1:end])
+		if err != nil || pri < 0 || pri > 191 {
+			return nil, fmt.Errorf("Invalid syslog PRI value: %s", raw)
+		}
+
+		log.Priority = pri
+		log.Facility, log.Severity = pri/8, pri%8
+		log.FacilityName = syslogFacilityNames[log.Facility]
+		log.SeverityName = syslogSeverityNames[log.Severity]
+		line = line[end+1:]
+	}
+
+	// RFC 5424 has VERSION (1-3 digits) just after PRI part
+	if sp := strings.IndexByte(line, ' '); sp > 0 && sp <= 3 && log.Priority >= 0 {
+		if v, err := strconv.Atoi(line[:sp]); err == nil {
+			log.Version = v
+			if err := parseSyslog5424(line[sp+1:], &log); err != nil {
+				return nil, errors.Wrapf(err, "Fail to parse RFC 5424 syslog: %s", raw)
+			}
+			return &log, nil
+		}
+	}
+
+	if err := x.parseSyslog3164(line, &log); err != nil {
+		return nil, errors.Wrapf(err, "Fail to parse RFC 3164 syslog: %s", raw)
+	}
+	return &log, nil
+}
+
+func (x *Syslog) newLogRecord(msg *rlogs.MessageQueue, log *SyslogMessage) []*rlogs.LogRecord {
+	tag := x.Tag
+	if tag == "" {
+		tag = "syslog"
+	}
+
+	return []*rlogs.LogRecord{
+		{
+			Tag:       tag,
+			Timestamp: log.Timestamp.UTC(),
+			Raw:       msg.Raw,
+			Values:    log,
+			Seq:       msg.Seq,
+			Src:       msg.Src,
+		},
+	}
+}
+
+// nextSyslogField returns a space delimitered field and the rest. "-" (NILVALUE) is
+// converted to empty string.
+func nextSyslogField(s string) (string, string) {
+	var field, rest string
+	if sp := strings.IndexByte(s, ' '); sp < 0 {
+		field = s
+	} else {
+		field, rest = s[:sp], s[sp+1:]
+	}
+
+	if field == "-" {
+		field = ""
+	}
+	return field, rest
+}
+
+func parseSyslog5424(s string, log *SyslogMessage) error {
+	var ts string
+	ts, s = nextSyslogField(s)
+	if ts != "" {
+		t, err := time.Parse(time.RFC3339Nano, ts)
+		if err != nil {
+			return errors.Wrapf(err, "Invalid timestamp: %s", ts)
+		}
+		log.Timestamp = t
+	}
+
+	log.Hostname, s = nextSyslogField(s)
+	log.AppName, s = nextSyslogField(s)
+	log.ProcID, s = nextSyslogField(s)
+	log.MsgID, s = nextSyslogField(s)
+
+	switch {
+	case strings.HasPrefix(s, "-"):
+		s = s[1:]
+	case strings.HasPrefix(s, "["):
+		sd, rest, err := parseSyslogStructuredData(s)
+		if err != nil {
+			return err
+		}
+		log.StructuredData = sd
+		s = rest
+	default:
+		return fmt.Errorf("STRUCTURED-DATA is required")
+	}
+
+	s = strings.TrimPrefix(s, " ")
+	// Remove UTF-8 BOM of MSG
+	log.Message = strings.TrimPrefix(s, "\xEF\xBB\xBF")
+	return nil
+}
+
+func parseSyslogStructuredData(s string) (map[string]map[string]string, string, error) {
+	sd := map[string]map[string]string{}
+
+	for strings.HasPrefix(s, "[") {
+		s = s[1:]
+		end := strings.IndexAny(s, " ]")
+		if end < 0 {
+			return nil, "", fmt.Errorf("Unterminated SD-ELEMENT")
+		}
+		params := map[string]string{}
+		sd[s[:end]] = params
+		s = s[end:]
+
+		for {
+			s = strings.TrimLeft(s, " ")
+			if s == "" {
+				return nil, "", fmt.Errorf("Unterminated SD-ELEMENT")
+			}
+			if s[0] == ']' {
+				s = s[1:]
+				break
+			}
+
+			eq := strings.Index(s, "=\"")
+			if eq < 0 {
+				return nil, "", fmt.Errorf("Invalid SD-PARAM: %s", s)
+			}
+			name := s[:eq]
+			s = s[eq+2:]
+
+			// PARAM-VALUE escapes '"', '\' and ']' with '\'
+			var value strings.Builder
+			closed := false
+			for i := 0; i < len(s); i++ {
+				switch {
+				case s[i] == '\\' && i+1 < len(s) && strings.IndexByte(`"\]`, s[i+1]) >= 0:
+					value.WriteByte(s[i+1])
+					i++
+				case s[i] == '"':
+					s = s[i+1:]
+					closed = true
+				default:
+					value.WriteByte(s[i])
+				}
+				if closed {
+					break
+				}
+			}
+			if !closed {
+				return nil, "", fmt.Errorf("Unterminated PARAM-VALUE of %s", name)
+			}
+			params[name] = value.String()
+		}
+	}
+
+	return sd, s, nil
+}
+
+// RFC 3164 timestamp, e.g. "Oct 11 22:14:15" and "Oct  1 22:14:15"
+const syslog3164TimestampLen = len("Jan _2 15:04:05")
+
+func (x *Syslog) parseSyslog3164(s string, log *SyslogMessage) error {
+	if len(s) < syslog3164TimestampLen {
+		return fmt.Errorf("Too short message")
+	}
+
+	loc := x.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	year := x.Year
+	if year == 0 {
+		year = time.Now().In(loc).Year()
+	}
+
+	t, err := time.ParseInLocation("Jan _2 15:04:05", s[:syslog3164TimestampLen], loc)
+	if err != nil {
+		return errors.Wrapf(err, "Invalid timestamp: %s", s[:syslog3164TimestampLen])
+	}
+	log.Timestamp = time.Date(year, t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc)
+	s = strings.TrimPrefix(s[syslog3164TimestampLen:], " ")
+
+	log.Hostname, s = nextSyslogField(s)
+
+	// TAG is terminated by ':' or '[' (PID), e.g. "sshd[123]: message"
+	if end := strings.IndexAny(s, ":[ "); end > 0 {
+		tag, procID, rest := s[:end], "", s[end:]
+		if rest[0] == '[' {
+			if rb := strings.IndexByte(rest, ']'); rb > 0 {
+				procID, rest = rest[1:rb], rest[rb+1:]
+			}
+		}
+		if strings.HasPrefix(rest, ":") {
+			log.AppName, log.ProcID = tag, procID
+			s = strings.TrimPrefix(rest[1:], " ")
+		}
+	}
+
+	log.Message = s
+	return nil
+}
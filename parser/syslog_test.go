@@ -0,0 +1,112 @@
+package parser_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/m-mizutani/rlogs/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyslogParserRFC5424(t *testing.T) {
+	// Sample from RFC 5424 6.5
+	msg := `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"][examplePriority@32473 class="high \"x\" \] y"] An application event log entry...`
+	src := &rlogs.AwsS3LogSource{Region: "test-r", Bucket: "test-b", Key: "test-k"}
+	psr := parser.Syslog{}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(msg), Src: src, Seq: 1})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "syslog", logs[0].Tag)
+	assert.Equal(t, "2003-10-11T22:14:15.003", logs[0].Timestamp.Format("2006-01-02T15:04:05.000"))
+
+	log := logs[0].Values.(*parser.SyslogMessage)
+	assert.Equal(t, 165, log.Priority)
+	assert.Equal(t, 20, log.Facility)
+	assert.Equal(t, "local4", log.FacilityName)
+	assert.Equal(t, 5, log.Severity)
+	assert.Equal(t, "notice", log.SeverityName)
+	assert.Equal(t, 1, log.Version)
+	assert.Equal(t, "mymachine.example.com", log.Hostname)
+	assert.Equal(t, "evntslog", log.AppName)
+	assert.Equal(t, "", log.ProcID)
+	assert.Equal(t, "ID47", log.MsgID)
+	assert.Equal(t, "1011", log.StructuredData["exampleSDID@32473"]["eventID"])
+	assert.Equal(t, `high "x" ] y`, log.StructuredData["examplePriority@32473"]["class"])
+	assert.Equal(t, "An application event log entry...", log.Message)
+}
+
+func TestSyslogParserRFC5424NoStructuredData(t *testing.T) {
+	msg := "<34>1 2003-10-11T22:14:15.003+09:00 mymachine.example.com su 1234 ID47 - \xEF\xBB\xBF'su root' failed for lonvick on /dev/pts/8"
+	psr := parser.Syslog{Tag: "onprem.syslog"}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(msg)})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "onprem.syslog", logs[0].Tag)
+	assert.Equal(t, 13, logs[0].Timestamp.Hour())
+
+	log := logs[0].Values.(*parser.SyslogMessage)
+	assert.Equal(t, "auth", log.FacilityName)
+	assert.Equal(t, "crit", log.SeverityName)
+	assert.Equal(t, "1234", log.ProcID)
+	assert.Nil(t, log.StructuredData)
+	assert.Equal(t, "'su root' failed for lonvick on /dev/pts/8", log.Message)
+}
+
+func TestSyslogParserRFC3164(t *testing.T) {
+	jst := time.FixedZone("JST", 9*60*60)
+	psr := parser.Syslog{Year: 2019, Location: jst}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(`<38>Oct  1 22:14:15 mymachine sshd[4123]: Accepted publickey for alice`)})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "2019-10-01T13:14:15Z", logs[0].Timestamp.Format(time.RFC3339))
+
+	log := logs[0].Values.(*parser.SyslogMessage)
+	assert.Equal(t, "auth", log.FacilityName)
+	assert.Equal(t, "info", log.SeverityName)
+	assert.Equal(t, 0, log.Version)
+	assert.Equal(t, "mymachine", log.Hostname)
+	assert.Equal(t, "sshd", log.AppName)
+	assert.Equal(t, "4123", log.ProcID)
+	assert.Equal(t, "Accepted publickey for alice", log.Message)
+}
+
+func TestSyslogParserRFC3164WithoutPRI(t *testing.T) {
+	psr := parser.Syslog{Year: 2020}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(`Feb 29 01:02:03 host kernel: device eth0 entered promiscuous mode`)})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "2020-02-29T01:02:03Z", logs[0].Timestamp.Format(time.RFC3339))
+
+	log := logs[0].Values.(*parser.SyslogMessage)
+	assert.Equal(t, -1, log.Priority)
+	assert.Equal(t, "", log.FacilityName)
+	assert.Equal(t, "kernel", log.AppName)
+	assert.Equal(t, "", log.ProcID)
+	assert.Equal(t, "device eth0 entered promiscuous mode", log.Message)
+}
+
+func TestSyslogParserErrorCase(t *testing.T) {
+	psr := parser.Syslog{}
+
+	_, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(`<999>Oct  1 22:14:15 host app: msg`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid syslog PRI")
+
+	_, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte(`<34>1 2003-10-11 host app - - - msg`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "RFC 5424")
+
+	_, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte(`<34>1 2003-10-11T22:14:15Z host app - - [id k="v msg`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Unterminated")
+
+	_, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte(`<34>Xyz 11 22:14:15 host app: msg`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "RFC 3164")
+}
@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TimestampOptions is set of options to extract timestamp from a field of log
+// message. One of UnixtimeField, UnixtimeMilliField, UnixtimeStringField and
+// TimestampField (with TimestampFormat) is required and used in the order.
+type TimestampOptions struct {
+	UnixtimeField       *string
+	UnixtimeStringField *string
+	UnixtimeMilliField  *string
+	TimestampField      *string
+	TimestampFormat     *string
+}
+
+// numberValue returns number of v. A number string is also accepted if
+// numberString is true because values of some formats (e.g. logfmt) are string.
+func numberValue(v interface{}, numberString bool) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	case string:
+		if !numberString {
+			return 0, false
+		}
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func (x *TimestampOptions) extract(value map[string]interface{}, numberString bool) (time.Time, error) {
+	var t time.Time
+	switch {
+	case x.UnixtimeField != nil:
+		if ts, ok := numberValue(value[*x.UnixtimeField], numberString); ok {
+			t = time.Unix(int64(ts), 0).UTC()
+		} else {
+			return t, fmt.Errorf("No unixtime field (%s): %v", *x.UnixtimeField, value)
+		}
+
+	case x.UnixtimeMilliField != nil:
+		if ts, ok := numberValue(value[*x.UnixtimeMilliField], numberString); ok {
+			t = time.Unix(int64(ts)/1000, (int64(ts)%1000)*int64(time.Millisecond)).UTC()
+		} else {
+			return t, fmt.Errorf("No unixtime milliseconds field (%s): %v", *x.UnixtimeMilliField, value)
+		}
+
+	case x.UnixtimeStringField != nil:
+		if str, ok := value[*x.UnixtimeStringField].(string); ok {
+			ts, err := strconv.ParseInt(str, 10, 64)
+			if err != nil {
+				return t, errors.Wrapf(err, "Fail to parse UnixTimeString: %v", str)
+			}
+			t = time.Unix(int64(ts), 0).UTC()
+		} else {
+			return t, fmt.Errorf("No unixtime milliseconds field (%s): %v", *x.UnixtimeStringField, value)
+		}
+
+	case x.TimestampField != nil:
+		if x.TimestampFormat == nil {
+			return t, fmt.Errorf("TimestampFormat is required, but not set")
+		}
+
+		if ts, ok := value[*x.TimestampField].(string); ok {
+			if p, err := time.Parse(*x.TimestampFormat, ts); err == nil {
+				t = p.UTC()
+			} else {
+				return t, errors.Wrapf(err, "Fail to parse timestamp field by format '%s': %v", *x.TimestampFormat, value)
+			}
+		}
+
+	default:
+		return t, fmt.Errorf("No timestamp field arguments. One of UnixtimeField, UnixtimeMilliField and TimestampField is required")
+	}
+
+	return t, nil
+}
@@ -0,0 +1,51 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// validateValueTypes checks types for convertValue by field name.
+func validateValueTypes(types map[string]string) error {
+	for field, typ := range types {
+		switch typ {
+		case "string", "int", "float", "bool":
+		default:
+			return fmt.Errorf("Unsupported type '%s' of field '%s'", typ, field)
+		}
+	}
+	return nil
+}
+
+// convertValue converts a string value of text based log to typ. Available types
+// are "string" (default), "int", "float" and "bool".
+func convertValue(s, typ string) (interface{}, error) {
+	switch typ {
+	case "int":
+		return strconv.ParseInt(s, 10, 64)
+	case "float":
+		return strconv.ParseFloat(s, 64)
+	case "bool":
+		return strconv.ParseBool(s)
+	default:
+		return s, nil
+	}
+}
+
+// parseTimestampValue parses string value of field by format as timestamp.
+func parseTimestampValue(values map[string]interface{}, field, format string) (time.Time, error) {
+	ts, ok := values[field].(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("No timestamp field (%s): %v", field, values)
+	}
+
+	t, err := time.Parse(format, ts)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "Fail to parse timestamp field by format '%s': %s", format, ts)
+	}
+
+	return t.UTC(), nil
+}
@@ -0,0 +1,328 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/pkg/errors"
+)
+
+// WindowsEventRecord is a Windows event. Level, Task and Opcode are numbers in
+// XML rendered event, but names in Winlogbeat JSON. EventData has name/value
+// pairs of EventData section and unnamed data are named "param1", "param2"...
+type WindowsEventRecord struct {
+	EventID       int
+	Provider      string
+	ProviderGUID  string
+	Channel       string
+	Computer      string
+	TimeCreated   time.Time
+	EventRecordID uint64
+	Version       int
+	Level         string
+	Task          string
+	Opcode        string
+	Keywords      string
+	ProcessID     int
+	ThreadID      int
+	UserID        string
+	Message       string
+	EventData     map[string]string
+}
+
+// windowsEventXML is rendered XML of Windows event.
+type windowsEventXML struct {
+	System struct {
+		Provider struct {
+			Name string `xml:"Name,attr"`
+			GUID string `xml:"Guid,attr"`
+		} `xml:"Provider"`
+		EventID     int    `xml:"EventID"`
+		Version     int    `xml:"Version"`
+		Level       string `xml:"Level"`
+		Task        string `xml:"Task"`
+		Opcode      string `xml:"Opcode"`
+		Keywords    string `xml:"Keywords"`
+		TimeCreated struct {
+			SystemTime string `xml:"SystemTime,attr"`
+		} `xml:"TimeCreated"`
+		EventRecordID uint64 `xml:"EventRecordID"`
+		Execution     struct {
+			ProcessID int `xml:"ProcessID,attr"`
+			ThreadID  int `xml:"ThreadID,attr"`
+		} `xml:"Execution"`
+		Channel  string `xml:"Channel"`
+		Computer string `xml:"Computer"`
+		Security struct {
+			UserID string `xml:"UserID,attr"`
+		} `xml:"Security"`
+	} `xml:"System"`
+	EventData struct {
+		Data []struct {
+			Name  string `xml:"Name,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"Data"`
+	} `xml:"EventData"`
+	RenderingInfo struct {
+		Message string `xml:"Message"`
+	} `xml:"RenderingInfo"`
+}
+
+// winlogbeatEvent is Windows event in JSON format of Winlogbeat.
+type winlogbeatEvent struct {
+	Timestamp string `json:"@timestamp"`
+	Message   string `json:"message"`
+	Winlog    *struct {
+		EventID      json.Number            `json:"event_id"`
+		ProviderName string                 `json:"provider_name"`
+		ProviderGUID string                 `json:"provider_guid"`
+		Channel      string                 `json:"channel"`
+		ComputerName string                 `json:"computer_name"`
+		RecordID     json.Number            `json:"record_id"`
+		Version      int                    `json:"version"`
+		Task         string                 `json:"task"`
+		Opcode       string                 `json:"opcode"`
+		Keywords     []string               `json:"keywords"`
+		EventData    map[string]interface{} `json:"event_data"`
+		Process      struct {
+			PID    int `json:"pid"`
+			Thread struct {
+				ID int `json:"id"`
+			} `json:"thread"`
+		} `json:"process"`
+		User struct {
+			Identifier string `json:"identifier"`
+		} `json:"user"`
+	} `json:"winlog"`
+	Log struct {
+		Level string `json:"level"`
+	} `json:"log"`
+}
+
+// nxlogSystemFields are fields of NXLog im_msvistalog output that are not
+// EventData.
+var nxlogSystemFields = map[string]bool{
+	"EventTime": true, "EventReceivedTime": true, "Hostname": true, "Keywords": true,
+	"EventType": true, "SeverityValue": true, "Severity": true, "EventID": true,
+	"SourceName": true, "ProviderGuid": true, "Version": true, "Task": true,
+	"OpcodeValue": true, "Opcode": true, "RecordNumber": true, "ProcessID": true,
+	"ThreadID": true, "Channel": true, "Category": true, "Domain": true,
+	"AccountName": true, "AccountType": true, "UserID": true, "Message": true,
+	"SourceModuleName": true, "SourceModuleType": true,
+}
+
+// WindowsEvent is parser of Windows events exported by agents such as
+// Winlogbeat, NXLog and Windows Event Forwarding. A line should have one event
+// in rendered XML, Winlogbeat JSON or NXLog JSON, then S3LineLoader is required.
+// UserData section of XML is not supported.
+type WindowsEvent struct {
+	// Tag of LogRecord. "windows.event" is used if empty.
+	Tag string
+	// Location is used for NXLog EventTime without timezone. UTC is used if nil.
+	Location *time.Location
+}
+
+// Parse of WindowsEvent parses one Windows event and uses TimeCreated as timestamp.
+func (x *WindowsEvent) Parse(msg *rlogs.MessageQueue) ([]*rlogs.LogRecord, error) {
+	raw := bytes.TrimSpace(msg.Raw)
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	var event *WindowsEventRecord
+	var err error
+	switch raw[0] {
+	case '<':
+		event, err = parseWindowsEventXML(raw)
+	case '{':
+		event, err = x.parseJSON(raw)
+	default:
+		return nil, fmt.Errorf("Invalid Windows event, neither XML nor JSON: %s", string(raw))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tag := x.Tag
+	if tag == "" {
+		tag = "windows.event"
+	}
+
+	return []*rlogs.LogRecord{
+		{
+			Tag:       tag,
+			Timestamp: event.TimeCreated,
+			Raw:       msg.Raw,
+			Values:    event,
+			Seq:       msg.Seq,
+			Src:       msg.Src,
+		},
+	}, nil
+}
+
+func parseWindowsEventXML(raw []byte) (*WindowsEventRecord, error) {
+	var ev windowsEventXML
+	if err := xml.Unmarshal(raw, &ev); err != nil {
+		return nil, errors.Wrapf(err, "Fail to parse Windows event XML: %s", string(raw))
+	}
+
+	// 2019-10-19T04:44:44.123456700Z
+	ts, err := time.Parse(time.RFC3339Nano, ev.System.TimeCreated.SystemTime)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Fail to parse TimeCreated of Windows event: %v", ev.System.TimeCreated.SystemTime)
+	}
+
+	event := &WindowsEventRecord{
+		EventID:       ev.System.EventID,
+		Provider:      ev.System.Provider.Name,
+		ProviderGUID:  ev.System.Provider.GUID,
+		Channel:       ev.System.Channel,
+		Computer:      ev.System.Computer,
+		TimeCreated:   ts.UTC(),
+		EventRecordID: ev.System.EventRecordID,
+		Version:       ev.System.Version,
+		Level:         ev.System.Level,
+		Task:          ev.System.Task,
+		Opcode:        ev.System.Opcode,
+		Keywords:      ev.System.Keywords,
+		ProcessID:     ev.System.Execution.ProcessID,
+		ThreadID:      ev.System.Execution.ThreadID,
+		UserID:        ev.System.Security.UserID,
+		Message:       strings.TrimSpace(ev.RenderingInfo.Message),
+		EventData:     map[string]string{},
+	}
+
+	for i, d := range ev.EventData.Data {
+		name := d.Name
+		if name == "" {
+			name = "param" + strconv.Itoa(i+1)
+		}
+		event.EventData[name] = d.Value
+	}
+
+	return event, nil
+}
+
+func (x *WindowsEvent) parseJSON(raw []byte) (*WindowsEventRecord, error) {
+	var beat winlogbeatEvent
+	if err := json.Unmarshal(raw, &beat); err != nil {
+		return nil, errors.Wrapf(err, "Fail to parse Windows event JSON: %s", string(raw))
+	}
+	if beat.Winlog == nil {
+		return x.parseNXLog(raw)
+	}
+
+	w := beat.Winlog
+	eventID, err := strconv.Atoi(w.EventID.String())
+	if err != nil {
+		return nil, errors.Wrapf(err, "Invalid event_id of Winlogbeat event: %v", w.EventID)
+	}
+	var recordID uint64
+	if w.RecordID != "" {
+		if recordID, err = strconv.ParseUint(w.RecordID.String(), 10, 64); err != nil {
+			return nil, errors.Wrapf(err, "Invalid record_id of Winlogbeat event: %v", w.RecordID)
+		}
+	}
+
+	ts, err := time.Parse(time.RFC3339Nano, beat.Timestamp)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Fail to parse @timestamp of Winlogbeat event: %v", beat.Timestamp)
+	}
+
+	event := &WindowsEventRecord{
+		EventID:       eventID,
+		Provider:      w.ProviderName,
+		ProviderGUID:  w.ProviderGUID,
+		Channel:       w.Channel,
+		Computer:      w.ComputerName,
+		TimeCreated:   ts.UTC(),
+		EventRecordID: recordID,
+		Version:       w.Version,
+		Level:         beat.Log.Level,
+		Task:          w.Task,
+		Opcode:        w.Opcode,
+		Keywords:      strings.Join(w.Keywords, ","),
+		ProcessID:     w.Process.PID,
+		ThreadID:      w.Process.Thread.ID,
+		UserID:        w.User.Identifier,
+		Message:       beat.Message,
+		EventData:     map[string]string{},
+	}
+	for k, v := range w.EventData {
+		event.EventData[k] = fmt.Sprint(v)
+	}
+
+	return event, nil
+}
+
+func (x *WindowsEvent) parseNXLog(raw []byte) (*WindowsEventRecord, error) {
+	var values map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&values); err != nil {
+		return nil, errors.Wrapf(err, "Fail to parse NXLog Windows event: %s", string(raw))
+	}
+
+	str := func(key string) string {
+		if v, ok := values[key]; ok && v != nil {
+			return fmt.Sprint(v)
+		}
+		return ""
+	}
+	num := func(key string) int {
+		n, _ := strconv.Atoi(str(key))
+		return n
+	}
+
+	if _, ok := values["EventID"]; !ok {
+		return nil, fmt.Errorf("No EventID in NXLog Windows event: %s", string(raw))
+	}
+
+	loc := x.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	eventTime := str("EventTime")
+	ts, err := time.Parse(time.RFC3339Nano, eventTime)
+	if err != nil {
+		// 2019-10-19 13:44:44
+		ts, err = time.ParseInLocation("2006-01-02 15:04:05", eventTime, loc)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Fail to parse EventTime of NXLog Windows event: %v", eventTime)
+		}
+	}
+
+	recordID, _ := strconv.ParseUint(str("RecordNumber"), 10, 64)
+	event := &WindowsEventRecord{
+		EventID:       num("EventID"),
+		Provider:      str("SourceName"),
+		ProviderGUID:  str("ProviderGuid"),
+		Channel:       str("Channel"),
+		Computer:      str("Hostname"),
+		TimeCreated:   ts.UTC(),
+		EventRecordID: recordID,
+		Version:       num("Version"),
+		Level:         str("Severity"),
+		Task:          str("Task"),
+		Opcode:        str("Opcode"),
+		Keywords:      str("Keywords"),
+		ProcessID:     num("ProcessID"),
+		ThreadID:      num("ThreadID"),
+		UserID:        str("UserID"),
+		Message:       str("Message"),
+		EventData:     map[string]string{},
+	}
+	for k := range values {
+		if !nxlogSystemFields[k] {
+			event.EventData[k] = str(k)
+		}
+	}
+
+	return event, nil
+}
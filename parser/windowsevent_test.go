@@ -0,0 +1,108 @@
+package parser_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/m-mizutani/rlogs/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWindowsEventParserXML(t *testing.T) {
+	msg := `<Event xmlns="http://schemas.microsoft.com/win/2004/08/events/event"><System><Provider Name="Microsoft-Windows-Security-Auditing" Guid="{54849625-5478-4994-A5BA-3E3B0328C30D}"/><EventID>4625</EventID><Version>0</Version><Level>0</Level><Task>12544</Task><Opcode>0</Opcode><Keywords>0x8010000000000000</Keywords><TimeCreated SystemTime="2019-10-19T04:44:44.123456700Z"/><EventRecordID>123456</EventRecordID><Correlation/><Execution ProcessID="612" ThreadID="1234"/><Channel>Security</Channel><Computer>dc01.example.com</Computer><Security/></System><EventData><Data Name="TargetUserName">alice</Data><Data Name="IpAddress">203.0.113.1</Data><Data Name="LogonType">3</Data><Data Name="Empty"></Data></EventData><RenderingInfo Culture="en-US"><Message>An account failed to log on.</Message></RenderingInfo></Event>`
+	src := &rlogs.AwsS3LogSource{Region: "test-r", Bucket: "test-b", Key: "test-k"}
+	psr := parser.WindowsEvent{}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(msg), Src: src, Seq: 1})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "windows.event", logs[0].Tag)
+	assert.Equal(t, "2019-10-19T04:44:44.1234567Z", logs[0].Timestamp.Format(time.RFC3339Nano))
+
+	event := logs[0].Values.(*parser.WindowsEventRecord)
+	assert.Equal(t, 4625, event.EventID)
+	assert.Equal(t, "Microsoft-Windows-Security-Auditing", event.Provider)
+	assert.Equal(t, "Security", event.Channel)
+	assert.Equal(t, "dc01.example.com", event.Computer)
+	assert.Equal(t, uint64(123456), event.EventRecordID)
+	assert.Equal(t, "12544", event.Task)
+	assert.Equal(t, 612, event.ProcessID)
+	assert.Equal(t, "An account failed to log on.", event.Message)
+	assert.Equal(t, "alice", event.EventData["TargetUserName"])
+	assert.Equal(t, "203.0.113.1", event.EventData["IpAddress"])
+	assert.Equal(t, "", event.EventData["Empty"])
+}
+
+func TestWindowsEventParserUnnamedData(t *testing.T) {
+	msg := `<Event xmlns="http://schemas.microsoft.com/win/2004/08/events/event"><System><Provider Name="Service Control Manager"/><EventID Qualifiers="16384">7036</EventID><TimeCreated SystemTime="2019-10-19T04:44:44Z"/><EventRecordID>1</EventRecordID><Channel>System</Channel><Computer>host1</Computer></System><EventData><Data>Windows Update</Data><Data>running</Data></EventData></Event>`
+	psr := parser.WindowsEvent{Tag: "windows.system"}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(msg)})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "windows.system", logs[0].Tag)
+
+	event := logs[0].Values.(*parser.WindowsEventRecord)
+	assert.Equal(t, 7036, event.EventID)
+	assert.Equal(t, "Windows Update", event.EventData["param1"])
+	assert.Equal(t, "running", event.EventData["param2"])
+}
+
+func TestWindowsEventParserWinlogbeat(t *testing.T) {
+	msg := `{"@timestamp":"2019-10-19T04:44:44.123Z","message":"An account was successfully logged on.","winlog":{"event_id":4624,"provider_name":"Microsoft-Windows-Security-Auditing","provider_guid":"{54849625-5478-4994-a5ba-3e3b0328c30d}","channel":"Security","computer_name":"ws01.example.com","record_id":"98765","task":"Logon","opcode":"Info","keywords":["Audit Success"],"process":{"pid":612,"thread":{"id":1234}},"event_data":{"TargetUserName":"bob","LogonType":"10"}},"log":{"level":"information"}}`
+	psr := parser.WindowsEvent{}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(msg)})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "2019-10-19T04:44:44.123Z", logs[0].Timestamp.Format(time.RFC3339Nano))
+
+	event := logs[0].Values.(*parser.WindowsEventRecord)
+	assert.Equal(t, 4624, event.EventID)
+	assert.Equal(t, "ws01.example.com", event.Computer)
+	assert.Equal(t, uint64(98765), event.EventRecordID)
+	assert.Equal(t, "Logon", event.Task)
+	assert.Equal(t, "information", event.Level)
+	assert.Equal(t, "Audit Success", event.Keywords)
+	assert.Equal(t, 1234, event.ThreadID)
+	assert.Equal(t, "bob", event.EventData["TargetUserName"])
+}
+
+func TestWindowsEventParserNXLog(t *testing.T) {
+	msg := `{"EventTime":"2019-10-19 13:44:44","Hostname":"ws02.example.com","Keywords":-9214364837600034816,"EventType":"AUDIT_SUCCESS","SeverityValue":2,"Severity":"INFO","EventID":4688,"SourceName":"Microsoft-Windows-Security-Auditing","Task":13312,"RecordNumber":555,"ProcessID":4,"ThreadID":8,"Channel":"Security","Message":"A new process has been created.","NewProcessName":"C:\\Windows\\System32\\cmd.exe","ProcessId":"0x1a4","EventReceivedTime":"2019-10-19 13:44:45","SourceModuleName":"eventlog","SourceModuleType":"im_msvistalog"}`
+	loc, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+	psr := parser.WindowsEvent{Location: loc}
+
+	logs, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(msg)})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "2019-10-19T04:44:44Z", logs[0].Timestamp.Format(time.RFC3339Nano))
+
+	event := logs[0].Values.(*parser.WindowsEventRecord)
+	assert.Equal(t, 4688, event.EventID)
+	assert.Equal(t, "ws02.example.com", event.Computer)
+	assert.Equal(t, uint64(555), event.EventRecordID)
+	assert.Equal(t, "-9214364837600034816", event.Keywords)
+	assert.Equal(t, 2, len(event.EventData))
+	assert.Equal(t, `C:\Windows\System32\cmd.exe`, event.EventData["NewProcessName"])
+	assert.Equal(t, "0x1a4", event.EventData["ProcessId"])
+}
+
+func TestWindowsEventParserErrorCase(t *testing.T) {
+	psr := parser.WindowsEvent{}
+
+	_, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(`EventID=4624`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "neither XML nor JSON")
+
+	_, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte(`<Event><System><EventID>1</EventID></System></Event>`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Fail to parse TimeCreated")
+
+	_, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte(`{"Hostname":"ws02"}`)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "No EventID")
+}
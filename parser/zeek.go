@@ -0,0 +1,272 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/pkg/errors"
+)
+
+// ZeekRecord is a record of Zeek (Bro) log. A value is converted by Zeek type:
+// time to time.Time, interval and double to float64, count to uint64, int and
+// port to int64, bool to bool, set[T] and vector[T] to []interface{} and others
+// to string. Unset field ("-") is nil.
+type ZeekRecord map[string]interface{}
+
+// Zeek is parser of Zeek (Bro) logs in both of TSV format with header directives
+// and JSON format. Tag of LogRecord is "zeek." + #path (or _path field of JSON),
+// e.g. "zeek.conn", if Tag is empty. Timestamp comes from "ts" field. One line
+// should have one record, then S3LineLoader is required. Seq of the first line
+// in an object must be 0 to reset header directives.
+type Zeek struct {
+	Tag string
+
+	header zeekHeader
+}
+
+type zeekHeader struct {
+	separator    string
+	setSeparator string
+	emptyField   string
+	unsetField   string
+	path         string
+	fields       []string
+	types        []string
+}
+
+func newZeekHeader() zeekHeader {
+	return zeekHeader{
+		separator:    "\t",
+		setSeparator: ",",
+		emptyField:   "(empty)",
+		unsetField:   "-",
+	}
+}
+
+var zeekEscapedChar = regexp.MustCompile(`\\x[0-9a-fA-F]{2}`)
+
+func unescapeZeek(s string) string {
+	return zeekEscapedChar.ReplaceAllStringFunc(s, func(h string) string {
+		c, _ := strconv.ParseUint(h[2:], 16, 8)
+		return string([]byte{byte(c)})
+	})
+}
+
+// Parse of Zeek parses one line of Zeek log. Header directives return no LogRecord.
+func (x *Zeek) Parse(msg *rlogs.MessageQueue) ([]*rlogs.LogRecord, error) {
+	if msg.Seq == 0 || x.header.separator == "" {
+		x.header = newZeekHeader()
+	}
+
+	line := strings.TrimRight(string(msg.Raw), "\r\n")
+	var record ZeekRecord
+	var path string
+
+	switch {
+	case line == "":
+		return nil, nil
+
+	case strings.HasPrefix(line, "#"):
+		if err := x.header.setDirective(line); err != nil {
+			return nil, err
+		}
+		return nil, nil
+
+	case strings.HasPrefix(line, "{"):
+		r, err := parseZeekJSON(msg.Raw)
+		if err != nil {
+			return nil, err
+		}
+		record = r
+		path, _ = record["_path"].(string)
+
+	default:
+		r, err := x.header.parseRow(line)
+		if err != nil {
+			return nil, err
+		}
+		record = r
+		path = x.header.path
+	}
+
+	ts, _ := record["ts"].(time.Time)
+
+	tag := x.Tag
+	if tag == "" {
+		tag = "zeek"
+		if path != "" {
+			tag += "." + path
+		}
+	}
+
+	return []*rlogs.LogRecord{
+		{
+			Tag:       tag,
+			Timestamp: ts.UTC(),
+			Raw:       msg.Raw,
+			Values:    record,
+			Seq:       msg.Seq,
+			Src:       msg.Src,
+		},
+	}, nil
+}
+
+func (x *zeekHeader) setDirective(line string) error {
+	if strings.HasPrefix(line, "#separator ") {
+		x.separator = unescapeZeek(strings.TrimPrefix(line, "#separator "))
+		return nil
+	}
+
+	items := strings.Split(line, x.separator)
+	switch items[0] {
+	case "#set_separator":
+		if len(items) > 1 {
+			x.setSeparator = unescapeZeek(items[1])
+		}
+	case "#empty_field":
+		if len(items) > 1 {
+			x.emptyField = items[1]
+		}
+	case "#unset_field":
+		if len(items) > 1 {
+			x.unsetField = items[1]
+		}
+	case "#path":
+		if len(items) > 1 {
+			x.path = items[1]
+		}
+	case "#fields":
+		x.fields = items[1:]
+	case "#types":
+		x.types = items[1:]
+	}
+	// Other directives such as #open and #close are ignored
+
+	return nil
+}
+
+func (x *zeekHeader) parseRow(line string) (ZeekRecord, error) {
+	if len(x.fields) == 0 {
+		return nil, fmt.Errorf("No #fields directive before Zeek log record")
+	}
+	if len(x.types) != 0 && len(x.types) != len(x.fields) {
+		return nil, fmt.Errorf("Mismatch length of #fields (%d) and #types (%d)", len(x.fields), len(x.types))
+	}
+
+	row := strings.Split(line, x.separator)
+	if len(row) != len(x.fields) {
+		return nil, fmt.Errorf("Invalid row length (expected %d, but %d)", len(x.fields), len(row))
+	}
+
+	record := ZeekRecord{}
+	for i, field := range x.fields {
+		typ := "string"
+		if len(x.types) > 0 {
+			typ = x.types[i]
+		}
+
+		v, err := x.convert(row[i], typ)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Fail to convert Zeek field '%s' as %s", field, typ)
+		}
+		record[field] = v
+	}
+
+	return record, nil
+}
+
+func (x *zeekHeader) convert(s, typ string) (interface{}, error) {
+	if s == x.unsetField {
+		return nil, nil
+	}
+
+	if elemType, ok := zeekContainerElemType(typ); ok {
+		items := []interface{}{}
+		if s == x.emptyField {
+			return items, nil
+		}
+		for _, item := range strings.Split(s, x.setSeparator) {
+			v, err := x.convert(item, elemType)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		return items, nil
+	}
+
+	if s == x.emptyField {
+		s = ""
+	}
+	return convertZeekValue(unescapeZeek(s), typ)
+}
+
+// zeekContainerElemType returns T of set[T] and vector[T].
+func zeekContainerElemType(typ string) (string, bool) {
+	for _, prefix := range []string{"set[", "vector["} {
+		if strings.HasPrefix(typ, prefix) && strings.HasSuffix(typ, "]") {
+			return typ[len(prefix) : len(typ)-1], true
+		}
+	}
+	return "", false
+}
+
+func convertZeekValue(s, typ string) (interface{}, error) {
+	switch typ {
+	case "time":
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, err
+		}
+		return zeekTime(f), nil
+	case "interval", "double":
+		return strconv.ParseFloat(s, 64)
+	case "count":
+		return strconv.ParseUint(s, 10, 64)
+	case "int", "port":
+		return strconv.ParseInt(s, 10, 64)
+	case "bool":
+		switch s {
+		case "T":
+			return true, nil
+		case "F":
+			return false, nil
+		}
+		return nil, fmt.Errorf("Invalid bool value: %s", s)
+	default: // string, addr, subnet, enum, pattern and so on
+		return s, nil
+	}
+}
+
+func zeekTime(f float64) time.Time {
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(math.Round(frac*1e6))*int64(time.Microsecond)).UTC()
+}
+
+// parseZeekJSON parses Zeek JSON log. "ts" may be epoch seconds (default) or
+// ISO 8601 string (JSON::TS_ISO8601).
+func parseZeekJSON(raw []byte) (ZeekRecord, error) {
+	var record ZeekRecord
+	if err := json.Unmarshal(raw, &record); err != nil {
+		return nil, errors.Wrapf(err, "Fail to parse Zeek JSON log: %s", string(raw))
+	}
+
+	switch ts := record["ts"].(type) {
+	case float64:
+		record["ts"] = zeekTime(ts)
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, ts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Fail to parse ts of Zeek JSON log: %s", ts)
+		}
+		record["ts"] = t.UTC()
+	}
+
+	return record, nil
+}
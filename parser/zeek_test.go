@@ -0,0 +1,122 @@
+package parser_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/m-mizutani/rlogs/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseZeekLines(t *testing.T, psr *parser.Zeek, lines []string) []*rlogs.LogRecord {
+	var logs []*rlogs.LogRecord
+	for i, line := range lines {
+		r, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte(line), Seq: i})
+		require.NoError(t, err)
+		logs = append(logs, r...)
+	}
+	return logs
+}
+
+func TestZeekParserTSV(t *testing.T) {
+	lines := []string{
+		`#separator \x09`,
+		"#set_separator\t,",
+		"#empty_field\t(empty)",
+		"#unset_field\t-",
+		"#path\tconn",
+		"#open\t2019-10-19-04-00-00",
+		strings.Join([]string{"#fields", "ts", "uid", "id.orig_h", "id.orig_p", "id.resp_h", "id.resp_p", "proto", "duration", "orig_bytes", "local_orig", "tunnel_parents", "history"}, "\t"),
+		strings.Join([]string{"#types", "time", "string", "addr", "port", "addr", "port", "enum", "interval", "count", "bool", "set[string]", "string"}, "\t"),
+		strings.Join([]string{"1571460284.123456", "CHhAvVGS1DHFjwGM9", "10.0.0.1", "52345", "93.184.216.34", "443", "tcp", "1.5", "2048", "T", "(empty)", `ShAD\x2cd`}, "\t"),
+		strings.Join([]string{"1571460285.000000", "C4J4Th3PJpwUYZZ6gc", "10.0.0.2", "53", "8.8.8.8", "53", "udp", "-", "-", "F", "Cx1,Cx2", "-"}, "\t"),
+		"#close\t2019-10-19-05-00-00",
+	}
+
+	logs := parseZeekLines(t, &parser.Zeek{}, lines)
+	require.Equal(t, 2, len(logs))
+	assert.Equal(t, "zeek.conn", logs[0].Tag)
+	assert.Equal(t, 8, logs[0].Seq)
+	assert.Equal(t, "2019-10-19T04:44:44.123456Z", logs[0].Timestamp.Format(time.RFC3339Nano))
+
+	r0 := logs[0].Values.(parser.ZeekRecord)
+	assert.Equal(t, "CHhAvVGS1DHFjwGM9", r0["uid"])
+	assert.Equal(t, "10.0.0.1", r0["id.orig_h"])
+	assert.Equal(t, int64(52345), r0["id.orig_p"])
+	assert.Equal(t, int64(443), r0["id.resp_p"])
+	assert.Equal(t, "tcp", r0["proto"])
+	assert.Equal(t, 1.5, r0["duration"])
+	assert.Equal(t, uint64(2048), r0["orig_bytes"])
+	assert.Equal(t, true, r0["local_orig"])
+	assert.Equal(t, []interface{}{}, r0["tunnel_parents"])
+	assert.Equal(t, "ShAD,d", r0["history"])
+
+	r1 := logs[1].Values.(parser.ZeekRecord)
+	assert.Nil(t, r1["duration"])
+	assert.Nil(t, r1["orig_bytes"])
+	assert.Nil(t, r1["history"])
+	assert.Equal(t, false, r1["local_orig"])
+	assert.Equal(t, []interface{}{"Cx1", "Cx2"}, r1["tunnel_parents"])
+}
+
+func TestZeekParserTSVVector(t *testing.T) {
+	lines := []string{
+		"#separator |",
+		"#path|dns",
+		"#fields|ts|query|answers|TTLs",
+		"#types|time|string|vector[string]|vector[interval]",
+		"1571460284.5|example.com|93.184.216.34|3600.0",
+	}
+
+	logs := parseZeekLines(t, &parser.Zeek{Tag: "nsm"}, lines)
+	require.Equal(t, 1, len(logs))
+	assert.Equal(t, "nsm", logs[0].Tag)
+
+	r := logs[0].Values.(parser.ZeekRecord)
+	assert.Equal(t, []interface{}{"93.184.216.34"}, r["answers"])
+	assert.Equal(t, []interface{}{3600.0}, r["TTLs"])
+}
+
+func TestZeekParserJSON(t *testing.T) {
+	lines := []string{
+		`{"_path":"http","ts":1571460284.123456,"uid":"CHhAvVGS1DHFjwGM9","id.orig_h":"10.0.0.1","method":"GET","host":"example.com","status_code":200}`,
+		`{"ts":"2019-10-19T04:44:45.000000Z","uid":"C4J4Th3PJpwUYZZ6gc","query":"example.com"}`,
+	}
+
+	logs := parseZeekLines(t, &parser.Zeek{}, lines)
+	require.Equal(t, 2, len(logs))
+	assert.Equal(t, "zeek.http", logs[0].Tag)
+	assert.Equal(t, "2019-10-19T04:44:44.123456Z", logs[0].Timestamp.Format(time.RFC3339Nano))
+	assert.Equal(t, "GET", logs[0].Values.(parser.ZeekRecord)["method"])
+
+	assert.Equal(t, "zeek", logs[1].Tag)
+	assert.Equal(t, "2019-10-19T04:44:45Z", logs[1].Timestamp.Format(time.RFC3339))
+}
+
+func TestZeekParserErrorCase(t *testing.T) {
+	psr := parser.Zeek{}
+
+	_, err := psr.Parse(&rlogs.MessageQueue{Raw: []byte("1571460284.5\tCx1"), Seq: 0})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "No #fields directive")
+
+	_, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte("#fields\tts\tok"), Seq: 0})
+	require.NoError(t, err)
+	_, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte("#types\ttime\tbool"), Seq: 1})
+	require.NoError(t, err)
+
+	_, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte("1571460284.5"), Seq: 2})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Invalid row length")
+
+	_, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte("1571460284.5\tyes"), Seq: 3})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Fail to convert Zeek field 'ok' as bool")
+
+	_, err = psr.Parse(&rlogs.MessageQueue{Raw: []byte(`{"ts":"yesterday"}`), Seq: 4})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Fail to parse ts")
+}
@@ -20,3 +20,43 @@ func NewCloudTrail() rlogs.Pipeline {
 		Ldr: &rlogs.S3FileLoader{},
 	}
 }
+
+// NewRoute53ResolverQuery provides set of Parser and Loader for Route 53 Resolver query logs
+func NewRoute53ResolverQuery() rlogs.Pipeline {
+	return rlogs.Pipeline{
+		Psr: &parser.Route53ResolverQuery{},
+		Ldr: &rlogs.S3LineLoader{},
+	}
+}
+
+// NewGuardDuty provides set of Parser and Loader for GuardDuty findings exported to S3
+func NewGuardDuty() rlogs.Pipeline {
+	return rlogs.Pipeline{
+		Psr: &parser.GuardDuty{},
+		Ldr: &rlogs.S3LineLoader{},
+	}
+}
+
+// NewAwsConfig provides set of Parser and Loader for AWS Config history and snapshot files
+func NewAwsConfig() rlogs.Pipeline {
+	return rlogs.Pipeline{
+		Psr: &parser.AwsConfig{},
+		Ldr: &rlogs.S3FileLoader{},
+	}
+}
+
+// NewSecurityHub provides set of Parser and Loader for Security Hub findings exported to S3 via EventBridge
+func NewSecurityHub() rlogs.Pipeline {
+	return rlogs.Pipeline{
+		Psr: &parser.SecurityHub{},
+		Ldr: &rlogs.S3LineLoader{},
+	}
+}
+
+// NewOCSF provides set of Parser and Loader for OCSF formatted JSON events
+func NewOCSF() rlogs.Pipeline {
+	return rlogs.Pipeline{
+		Psr: &parser.OCSF{},
+		Ldr: &rlogs.S3LineLoader{},
+	}
+}
@@ -0,0 +1,15 @@
+package pipeline
+
+import (
+	"github.com/m-mizutani/rlogs"
+	"github.com/m-mizutani/rlogs/parser"
+)
+
+// NewCSV provides set of Parser and Loader for CSV/TSV logs. Quote of the loader
+// is taken from the parser to split and parse records with the same quote.
+func NewCSV(psr *parser.CSV) rlogs.Pipeline {
+	return rlogs.Pipeline{
+		Psr: psr,
+		Ldr: &rlogs.S3CSVLoader{Quote: psr.Quote},
+	}
+}
@@ -0,0 +1,25 @@
+package pipeline
+
+import (
+	"github.com/m-mizutani/rlogs"
+	"github.com/m-mizutani/rlogs/parser"
+)
+
+// NewK8sAudit provides set of Parser and Loader for Kubernetes audit logs in JSON
+// lines including EKS control plane logs exported by CloudWatch Logs export task
+func NewK8sAudit() rlogs.Pipeline {
+	return rlogs.Pipeline{
+		Psr: &parser.K8sAudit{},
+		Ldr: &rlogs.S3LineLoader{},
+	}
+}
+
+// NewK8sAuditCloudWatchLogs provides set of Parser and Loader for EKS control
+// plane logs delivered by CloudWatch Logs subscription and Firehose. Subscription
+// data are concatenated without line break in an object.
+func NewK8sAuditCloudWatchLogs() rlogs.Pipeline {
+	return rlogs.Pipeline{
+		Psr: &parser.K8sAudit{},
+		Ldr: &rlogs.S3MultilineLoader{JSON: true},
+	}
+}
@@ -13,7 +13,9 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-type dummyS3ClientForReader struct{}
+type dummyS3ClientForReader struct {
+	rlogs.TestS3ClientBase
+}
 
 func (x *dummyS3ClientForReader) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
 	if *input.Bucket != "your-bucket" {
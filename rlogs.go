@@ -1,6 +1,7 @@
 package rlogs
 
 import (
+	"context"
 	"time"
 
 	"github.com/pkg/errors"
@@ -27,7 +28,11 @@ type LogRecord struct {
 	Raw []byte
 	// Value is parsed log data
 	Values interface{}
-	// Sequence number in log object
+	// Sequence number in log object. Pipeline numbers records from 0 in order of
+	// the object, then records parsed from one message (e.g. findings in an
+	// EventBridge event or rows of osquery snapshot) have distinct numbers.
+	// Parser sets Seq of the message (or index of the record in the message if
+	// the message is whole object) and it's overwritten by Pipeline.
 	Seq int
 	// Log source location
 	Src LogSource
@@ -51,46 +56,144 @@ type Loader interface {
 	Load(src LogSource) chan *MessageQueue
 }
 
+// ContextLoader is Loader that can be cancelled. The loader stops reading the
+// object, closes it and closes the channel when ctx is done. Loaders of this
+// package implement ContextLoader.
+type ContextLoader interface {
+	Loader
+	LoadContext(ctx context.Context, src LogSource) chan *MessageQueue
+}
+
 // Pipeline is a pair of Parser and Loader.
 type Pipeline struct {
 	Ldr       Loader
 	Psr       Parser
 	QueueSize int
+
+	// MaxObjectSize is max size (bytes) of log object. Size of S3 object is
+	// checked by HeadObject before download. It's stored size of the object,
+	// then size of decompressed data is not limited for gzip compressed object.
+	// No limit if 0.
+	MaxObjectSize int64
+	// MaxRecords is max number of log records in a log object. No limit if 0.
+	MaxRecords int
+	// Timeout is max time to process a log object. No limit if 0.
+	Timeout time.Duration
 }
 
-// Run of Pipeline downloads object and parse it.
+// Run of Pipeline downloads object and parse it. If the object exceeds a limit,
+// ObjectSizeLimitError, RecordLimitError or TimeLimitError is sent as Error of
+// LogQueue and the rest of the object is not read. Timeout covers HeadObject for
+// MaxObjectSize, loading, parsing and sending log records to ch. If Ldr is
+// ContextLoader, the loader is cancelled when Run returns. Otherwise rest of
+// messages from the loader are discarded in background until the loader closes
+// the channel.
 func (x *Pipeline) Run(src LogSource, ch chan *LogQueue) {
 	defer close(ch)
 
-	msgch := x.Ldr.Load(src)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if x.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, x.Timeout)
+		defer cancel()
+	}
+
+	if err := x.run(ctx, src, ch); err == context.DeadlineExceeded {
+		ch <- &LogQueue{Error: &TimeLimitError{Src: src, Limit: x.Timeout}}
+	}
+}
+
+// run is main part of Run. It returns error of ctx if ctx is done before
+// sending all log records.
+func (x *Pipeline) run(ctx context.Context, src LogSource, ch chan *LogQueue) error {
+	send := func(q *LogQueue) error {
+		select {
+		case ch <- q:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if x.MaxObjectSize > 0 {
+		if s3src, ok := src.(*AwsS3LogSource); ok {
+			size, err := getS3ObjectSize(ctx, s3src)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				return send(&LogQueue{Error: err})
+			}
+			if size > x.MaxObjectSize {
+				return send(&LogQueue{Error: &ObjectSizeLimitError{Src: src, Size: size, Limit: x.MaxObjectSize}})
+			}
+		}
+	}
+
+	var msgch chan *MessageQueue
+	if ldr, ok := x.Ldr.(ContextLoader); ok {
+		msgch = ldr.LoadContext(ctx, src)
+	} else {
+		msgch = x.Ldr.Load(src)
+	}
 	if msgch == nil {
-		return // ignore
+		return nil // ignore
 	}
+	defer func() {
+		// Unblock the loader that is sending a message to stop
+		go func() {
+			for range msgch {
+			}
+		}()
+	}()
+
+	records := 0
+	for {
+		var msg *MessageQueue
+		select {
+		case m, ok := <-msgch:
+			if !ok {
+				return ctx.Err()
+			}
+			msg = m
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 
-	for msg := range msgch {
 		if msg.Error != nil {
-			ch <- &LogQueue{
+			if ctx.Err() != nil {
+				return ctx.Err() // The loader is cancelled
+			}
+			return send(&LogQueue{
 				Error: errors.Wrap(msg.Error, "Fail to load log message"),
 				Log: &LogRecord{
 					Raw: msg.Raw,
 				},
-			}
-			return
+			})
 		}
 
 		logs, err := x.Psr.Parse(msg)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		if err != nil {
-			ch <- &LogQueue{
+			return send(&LogQueue{
 				Error: errors.Wrap(err, "Fail to parse log message"),
 				Log: &LogRecord{
 					Raw: msg.Raw,
 				},
-			}
-			return
+			})
 		}
 
 		for i := range logs {
-			ch <- &LogQueue{Log: logs[i]}
+			if x.MaxRecords > 0 && records >= x.MaxRecords {
+				return send(&LogQueue{Error: &RecordLimitError{Src: src, Limit: x.MaxRecords}})
+			}
+			logs[i].Seq = records
+			records++
+			if err := send(&LogQueue{Log: logs[i]}); err != nil {
+				return err
+			}
 		}
 	}
 }
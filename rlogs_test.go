@@ -0,0 +1,84 @@
+package rlogs_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/m-mizutani/rlogs"
+	"github.com/m-mizutani/rlogs/parser"
+	"github.com/m-mizutani/rlogs/pipeline"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipelineSeqSecurityHub(t *testing.T) {
+	finding := `{"SchemaVersion":"2018-10-08","Id":"%s","AwsAccountId":"123456789012","UpdatedAt":"2020-03-22T13:22:14Z","Severity":{"Label":"LOW"}}`
+	event := `{"detail-type":"Security Hub Findings - Imported","source":"aws.securityhub","detail":{"findings":[` + finding + `,` + finding + `]}}`
+	data := strings.Join([]string{
+		fmt.Sprintf(event, "finding-0", "finding-1"),
+		fmt.Sprintf(event, "finding-2", "finding-3"),
+	}, "\n")
+
+	rlogs.InjectNewS3Client(&dummyS3ClientData{data: []byte(data)})
+	defer rlogs.FixNewS3Client()
+
+	queues := runPipeline(pipeline.NewSecurityHub())
+	require.Equal(t, 4, len(queues))
+	for i, q := range queues {
+		require.NoError(t, q.Error)
+		assert.Equal(t, fmt.Sprintf("finding-%d", i), q.Log.Values.(*parser.AsffFinding).ID)
+		assert.Equal(t, i, q.Log.Seq)
+	}
+}
+
+func TestPipelineSeqK8sAuditCloudWatchLogs(t *testing.T) {
+	event := `{"kind":"Event","apiVersion":"audit.k8s.io/v1","auditID":"%s","stage":"ResponseComplete","verb":"get","requestReceivedTimestamp":"2019-10-19T04:44:44.123456Z"}`
+
+	// Firehose concatenates subscription data without line break
+	var data []byte
+	for i := 0; i < 2; i++ {
+		raw, err := json.Marshal(map[string]interface{}{
+			"messageType": "DATA_MESSAGE",
+			"logGroup":    "/aws/eks/my-cluster/cluster",
+			"logStream":   "kube-apiserver-audit-0123456789abcdef",
+			"logEvents": []map[string]interface{}{
+				{"id": "1", "timestamp": 1571460284200, "message": fmt.Sprintf(event, fmt.Sprintf("audit-%d", i*2))},
+				{"id": "2", "timestamp": 1571460284300, "message": fmt.Sprintf(event, fmt.Sprintf("audit-%d", i*2+1))},
+			},
+		})
+		require.NoError(t, err)
+		data = append(data, raw...)
+	}
+
+	rlogs.InjectNewS3Client(&dummyS3ClientData{data: data})
+	defer rlogs.FixNewS3Client()
+
+	queues := runPipeline(pipeline.NewK8sAuditCloudWatchLogs())
+	require.Equal(t, 4, len(queues))
+	for i, q := range queues {
+		require.NoError(t, q.Error)
+		assert.Equal(t, fmt.Sprintf("audit-%d", i), q.Log.Values.(*parser.K8sAuditEvent).AuditID)
+		assert.Equal(t, i, q.Log.Seq)
+	}
+}
+
+func TestPipelineSeqOsquery(t *testing.T) {
+	result := `{"name":"users","hostIdentifier":"host1","unixTime":1571460284,"diffResults":{"added":[{"username":"%s"}],"removed":[{"username":"%s"}]}}`
+	data := strings.Join([]string{
+		fmt.Sprintf(result, "user-0", "user-1"),
+		fmt.Sprintf(result, "user-2", "user-3"),
+	}, "\n")
+
+	rlogs.InjectNewS3Client(&dummyS3ClientData{data: []byte(data)})
+	defer rlogs.FixNewS3Client()
+
+	queues := runPipeline(rlogs.Pipeline{Ldr: &rlogs.S3LineLoader{}, Psr: &parser.Osquery{}})
+	require.Equal(t, 4, len(queues))
+	for i, q := range queues {
+		require.NoError(t, q.Error)
+		assert.Equal(t, fmt.Sprintf("user-%d", i), q.Log.Values.(*parser.OsqueryResult).Columns["username"])
+		assert.Equal(t, i, q.Log.Seq)
+	}
+}
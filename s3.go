@@ -1,13 +1,69 @@
 package rlogs
 
 import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 )
 
 type s3Client interface {
 	GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error)
+	HeadObject(input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error)
+}
+
+// s3ContextClient is AWS S3 client that can cancel a request by context.
+type s3ContextClient interface {
+	GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error)
+}
+
+// getObject sends GetObject request that is cancelled with ctx if the client
+// supports it.
+func getObject(ctx context.Context, client s3Client, input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	if c, ok := client.(s3ContextClient); ok {
+		return c.GetObjectWithContext(ctx, input)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return client.GetObject(input)
+}
+
+// s3HeadContextClient is AWS S3 client that can cancel HeadObject by context.
+type s3HeadContextClient interface {
+	HeadObjectWithContext(ctx aws.Context, input *s3.HeadObjectInput, opts ...request.Option) (*s3.HeadObjectOutput, error)
+}
+
+// headObject sends HeadObject request that is cancelled with ctx if the client
+// supports it.
+func headObject(ctx context.Context, client s3Client, input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
+	if c, ok := client.(s3HeadContextClient); ok {
+		return c.HeadObjectWithContext(ctx, input)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return client.HeadObject(input)
 }
 
 // NewS3Client is constructor of AWS S3 client. It can be replaced for testing
@@ -20,3 +76,508 @@ func newAwsS3Client(region string) s3Client {
 
 	return s3.New(ssn)
 }
+
+// getS3ObjectSize returns size of S3 object by HeadObject.
+func getS3ObjectSize(ctx context.Context, src *AwsS3LogSource) (int64, error) {
+	resp, err := headObject(ctx, NewS3Client(src.Region), &s3.HeadObjectInput{
+		Bucket: aws.String(src.Bucket),
+		Key:    aws.String(src.Key),
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "Fail to get object metadata")
+	}
+	if resp == nil || resp.ContentLength == nil {
+		return 0, fmt.Errorf("No ContentLength of S3 object: s3://%s/%s", src.Bucket, src.Key)
+	}
+
+	return *resp.ContentLength, nil
+}
+
+const (
+	defaultS3RetryLimit    = 3
+	defaultS3RetryInterval = 1 * time.Second
+)
+
+// S3Options is option of downloading S3 object that is embedded in loaders.
+type S3Options struct {
+	// RetryLimit is max number of retries to resume reading S3 object after
+	// an error such as connection reset. 3 is used if zero and retry is
+	// disabled if negative.
+	RetryLimit int
+	// RetryInterval is interval before the first retry. The interval is
+	// doubled for each retry. 1 second is used if zero.
+	RetryInterval time.Duration
+
+	// VerifyChecksum enables integrity check of downloaded S3 object.
+	// Downloaded bytes are compared with additional checksum of the object
+	// (SHA-256, SHA-1, CRC32C or CRC32) if present, otherwise with ETag that is
+	// MD5 digest of the object uploaded by single PUT. Objects that have no
+	// verifiable checksum (e.g. multipart upload without additional checksum or
+	// SSE-KMS) are warned and not verified.
+	//
+	// The checksum can be verified only after reading the whole object, then
+	// records of the object are sent before the verification. A mismatch is
+	// sent as ChecksumMismatchError in the last MessageQueue of the object and
+	// the records that have been already sent should be discarded.
+	VerifyChecksum bool
+}
+
+func (x S3Options) retryLimit() int {
+	if x.RetryLimit < 0 {
+		return 0
+	} else if x.RetryLimit == 0 {
+		return defaultS3RetryLimit
+	}
+	return x.RetryLimit
+}
+
+func (x S3Options) retryInterval() time.Duration {
+	if x.RetryInterval > 0 {
+		return x.RetryInterval
+	}
+	return defaultS3RetryInterval
+}
+
+// ChecksumMismatchError is error that checksum of downloaded S3 object does not
+// match checksum of the stored object.
+type ChecksumMismatchError struct {
+	Src       *AwsS3LogSource
+	Algorithm string
+	Expected  []byte
+	Actual    []byte
+}
+
+func (x *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("%s checksum mismatch of S3 object s3://%s/%s: expected %x, actual %x",
+		x.Algorithm, x.Src.Bucket, x.Src.Key, x.Expected, x.Actual)
+}
+
+// s3ChecksumReader computes digest of the object body and compares it with
+// expected checksum at EOF.
+type s3ChecksumReader struct {
+	body   io.ReadCloser
+	src    *AwsS3LogSource
+	name   string
+	hash   hash.Hash
+	expect []byte
+}
+
+// newS3ChecksumReader wraps body of the object with checksum verification. resp
+// is response of GetObject for the object (or the first range of the object).
+// body is returned as it is if no verifiable checksum.
+func newS3ChecksumReader(src *AwsS3LogSource, resp *s3.GetObjectOutput, body io.ReadCloser) io.ReadCloser {
+	name, h, expect := s3ObjectChecksum(resp)
+	if h == nil {
+		Logger.WithFields(logrus.Fields{
+			"bucket": src.Bucket,
+			"key":    src.Key,
+			"etag":   aws.StringValue(resp.ETag),
+		}).Warn("No verifiable checksum of S3 object")
+		return body
+	}
+
+	return &s3ChecksumReader{
+		body:   body,
+		src:    src,
+		name:   name,
+		hash:   h,
+		expect: expect,
+	}
+}
+
+// s3ObjectChecksum returns name, hash and expected digest of checksum that is
+// available to verify the whole object.
+func s3ObjectChecksum(resp *s3.GetObjectOutput) (string, hash.Hash, []byte) {
+	checksums := []struct {
+		name    string
+		value   *string
+		newHash func() hash.Hash
+	}{
+		{"SHA256", resp.ChecksumSHA256, sha256.New},
+		{"SHA1", resp.ChecksumSHA1, sha1.New},
+		{"CRC32C", resp.ChecksumCRC32C, func() hash.Hash { return crc32.New(crc32.MakeTable(crc32.Castagnoli)) }},
+		{"CRC32", resp.ChecksumCRC32, func() hash.Hash { return crc32.NewIEEE() }},
+	}
+
+	for _, c := range checksums {
+		// Checksum of multipart upload is checksum of part checksums, e.g. "xxxx-3"
+		if c.value == nil || strings.Contains(*c.value, "-") {
+			continue
+		}
+		expect, err := base64.StdEncoding.DecodeString(*c.value)
+		if err != nil {
+			continue
+		}
+		return c.name, c.newHash(), expect
+	}
+
+	// ETag is not MD5 digest of the object if uploaded by multipart upload
+	// (e.g. "xxxx-3") or encrypted by SSE-KMS or SSE-C.
+	if resp.ETag == nil || aws.StringValue(resp.ServerSideEncryption) == s3.ServerSideEncryptionAwsKms ||
+		resp.SSECustomerAlgorithm != nil {
+		return "", nil, nil
+	}
+	expect, err := hex.DecodeString(strings.Trim(*resp.ETag, `"`))
+	if err != nil || len(expect) != md5.Size {
+		return "", nil, nil
+	}
+	return "MD5", md5.New(), expect
+}
+
+func (x *s3ChecksumReader) Read(p []byte) (int, error) {
+	n, err := x.body.Read(p)
+	x.hash.Write(p[:n])
+
+	if err == io.EOF {
+		if actual := x.hash.Sum(nil); !bytes.Equal(actual, x.expect) {
+			return n, &ChecksumMismatchError{Src: x.src, Algorithm: x.name, Expected: x.expect, Actual: actual}
+		}
+	}
+	return n, err
+}
+
+func (x *s3ChecksumReader) Close() error {
+	return x.body.Close()
+}
+
+// s3ObjectReader reads body of S3 object (or a range of the object). If reading
+// body fails, it resumes reading from the last offset with range request.
+// If-Match with ETag of the first response is used to ensure that the object
+// has not been changed.
+type s3ObjectReader struct {
+	ctx    context.Context
+	client s3Client
+	src    *AwsS3LogSource
+	body   io.ReadCloser
+	etag   *string
+	// offset is position of the next byte in the object
+	offset int64
+	// end is position next to the last byte to be read, or -1 if unknown
+	end int64
+	// retries is number of retries since the last successful read
+	retries int
+	opt     S3Options
+}
+
+func newS3ObjectReader(ctx context.Context, client s3Client, src *AwsS3LogSource, resp *s3.GetObjectOutput, opt S3Options) *s3ObjectReader {
+	end := int64(-1)
+	if resp.ContentLength != nil {
+		end = *resp.ContentLength
+	}
+
+	return &s3ObjectReader{
+		ctx:    ctx,
+		client: client,
+		src:    src,
+		body:   resp.Body,
+		etag:   resp.ETag,
+		end:    end,
+		opt:    opt,
+	}
+}
+
+func (x *s3ObjectReader) Read(p []byte) (int, error) {
+	n, err := x.body.Read(p)
+	x.offset += int64(n)
+	if n > 0 {
+		x.retries = 0
+	}
+	if err == nil || err == io.EOF {
+		return n, err
+	}
+	if n > 0 {
+		return n, nil // Resume at next Read
+	}
+	if x.end >= 0 && x.offset >= x.end {
+		return 0, io.EOF
+	}
+
+	if resumeErr := x.resume(err); resumeErr != nil {
+		return 0, resumeErr
+	}
+	return x.Read(p)
+}
+
+func (x *s3ObjectReader) resume(readErr error) error {
+	x.body.Close()
+
+	for x.retries < x.opt.retryLimit() {
+		if err := x.ctx.Err(); err != nil {
+			return err // Reading has been cancelled
+		}
+		x.retries++
+		Logger.WithError(readErr).WithFields(logrus.Fields{
+			"bucket": x.src.Bucket,
+			"key":    x.src.Key,
+			"offset": x.offset,
+			"retry":  x.retries,
+		}).Warn("Resume reading S3 object")
+
+		select {
+		case <-time.After(x.opt.retryInterval() << uint(x.retries-1)):
+		case <-x.ctx.Done():
+			return x.ctx.Err()
+		}
+
+		changed, err := x.open()
+		if changed {
+			return err
+		} else if x.ctx.Err() != nil {
+			return x.ctx.Err()
+		} else if err != nil {
+			readErr = err
+			continue
+		}
+
+		return nil
+	}
+
+	return errors.Wrapf(readErr, "Fail to read S3 object after %d retries", x.retries)
+}
+
+// open sends range request from offset. changed is true if the object has
+// been changed from ETag.
+func (x *s3ObjectReader) open() (bool, error) {
+	resp, err := getObject(x.ctx, x.client, &s3.GetObjectInput{
+		Bucket:  aws.String(x.src.Bucket),
+		Key:     aws.String(x.src.Key),
+		Range:   aws.String(x.rangeHeader()),
+		IfMatch: x.etag,
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "PreconditionFailed" {
+			return true, fmt.Errorf("S3 object has been changed while reading: s3://%s/%s", x.src.Bucket, x.src.Key)
+		}
+		return false, err
+	}
+
+	if x.etag != nil && (resp.ETag == nil || *resp.ETag != *x.etag) {
+		resp.Body.Close()
+		return true, fmt.Errorf("S3 object has been changed while reading: s3://%s/%s", x.src.Bucket, x.src.Key)
+	}
+
+	x.body = resp.Body
+	return false, nil
+}
+
+func (x *s3ObjectReader) rangeHeader() string {
+	if x.end >= 0 {
+		return fmt.Sprintf("bytes=%d-%d", x.offset, x.end-1)
+	}
+	return fmt.Sprintf("bytes=%d-", x.offset)
+}
+
+func (x *s3ObjectReader) Close() error {
+	return x.body.Close()
+}
+
+const defaultS3PartSize = 8 * 1024 * 1024 // 8 MB
+
+// s3Download is option of downloading S3 object. Parallel ranged download is
+// enabled if Concurrency is more than 1.
+type s3Download struct {
+	S3Options
+	PartSize    int64
+	Concurrency int
+}
+
+func (x s3Download) partSize() int64 {
+	if x.PartSize > 0 {
+		return x.PartSize
+	}
+	return defaultS3PartSize
+}
+
+// getObjectRange sends range request and returns data and Content-Range of the
+// response.
+func getObjectRange(ctx context.Context, client s3Client, src *AwsS3LogSource, rangeHeader string) ([]byte, *string, error) {
+	resp, err := getObject(ctx, client, &s3.GetObjectInput{
+		Bucket: aws.String(src.Bucket),
+		Key:    aws.String(src.Key),
+		Range:  aws.String(rangeHeader),
+	})
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "Fail to get object range %s", rangeHeader)
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Fail to read S3 object data")
+	}
+
+	return data, resp.ContentRange, nil
+}
+
+// parseContentRangeSize returns size of object in Content-Range header, e.g.
+// "bytes 0-3/12345".
+func parseContentRangeSize(contentRange *string) (int64, error) {
+	if contentRange == nil {
+		return 0, fmt.Errorf("No Content-Range in response of range request")
+	}
+
+	sp := strings.LastIndexByte(*contentRange, '/')
+	if sp < 0 {
+		return 0, fmt.Errorf("Invalid Content-Range: %s", *contentRange)
+	}
+	size, err := strconv.ParseInt((*contentRange)[sp+1:], 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Invalid Content-Range: %s", *contentRange)
+	}
+
+	return size, nil
+}
+
+// s3Part is a downloaded part of S3 object.
+type s3Part struct {
+	buf []byte
+	err error
+}
+
+// s3ParallelReader downloads parts of S3 object concurrently with range requests
+// and provides data of the parts in order. Number of parts on memory (being
+// downloaded or waiting to be read) is limited by Concurrency as buffer pool.
+// Close cancels downloading parts and it can be called more than once.
+type s3ParallelReader struct {
+	parts  chan chan *s3Part
+	pool   chan []byte
+	ctx    context.Context
+	cancel context.CancelFunc
+	once   sync.Once
+	cur    *s3Part
+	pos    int
+}
+
+// newS3ParallelReader starts downloading rest of parts. resp is response of
+// range request for the first part and size is size of the object. Requests
+// are cancelled by cancel of ctx when the reader is closed.
+func newS3ParallelReader(ctx context.Context, cancel context.CancelFunc, client s3Client, src *AwsS3LogSource, resp *s3.GetObjectOutput, size int64, dl s3Download) *s3ParallelReader {
+	partSize := dl.partSize()
+	x := &s3ParallelReader{
+		parts:  make(chan chan *s3Part, dl.Concurrency),
+		pool:   make(chan []byte, dl.Concurrency),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	for i := 0; i < dl.Concurrency; i++ {
+		x.pool <- make([]byte, partSize)
+	}
+
+	fetch := func(body *s3ObjectReader, buf []byte, ch chan *s3Part) {
+		defer body.Close()
+		n, err := io.ReadFull(body, buf[:body.end-body.offset])
+		ch <- &s3Part{buf: buf[:n], err: err}
+	}
+
+	go func() {
+		defer close(x.parts)
+
+		first := newS3ObjectReader(ctx, client, src, resp, dl.S3Options)
+		first.end = partSize
+		if first.end > size {
+			first.end = size
+		}
+
+		for begin := int64(0); begin < size; begin += partSize {
+			var buf []byte
+			select {
+			case buf = <-x.pool:
+			case <-ctx.Done():
+				if begin == 0 {
+					first.Close()
+				}
+				return
+			}
+
+			ch := make(chan *s3Part, 1)
+			select {
+			case x.parts <- ch:
+			case <-ctx.Done():
+				if begin == 0 {
+					first.Close()
+				}
+				return
+			}
+
+			if begin == 0 {
+				go fetch(first, buf, ch)
+				continue
+			}
+
+			end := begin + partSize
+			if end > size {
+				end = size
+			}
+
+			go func(begin, end int64) {
+				body := &s3ObjectReader{
+					ctx:    ctx,
+					client: client,
+					src:    src,
+					body:   ioutil.NopCloser(bytes.NewReader(nil)),
+					etag:   resp.ETag,
+					offset: begin,
+					end:    end,
+					opt:    dl.S3Options,
+				}
+				if changed, err := body.open(); changed {
+					ch <- &s3Part{buf: buf[:0], err: err}
+					return
+				} else if err != nil {
+					if err := body.resume(err); err != nil {
+						ch <- &s3Part{buf: buf[:0], err: err}
+						return
+					}
+				}
+				fetch(body, buf, ch)
+			}(begin, end)
+		}
+	}()
+
+	return x
+}
+
+func (x *s3ParallelReader) Read(p []byte) (int, error) {
+	for x.cur == nil || x.pos >= len(x.cur.buf) {
+		if x.cur != nil {
+			if x.cur.err != nil {
+				return 0, x.cur.err
+			}
+			x.pool <- x.cur.buf[:cap(x.cur.buf)]
+			x.cur = nil
+		}
+
+		var ch chan *s3Part
+		var ok bool
+		select {
+		case ch, ok = <-x.parts:
+			if !ok {
+				if err := x.ctx.Err(); err != nil {
+					return 0, err
+				}
+				return 0, io.EOF
+			}
+		case <-x.ctx.Done():
+			return 0, x.ctx.Err()
+		}
+
+		select {
+		case x.cur = <-ch:
+			x.pos = 0
+		case <-x.ctx.Done():
+			return 0, x.ctx.Err()
+		}
+		if x.cur.err != nil && len(x.cur.buf) == 0 {
+			return 0, x.cur.err
+		}
+	}
+
+	n := copy(p, x.cur.buf[x.pos:])
+	x.pos += n
+	return n, nil
+}
+
+func (x *s3ParallelReader) Close() error {
+	x.once.Do(x.cancel)
+	return nil
+}
@@ -0,0 +1,468 @@
+package rlogs_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/m-mizutani/rlogs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// brokenReader returns error after reading data.
+type brokenReader struct {
+	r io.Reader
+}
+
+func (x *brokenReader) Read(p []byte) (int, error) {
+	n, err := x.r.Read(p)
+	if err == io.EOF {
+		return n, errors.New("connection reset by peer")
+	}
+	return n, err
+}
+
+// dummyS3ClientBroken serves data, but connection of the first request and
+// requests in fails are broken after brokenAt bytes.
+type dummyS3ClientBroken struct {
+	rlogs.TestS3ClientBase
+	data     string
+	brokenAt int
+	fails    int
+	etags    []string
+	ranges   []string
+}
+
+func (x *dummyS3ClientBroken) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	call := len(x.ranges)
+	etag := x.etags[call%len(x.etags)]
+
+	offset := 0
+	if input.Range != nil {
+		x.ranges = append(x.ranges, *input.Range)
+		offset, _ = strconv.Atoi(strings.Split(strings.TrimPrefix(*input.Range, "bytes="), "-")[0])
+	} else {
+		x.ranges = append(x.ranges, "")
+	}
+
+	if input.IfMatch != nil && *input.IfMatch != etag {
+		return nil, awserr.New("PreconditionFailed", "At least one of the pre-conditions you specified did not hold", nil)
+	}
+
+	data := x.data[offset:]
+	var body io.Reader = strings.NewReader(data)
+	if call <= x.fails && x.brokenAt < len(data) {
+		body = &brokenReader{r: strings.NewReader(data[:x.brokenAt])}
+	}
+
+	return &s3.GetObjectOutput{
+		Body:          ioutil.NopCloser(body),
+		ETag:          aws.String(etag),
+		ContentLength: aws.Int64(int64(len(data))),
+	}, nil
+}
+
+var testS3Options = rlogs.S3Options{RetryInterval: time.Millisecond}
+
+func TestS3ObjectReaderResume(t *testing.T) {
+	defer rlogs.FixNewS3Client()
+
+	data := "blue\norange\nred\nlemon\n"
+	dummy := &dummyS3ClientBroken{data: data, brokenAt: 7, fails: 1, etags: []string{`"abc"`}}
+	rlogs.InjectNewS3Client(dummy)
+
+	messages := loadMessages(&rlogs.S3LineLoader{S3Options: testS3Options}, "data.log")
+	require.Equal(t, 4, len(messages))
+	for i, expect := range []string{"blue", "orange", "red", "lemon"} {
+		require.NoError(t, messages[i].Error)
+		assert.Equal(t, expect, string(messages[i].Raw))
+	}
+	assert.Equal(t, []string{"", "bytes=7-21", "bytes=14-21"}, dummy.ranges)
+
+	dummy = &dummyS3ClientBroken{data: data, brokenAt: 3, fails: 3, etags: []string{`"abc"`}}
+	rlogs.InjectNewS3Client(dummy)
+
+	messages = loadMessages(&rlogs.S3FileLoader{S3Options: testS3Options}, "data.log")
+	require.Equal(t, 1, len(messages))
+	require.NoError(t, messages[0].Error)
+	assert.Equal(t, data, string(messages[0].Raw))
+}
+
+func TestS3ObjectReaderRetryLimit(t *testing.T) {
+	defer rlogs.FixNewS3Client()
+
+	dummy := &dummyS3ClientBroken{data: "blue\norange\nred\n", brokenAt: 0, fails: 100, etags: []string{`"abc"`}}
+	rlogs.InjectNewS3Client(dummy)
+
+	messages := loadMessages(&rlogs.S3FileLoader{S3Options: testS3Options}, "data.log")
+	require.Equal(t, 1, len(messages))
+	require.Error(t, messages[0].Error)
+	assert.Contains(t, messages[0].Error.Error(), "after 3 retries")
+	assert.Equal(t, 1+3, len(dummy.ranges))
+
+	// Retry limit is configured per loader, and negative value disables retry
+	dummy = &dummyS3ClientBroken{data: "blue\norange\nred\n", brokenAt: 0, fails: 100, etags: []string{`"abc"`}}
+	rlogs.InjectNewS3Client(dummy)
+
+	messages = loadMessages(&rlogs.S3FileLoader{S3Options: rlogs.S3Options{RetryLimit: 1, RetryInterval: time.Millisecond}}, "data.log")
+	require.Equal(t, 1, len(messages))
+	require.Error(t, messages[0].Error)
+	assert.Contains(t, messages[0].Error.Error(), "after 1 retries")
+	assert.Equal(t, 1+1, len(dummy.ranges))
+
+	dummy = &dummyS3ClientBroken{data: "blue\norange\nred\n", brokenAt: 0, fails: 100, etags: []string{`"abc"`}}
+	rlogs.InjectNewS3Client(dummy)
+
+	messages = loadMessages(&rlogs.S3FileLoader{S3Options: rlogs.S3Options{RetryLimit: -1}}, "data.log")
+	require.Equal(t, 1, len(messages))
+	require.Error(t, messages[0].Error)
+	assert.Contains(t, messages[0].Error.Error(), "after 0 retries")
+	assert.Equal(t, 1, len(dummy.ranges))
+}
+
+func TestS3ObjectReaderChanged(t *testing.T) {
+	defer rlogs.FixNewS3Client()
+
+	dummy := &dummyS3ClientBroken{data: "blue\norange\nred\n", brokenAt: 7, fails: 1, etags: []string{`"abc"`, `"def"`}}
+	rlogs.InjectNewS3Client(dummy)
+
+	messages := loadMessages(&rlogs.S3LineLoader{S3Options: testS3Options}, "data.log")
+	require.True(t, len(messages) >= 2)
+	assert.Equal(t, "blue", string(messages[0].Raw))
+	last := messages[len(messages)-1]
+	require.Error(t, last.Error)
+	assert.Contains(t, last.Error.Error(), "has been changed")
+}
+
+// dummyS3ClientRanged serves range requests concurrently. ETag of the object is
+// changed to changedETag after the first request if it is not empty.
+type dummyS3ClientRanged struct {
+	rlogs.TestS3ClientBase
+	data        string
+	changedETag string
+	mutex       sync.Mutex
+	ranges      []string
+}
+
+func (x *dummyS3ClientRanged) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	x.mutex.Lock()
+	etag := `"abc"`
+	if len(x.ranges) > 0 && x.changedETag != "" {
+		etag = x.changedETag
+	}
+	x.ranges = append(x.ranges, aws.StringValue(input.Range))
+	x.mutex.Unlock()
+
+	if input.IfMatch != nil && *input.IfMatch != etag {
+		return nil, awserr.New("PreconditionFailed", "At least one of the pre-conditions you specified did not hold", nil)
+	}
+
+	if input.Range == nil {
+		return &s3.GetObjectOutput{
+			Body:          ioutil.NopCloser(strings.NewReader(x.data)),
+			ETag:          aws.String(etag),
+			ContentLength: aws.Int64(int64(len(x.data))),
+		}, nil
+	}
+
+	if len(x.data) == 0 {
+		return nil, awserr.New("InvalidRange", "The requested range is not satisfiable", nil)
+	}
+
+	r := strings.Split(strings.TrimPrefix(*input.Range, "bytes="), "-")
+	begin, _ := strconv.Atoi(r[0])
+	end, _ := strconv.Atoi(r[1])
+	if end >= len(x.data) {
+		end = len(x.data) - 1
+	}
+
+	return &s3.GetObjectOutput{
+		Body:          ioutil.NopCloser(strings.NewReader(x.data[begin : end+1])),
+		ETag:          aws.String(etag),
+		ContentLength: aws.Int64(int64(end - begin + 1)),
+		ContentRange:  aws.String(fmt.Sprintf("bytes %d-%d/%d", begin, end, len(x.data))),
+	}, nil
+}
+
+func TestS3LineLoaderParallel(t *testing.T) {
+	defer rlogs.FixNewS3Client()
+
+	dummy := &dummyS3ClientRanged{data: "blue\norange\nred\nlemon\nstrawberry\n"}
+	rlogs.InjectNewS3Client(dummy)
+
+	messages := loadMessages(&rlogs.S3LineLoader{PartSize: 4, Concurrency: 3}, "data.log")
+	require.Equal(t, 5, len(messages))
+	for i, expect := range []string{"blue", "orange", "red", "lemon", "strawberry"} {
+		require.NoError(t, messages[i].Error)
+		assert.Equal(t, expect, string(messages[i].Raw))
+	}
+	// 33 bytes are downloaded as 9 parts
+	assert.Equal(t, 9, len(dummy.ranges))
+	assert.Equal(t, "bytes=0-3", dummy.ranges[0])
+	assert.Contains(t, dummy.ranges, "bytes=32-32")
+}
+
+func TestS3LineLoaderParallelEmpty(t *testing.T) {
+	defer rlogs.FixNewS3Client()
+
+	dummy := &dummyS3ClientRanged{data: ""}
+	rlogs.InjectNewS3Client(dummy)
+
+	messages := loadMessages(&rlogs.S3LineLoader{PartSize: 4, Concurrency: 3}, "data.log")
+	assert.Equal(t, 0, len(messages))
+	assert.Equal(t, []string{"bytes=0-3", ""}, dummy.ranges)
+}
+
+func TestS3LineLoaderParallelChanged(t *testing.T) {
+	defer rlogs.FixNewS3Client()
+
+	dummy := &dummyS3ClientRanged{data: "blue\norange\nred\nlemon\n", changedETag: `"def"`}
+	rlogs.InjectNewS3Client(dummy)
+
+	messages := loadMessages(&rlogs.S3LineLoader{S3Options: testS3Options, PartSize: 8, Concurrency: 2}, "data.log")
+	require.True(t, len(messages) >= 1)
+	last := messages[len(messages)-1]
+	require.Error(t, last.Error)
+	assert.Contains(t, last.Error.Error(), "has been changed")
+}
+
+// dummyS3ClientBlocking serves the first part and blocks requests of other
+// parts until the request is cancelled.
+type dummyS3ClientBlocking struct {
+	dummyS3ClientRanged
+	cancelled chan string
+}
+
+func (x *dummyS3ClientBlocking) GetObjectWithContext(ctx aws.Context, input *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
+	if aws.StringValue(input.Range) == "bytes=0-3" {
+		return x.GetObject(input)
+	}
+
+	<-ctx.Done()
+	x.cancelled <- aws.StringValue(input.Range)
+	return nil, ctx.Err()
+}
+
+func TestS3ParallelReaderClose(t *testing.T) {
+	defer rlogs.FixNewS3Client()
+
+	dummy := &dummyS3ClientBlocking{
+		dummyS3ClientRanged: dummyS3ClientRanged{data: "blue\norange\nred\nlemon\n"},
+		cancelled:           make(chan string, 8),
+	}
+	rlogs.InjectNewS3Client(dummy)
+
+	r, err := rlogs.OpenS3Object(&rlogs.AwsS3LogSource{Region: "ap-northeast-1", Bucket: "my-own-bucket", Key: "data.log"}, 4, 3)
+	require.NoError(t, err)
+
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(r, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "blue", string(buf))
+
+	// In-flight requests of the 2nd and 3rd parts are cancelled by Close
+	require.NoError(t, r.Close())
+	require.NoError(t, r.Close())
+
+	var ranges []string
+	for i := 0; i < 2; i++ {
+		select {
+		case rng := <-dummy.cancelled:
+			ranges = append(ranges, rng)
+		case <-time.After(3 * time.Second):
+			require.Fail(t, "requests are not cancelled")
+		}
+	}
+	assert.ElementsMatch(t, []string{"bytes=4-7", "bytes=8-11"}, ranges)
+
+	_, err = r.Read(buf)
+	assert.Equal(t, context.Canceled, err)
+}
+
+// closeRecorder records Close of body.
+type closeRecorder struct {
+	io.Reader
+	closed int
+}
+
+func (x *closeRecorder) Close() error {
+	x.closed++
+	return nil
+}
+
+type dummyS3ClientGzipBody struct {
+	rlogs.TestS3ClientBase
+	body *closeRecorder
+}
+
+func (x *dummyS3ClientGzipBody) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	return &s3.GetObjectOutput{
+		Body:        x.body,
+		ContentType: aws.String("application/x-gzip"),
+	}, nil
+}
+
+func TestS3ObjectReaderGzipClose(t *testing.T) {
+	defer rlogs.FixNewS3Client()
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	gw.Write([]byte("blue\norange\n"))
+	gw.Close()
+
+	dummy := &dummyS3ClientGzipBody{body: &closeRecorder{Reader: &buf}}
+	rlogs.InjectNewS3Client(dummy)
+
+	r, err := rlogs.OpenS3Object(&rlogs.AwsS3LogSource{Region: "ap-northeast-1", Bucket: "my-own-bucket", Key: "data.log.gz"}, 0, 0)
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "blue\norange\n", string(data))
+
+	// Body of the object is closed with gzip reader
+	require.NoError(t, r.Close())
+	assert.Equal(t, 1, dummy.body.closed)
+}
+
+// dummyS3ClientChecksum serves data with headers of output.
+type dummyS3ClientChecksum struct {
+	rlogs.TestS3ClientBase
+	data   string
+	output s3.GetObjectOutput
+	input  *s3.GetObjectInput
+}
+
+func (x *dummyS3ClientChecksum) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	x.input = input
+	output := x.output
+	output.Body = ioutil.NopCloser(strings.NewReader(x.data))
+	output.ContentLength = aws.Int64(int64(len(x.data)))
+	return &output, nil
+}
+
+func TestS3VerifyChecksum(t *testing.T) {
+	defer rlogs.FixNewS3Client()
+
+	data := "blue\norange\nred\n"
+	testCases := []struct {
+		title    string
+		output   s3.GetObjectOutput
+		mismatch string
+	}{
+		{
+			title:  "SHA256",
+			output: s3.GetObjectOutput{ChecksumSHA256: aws.String("ohaXt8IrNvvEFspYa28i/ZxOCQbQHL3PNh6tkzC5zac=")},
+		},
+		{
+			title:  "SHA1",
+			output: s3.GetObjectOutput{ChecksumSHA1: aws.String("Te1381M3YvGWDfeWMGcavwsd65s=")},
+		},
+		{
+			title:  "CRC32C",
+			output: s3.GetObjectOutput{ChecksumCRC32C: aws.String("vjw7jw==")},
+		},
+		{
+			title:  "CRC32",
+			output: s3.GetObjectOutput{ChecksumCRC32: aws.String("T6mRxQ==")},
+		},
+		{
+			title:  "ETag",
+			output: s3.GetObjectOutput{ETag: aws.String(`"a8faabad5ada436f9b5b2cedef1b9560"`)},
+		},
+		{
+			title:    "SHA256 mismatch",
+			output:   s3.GetObjectOutput{ChecksumSHA256: aws.String("47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=")},
+			mismatch: "SHA256 checksum mismatch",
+		},
+		{
+			title: "CRC32 mismatch prior to ETag",
+			output: s3.GetObjectOutput{
+				ChecksumCRC32: aws.String("AAAAAA=="),
+				ETag:          aws.String(`"a8faabad5ada436f9b5b2cedef1b9560"`),
+			},
+			mismatch: "CRC32 checksum mismatch",
+		},
+		{
+			title:    "ETag mismatch",
+			output:   s3.GetObjectOutput{ETag: aws.String(`"d41d8cd98f00b204e9800998ecf8427e"`)},
+			mismatch: "MD5 checksum mismatch",
+		},
+		{
+			title:  "Multipart upload is not verified",
+			output: s3.GetObjectOutput{ETag: aws.String(`"d41d8cd98f00b204e9800998ecf8427e-2"`), ChecksumSHA256: aws.String("47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=-2")},
+		},
+		{
+			title: "ETag of SSE-KMS is not verified",
+			output: s3.GetObjectOutput{
+				ETag:                 aws.String(`"d41d8cd98f00b204e9800998ecf8427e"`),
+				ServerSideEncryption: aws.String("aws:kms"),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.title, func(t *testing.T) {
+			dummy := &dummyS3ClientChecksum{data: data, output: tc.output}
+			rlogs.InjectNewS3Client(dummy)
+
+			messages := loadMessages(&rlogs.S3LineLoader{S3Options: rlogs.S3Options{VerifyChecksum: true}}, "data.log")
+			assert.Equal(t, "ENABLED", aws.StringValue(dummy.input.ChecksumMode))
+
+			if tc.mismatch == "" {
+				require.Equal(t, 3, len(messages))
+				for i, expect := range []string{"blue", "orange", "red"} {
+					require.NoError(t, messages[i].Error)
+					assert.Equal(t, expect, string(messages[i].Raw))
+				}
+			} else {
+				require.True(t, len(messages) >= 1)
+				last := messages[len(messages)-1]
+				require.Error(t, last.Error)
+				assert.Contains(t, last.Error.Error(), tc.mismatch)
+				var mismatch *rlogs.ChecksumMismatchError
+				assert.True(t, errors.As(last.Error, &mismatch))
+			}
+		})
+	}
+}
+
+func TestS3VerifyChecksumFileLoader(t *testing.T) {
+	defer rlogs.FixNewS3Client()
+
+	dummy := &dummyS3ClientChecksum{
+		data:   "blue\norange\nred\n",
+		output: s3.GetObjectOutput{ChecksumCRC32C: aws.String("AAAAAA==")},
+	}
+	rlogs.InjectNewS3Client(dummy)
+
+	messages := loadMessages(&rlogs.S3FileLoader{S3Options: rlogs.S3Options{VerifyChecksum: true}}, "data.json")
+	require.Equal(t, 1, len(messages))
+	require.Error(t, messages[0].Error)
+	assert.Contains(t, messages[0].Error.Error(), "CRC32C checksum mismatch")
+
+	// Checksum is not verified by default
+	dummy = &dummyS3ClientChecksum{
+		data:   "blue\norange\nred\n",
+		output: s3.GetObjectOutput{ChecksumCRC32C: aws.String("AAAAAA==")},
+	}
+	rlogs.InjectNewS3Client(dummy)
+
+	messages = loadMessages(&rlogs.S3FileLoader{}, "data.json")
+	require.Equal(t, 1, len(messages))
+	require.NoError(t, messages[0].Error)
+	assert.Nil(t, dummy.input.ChecksumMode)
+}